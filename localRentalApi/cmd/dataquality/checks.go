@@ -0,0 +1,245 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// completenessColumns lists the source columns whose null or empty rate is
+// reported; text columns also count blank values as empty
+var completenessColumns = []struct {
+	name string
+	text bool
+}{
+	{"object_id", false}, {"nr_rnal", false}, {"denominacao", true},
+	{"data_registo", false}, {"data_abertura_publico", false}, {"modalidade", true},
+	{"nr_utentes", false}, {"email", true}, {"endereco", true}, {"codigo_postal", true},
+	{"localidade", true}, {"latitude", false}, {"longitude", false},
+	{"fiabilidade_geo", true}, {"freguesia", true}, {"concelho", true}, {"distrito", true},
+	{"nuts_iii", true}, {"nuts_ii", true}, {"ert", true}, {"selo_clean_safe", true},
+	{"distrito_code", true}, {"concelho_code", true}, {"freguesia_code", true},
+}
+
+// Keys duplicates are grouped on, with the condition a record needs to have
+// one, shared by the checks and the listings of the most shared values so
+// both count the same groups. The separator keeps an address ending in digits
+// apart from the postal code, and reads well in the listing.
+const (
+	emailKey   = "lower(btrim(email))"
+	hasEmail   = "btrim(email) != ''"
+	addressKey = "lower(btrim(endereco)) || ' | ' || COALESCE(btrim(codigo_postal), '')"
+	hasAddress = "btrim(endereco) != ''"
+)
+
+// region is a latitude/longitude box around part of Portugal
+type region struct {
+	name                           string
+	minLat, maxLat, minLng, maxLng float64
+}
+
+// portugalRegions covers mainland Portugal, the Madeira archipelago (Madeira
+// and Porto Santo) and the Azores
+var portugalRegions = []region{
+	{"mainland", 36.9, 42.2, -9.6, -6.1},
+	{"madeira", 32.3, 33.2, -17.4, -16.2},
+	{"azores", 36.8, 39.8, -31.4, -24.9},
+}
+
+// checkDef describes a rule some records break. condition selects the
+// offending records and value, when set, is shown for each example.
+type checkDef struct {
+	name        string
+	description string
+	condition   string
+	value       string
+}
+
+// hasCoordinates excludes records without a usable position
+const hasCoordinates = "latitude IS NOT NULL AND longitude IS NOT NULL AND NOT (latitude = 0 AND longitude = 0)"
+
+// ptPostalCodePattern mirrors the pt_postal_code validation rule
+const ptPostalCodePattern = `^[0-9]{4}-[0-9]{3}$`
+
+// checkDefs returns the checks run against the records selected by scope.
+// The capacity outlier check needs the upper fence computed beforehand.
+func checkDefs(scope string, capacityFence float64) []checkDef {
+	var inside []string
+	for _, r := range portugalRegions {
+		inside = append(inside, fmt.Sprintf("(latitude BETWEEN %g AND %g AND longitude BETWEEN %g AND %g)",
+			r.minLat, r.maxLat, r.minLng, r.maxLng))
+	}
+
+	duplicated := func(key, filter string) string {
+		return fmt.Sprintf(`%s AND %s IN (
+			SELECT %s FROM alojamentos WHERE %s AND %s GROUP BY 1 HAVING COUNT(*) > 1
+		)`, filter, key, key, scope, filter)
+	}
+
+	return []checkDef{
+		{
+			name:        "geo_not_reliable",
+			description: "Location flagged as unreliable (fiabilidade_geo = 'NaoFiavel')",
+			condition:   "fiabilidade_geo = 'NaoFiavel'",
+		},
+		{
+			name:        "missing_coordinates",
+			description: "No coordinates, or (0,0)",
+			condition:   "NOT (" + hasCoordinates + ")",
+		},
+		{
+			name:        "coordinates_outside_portugal",
+			description: "Coordinates outside mainland Portugal, Madeira and the Azores",
+			condition:   hasCoordinates + " AND NOT (" + strings.Join(inside, " OR ") + ")",
+			value:       "latitude || ', ' || longitude",
+		},
+		{
+			name:        "invalid_postal_code",
+			description: "Postal code not in the NNNN-NNN format",
+			condition:   fmt.Sprintf("btrim(codigo_postal) != '' AND codigo_postal !~ '%s'", ptPostalCodePattern),
+			value:       "codigo_postal",
+		},
+		{
+			name:        "duplicate_email",
+			description: "Email shared with another record",
+			condition:   duplicated(emailKey, hasEmail),
+			value:       "email",
+		},
+		{
+			name:        "duplicate_address",
+			description: "Address and postal code shared with another record",
+			condition:   duplicated(addressKey, hasAddress),
+			value:       "endereco || ' ' || COALESCE(codigo_postal, '')",
+		},
+		{
+			name:        "future_data_registo",
+			description: "Registration date (data_registo) in the future",
+			condition:   "data_registo > CURRENT_TIMESTAMP",
+			value:       "data_registo",
+		},
+		{
+			name:        "capacity_outlier",
+			description: fmt.Sprintf("Capacity (nr_utentes) above %g, three interquartile ranges past the third quartile", capacityFence),
+			condition:   fmt.Sprintf("nr_utentes > %g", capacityFence),
+			value:       "nr_utentes",
+		},
+		{
+			name:        "non_positive_capacity",
+			description: "Capacity (nr_utentes) of zero or less",
+			condition:   "nr_utentes <= 0",
+			value:       "nr_utentes",
+		},
+	}
+}
+
+// completeness returns the null or empty count of every completeness column
+func completeness(db *sql.DB, scope string, total int) ([]columnCompleteness, error) {
+	exprs := make([]string, len(completenessColumns))
+	for i, col := range completenessColumns {
+		if col.text {
+			exprs[i] = fmt.Sprintf("COUNT(*) FILTER (WHERE %s IS NULL OR btrim(%s) = '')", col.name, col.name)
+		} else {
+			exprs[i] = fmt.Sprintf("COUNT(*) FILTER (WHERE %s IS NULL)", col.name)
+		}
+	}
+
+	counts := make([]int, len(completenessColumns))
+	dest := make([]any, len(counts))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+
+	query := "SELECT " + strings.Join(exprs, ", ") + " FROM alojamentos WHERE " + scope
+	if err := db.QueryRow(query).Scan(dest...); err != nil {
+		return nil, fmt.Errorf("failed to measure completeness: %w", err)
+	}
+
+	result := make([]columnCompleteness, len(counts))
+	for i, col := range completenessColumns {
+		result[i] = columnCompleteness{Column: col.name, Empty: counts[i], Rate: rate(counts[i], total)}
+	}
+	return result, nil
+}
+
+// capacityQuartiles returns the first and third quartiles of nr_utentes
+func capacityQuartiles(db *sql.DB, scope string) (q1, q3 float64, err error) {
+	err = db.QueryRow(`
+		SELECT COALESCE(percentile_cont(0.25) WITHIN GROUP (ORDER BY nr_utentes), 0),
+		       COALESCE(percentile_cont(0.75) WITHIN GROUP (ORDER BY nr_utentes), 0)
+		FROM alojamentos
+		WHERE nr_utentes IS NOT NULL AND `+scope).Scan(&q1, &q3)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to compute capacity quartiles: %w", err)
+	}
+	return q1, q3, nil
+}
+
+// runCheck counts the records breaking def and collects up to limit examples
+func runCheck(db *sql.DB, scope string, def checkDef, total, limit int) (checkResult, error) {
+	result := checkResult{Name: def.name, Description: def.description}
+
+	where := " FROM alojamentos WHERE " + scope + " AND (" + def.condition + ")"
+	if err := db.QueryRow("SELECT COUNT(*)" + where).Scan(&result.Count); err != nil {
+		return result, fmt.Errorf("check %s failed: %w", def.name, err)
+	}
+	result.Rate = rate(result.Count, total)
+
+	if def.value == "" || result.Count == 0 || limit <= 0 {
+		return result, nil
+	}
+
+	rows, err := db.Query("SELECT id, nr_rnal, ("+def.value+")::text"+where+" ORDER BY id LIMIT $1", limit)
+	if err != nil {
+		return result, fmt.Errorf("check %s failed: %w", def.name, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ex example
+		var nrRNAL sql.NullInt64
+		var value sql.NullString
+		if err := rows.Scan(&ex.ID, &nrRNAL, &value); err != nil {
+			return result, fmt.Errorf("check %s failed: %w", def.name, err)
+		}
+		ex.NrRNAL = int(nrRNAL.Int64)
+		ex.Value = value.String
+		result.Examples = append(result.Examples, ex)
+	}
+
+	return result, rows.Err()
+}
+
+// duplicateGroups lists the most repeated values of key among the records
+// matching filter
+func duplicateGroups(db *sql.DB, scope, key, filter string, limit int) ([]duplicateGroup, error) {
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT %s, COUNT(*)
+		FROM alojamentos
+		WHERE %s AND %s
+		GROUP BY 1
+		HAVING COUNT(*) > 1
+		ORDER BY 2 DESC, 1
+		LIMIT $1
+	`, key, scope, filter), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list duplicates: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []duplicateGroup
+	for rows.Next() {
+		var g duplicateGroup
+		if err := rows.Scan(&g.Value, &g.Count); err != nil {
+			return nil, fmt.Errorf("failed to list duplicates: %w", err)
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+func rate(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"localRental/pkg/database"
+
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	dbConn := flag.String("db", "postgres://localhost/alojamentos?sslmode=disable", "PostgreSQL connection string")
+	markdownFile := flag.String("markdown", "dataquality.md", "Markdown report path (empty to skip)")
+	jsonFile := flag.String("json", "dataquality.json", "JSON report path (empty to skip)")
+	examples := flag.Int("examples", 10, "Example records listed per check and duplicate values listed")
+	includeDeregistered := flag.Bool("include-deregistered", false, "Also scan deregistered records")
+	save := flag.Bool("save", true, "Store the report in data_quality_reports so the next one shows the changes")
+	flag.Parse()
+
+	db, err := sql.Open("postgres", *dbConn)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Test connection
+	if err := db.Ping(); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	if err := database.CheckSchema(db); err != nil {
+		log.Fatalf("Schema check failed: %v", err)
+	}
+
+	r, err := scan(db, *includeDeregistered, *examples)
+	if err != nil {
+		log.Fatalf("Scan failed: %v", err)
+	}
+
+	if err := r.loadPrevious(db); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	if *markdownFile != "" {
+		if err := writeFile(*markdownFile, r.writeMarkdown); err != nil {
+			log.Fatalf("Failed to write Markdown report: %v", err)
+		}
+		log.Printf("Wrote %s", *markdownFile)
+	}
+	if *jsonFile != "" {
+		if err := writeFile(*jsonFile, r.writeJSON); err != nil {
+			log.Fatalf("Failed to write JSON report: %v", err)
+		}
+		log.Printf("Wrote %s", *jsonFile)
+	}
+
+	if *save {
+		if err := r.save(db); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+
+	for _, c := range r.Checks {
+		fmt.Printf("  %-30s: %d\n", c.Name, c.Count)
+	}
+}
+
+// scan runs every check against the alojamentos table
+func scan(db *sql.DB, includeDeregistered bool, examples int) (*report, error) {
+	scope := "deregistered_at IS NULL"
+	if includeDeregistered {
+		scope = "TRUE"
+	}
+
+	r := &report{IncludeDeregistered: includeDeregistered}
+	if err := db.QueryRow("SELECT LOCALTIMESTAMP").Scan(&r.GeneratedAt); err != nil {
+		return nil, fmt.Errorf("failed to read database timestamp: %w", err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM alojamentos WHERE " + scope).Scan(&r.Total); err != nil {
+		return nil, fmt.Errorf("failed to count records: %w", err)
+	}
+
+	q1, q3, err := capacityQuartiles(db, scope)
+	if err != nil {
+		return nil, err
+	}
+	r.Capacity = capacityStats{Q1: q1, Q3: q3, UpperFence: q3 + 3*(q3-q1)}
+
+	for _, def := range checkDefs(scope, r.Capacity.UpperFence) {
+		result, err := runCheck(db, scope, def, r.Total, examples)
+		if err != nil {
+			return nil, err
+		}
+		r.Checks = append(r.Checks, result)
+	}
+
+	if r.Completeness, err = completeness(db, scope, r.Total); err != nil {
+		return nil, err
+	}
+
+	r.DuplicateEmails, err = duplicateGroups(db, scope, emailKey, hasEmail, examples)
+	if err != nil {
+		return nil, err
+	}
+	r.DuplicateAddresses, err = duplicateGroups(db, scope, addressKey, hasAddress, examples)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// writeFile creates path and fills it with write
+func writeFile(path string, write func(io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// report is the outcome of a data quality scan, written as Markdown and JSON
+// and stored in data_quality_reports to follow the trend between scans
+type report struct {
+	GeneratedAt         time.Time            `json:"generated_at"`
+	IncludeDeregistered bool                 `json:"include_deregistered"`
+	Total               int                  `json:"total"`
+	Checks              []checkResult        `json:"checks"`
+	Completeness        []columnCompleteness `json:"completeness"`
+	DuplicateEmails     []duplicateGroup     `json:"duplicate_emails"`
+	DuplicateAddresses  []duplicateGroup     `json:"duplicate_addresses"`
+	Capacity            capacityStats        `json:"capacity"`
+	// PreviousAt is when the report compared against was generated
+	PreviousAt *time.Time `json:"previous_at,omitempty"`
+}
+
+// checkResult counts the records breaking one check. Previous holds the count
+// of the last stored report, when it ran the same check.
+type checkResult struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Count       int       `json:"count"`
+	Rate        float64   `json:"rate"`
+	Previous    *int      `json:"previous,omitempty"`
+	Examples    []example `json:"examples,omitempty"`
+}
+
+// example is one record breaking a check, with the offending value
+type example struct {
+	ID     int    `json:"id"`
+	NrRNAL int    `json:"nr_rnal"`
+	Value  string `json:"value"`
+}
+
+// columnCompleteness is the null or empty count of one column
+type columnCompleteness struct {
+	Column string  `json:"column"`
+	Empty  int     `json:"empty"`
+	Rate   float64 `json:"rate"`
+}
+
+// duplicateGroup is a value shared by several records
+type duplicateGroup struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// capacityStats describes the nr_utentes distribution behind the outlier check
+type capacityStats struct {
+	Q1         float64 `json:"q1"`
+	Q3         float64 `json:"q3"`
+	UpperFence float64 `json:"upper_fence"`
+}
+
+func (r *report) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func (r *report) writeMarkdown(w io.Writer) error {
+	var b strings.Builder
+
+	scope := "active"
+	if r.IncludeDeregistered {
+		scope = "active and deregistered"
+	}
+	fmt.Fprintf(&b, "# Data quality report\n\n")
+	fmt.Fprintf(&b, "Generated %s over %d %s records.", r.GeneratedAt.Format(time.RFC3339), r.Total, scope)
+	if r.PreviousAt != nil {
+		fmt.Fprintf(&b, " Changes are relative to the report of %s.", r.PreviousAt.Format(time.RFC3339))
+	}
+	b.WriteString("\n\n## Checks\n\n")
+	b.WriteString("| Check | Records | Rate | Change |\n|---|---:|---:|---:|\n")
+	for _, c := range r.Checks {
+		fmt.Fprintf(&b, "| %s | %d | %s | %s |\n", c.Description, c.Count, percent(c.Rate), change(c.Count, c.Previous))
+	}
+
+	for _, c := range r.Checks {
+		if len(c.Examples) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n### %s\n\n", c.Description)
+		b.WriteString("| ID | NrRNAL | Value |\n|---:|---:|---|\n")
+		for _, ex := range c.Examples {
+			fmt.Fprintf(&b, "| %d | %d | %s |\n", ex.ID, ex.NrRNAL, escapeCell(ex.Value))
+		}
+	}
+
+	fmt.Fprintf(&b, "\n## Capacity\n\nFirst quartile %g, third quartile %g, outlier fence %g.\n",
+		r.Capacity.Q1, r.Capacity.Q3, r.Capacity.UpperFence)
+
+	writeDuplicates(&b, "Most shared emails", r.DuplicateEmails)
+	writeDuplicates(&b, "Most shared addresses", r.DuplicateAddresses)
+
+	b.WriteString("\n## Completeness\n\n")
+	b.WriteString("| Column | Null or empty | Rate |\n|---|---:|---:|\n")
+	for _, c := range r.Completeness {
+		fmt.Fprintf(&b, "| %s | %d | %s |\n", c.Column, c.Empty, percent(c.Rate))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeDuplicates(b *strings.Builder, title string, groups []duplicateGroup) {
+	if len(groups) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n## %s\n\n", title)
+	b.WriteString("| Value | Records |\n|---|---:|\n")
+	for _, g := range groups {
+		fmt.Fprintf(b, "| %s | %d |\n", escapeCell(g.Value), g.Count)
+	}
+}
+
+func percent(rate float64) string {
+	return fmt.Sprintf("%.2f%%", rate*100)
+}
+
+// change formats the difference with the previous count, if there was one
+func change(count int, previous *int) string {
+	if previous == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%+d", count-*previous)
+}
+
+// escapeCell keeps a value from breaking the Markdown table it is written to
+func escapeCell(value string) string {
+	value = strings.ReplaceAll(value, "|", "\\|")
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// loadPrevious fills in the counts of the last stored report with the same scope
+func (r *report) loadPrevious(db *sql.DB) error {
+	var data []byte
+	var createdAt time.Time
+	err := db.QueryRow(`
+		SELECT created_at, report
+		FROM data_quality_reports
+		WHERE include_deregistered = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	`, r.IncludeDeregistered).Scan(&createdAt, &data)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load previous report: %w", err)
+	}
+
+	var previous report
+	if err := json.Unmarshal(data, &previous); err != nil {
+		return fmt.Errorf("failed to decode previous report: %w", err)
+	}
+
+	counts := make(map[string]int, len(previous.Checks))
+	for _, c := range previous.Checks {
+		counts[c.Name] = c.Count
+	}
+	for i := range r.Checks {
+		if count, ok := counts[r.Checks[i].Name]; ok {
+			r.Checks[i].Previous = &count
+		}
+	}
+	r.PreviousAt = &createdAt
+
+	return nil
+}
+
+// save stores the report for later comparison
+func (r *report) save(db *sql.DB) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO data_quality_reports (created_at, include_deregistered, record_count, report)
+		VALUES ($1, $2, $3, $4)
+	`, r.GeneratedAt, r.IncludeDeregistered, r.Total, data)
+	if err != nil {
+		return fmt.Errorf("failed to store report: %w", err)
+	}
+	return nil
+}
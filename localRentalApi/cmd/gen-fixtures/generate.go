@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"localRental/pkg/divisions"
+)
+
+// Feature mirrors the importer's GeoJSON feature. Geometry is a pointer so that
+// features without a position can be written with a null geometry.
+type Feature struct {
+	Type       string     `json:"type"`
+	Properties Properties `json:"properties"`
+	Geometry   *Geometry  `json:"geometry"`
+}
+
+// Properties mirrors the properties read by the importer
+type Properties struct {
+	OBJECTID            int    `json:"OBJECTID"`
+	NrRNAL              int    `json:"NrRNAL"`
+	Denominacao         string `json:"Denominacao"`
+	DataRegisto         string `json:"DataRegisto"`
+	DataAberturaPublico string `json:"DataAberturaPublico"`
+	Modalidade          string `json:"Modalidade"`
+	NrUtentes           int    `json:"NrUtentes"`
+	Email               string `json:"Email"`
+	Endereco            string `json:"Endereco"`
+	CodigoPostal        string `json:"CodigoPostal"`
+	LOCALIDADE          string `json:"LOCALIDADE"`
+	LatLong             string `json:"LatLong"`
+	FiabilidadeGeo      string `json:"FiabilidadeGeo"`
+	Freguesia           string `json:"Freguesia"`
+	Concelho            string `json:"Concelho"`
+	Distrito            string `json:"Distrito"`
+	NUTSIII             string `json:"NUTSIII"`
+	NUTSII              string `json:"NUTSII"`
+	ERT                 string `json:"ERT"`
+	SeloCleanSafe       string `json:"SeloCleanSafe"`
+}
+
+type Geometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"` // [longitude, latitude]
+}
+
+// Defects the importer rejects; a feature gets at most one of them, so their
+// rates add up to the share of the output the importer should reject
+const (
+	defectInvalidRNAL        = "invalid_rnal"
+	defectMissingCoordinates = "missing_coordinates"
+	defectInvalidCoordinates = "invalid_coordinates"
+	defectInvalidPostalCode  = "invalid_postal_code"
+	defectInvalidEmail       = "invalid_email"
+	defectNegativeCapacity   = "negative_capacity"
+)
+
+// Quirks the importer accepts but handles specially; they are drawn
+// independently of each other and of the defects
+const (
+	quirkUnreliableGeo   = "unreliable_geo"
+	quirkMissingGeometry = "missing_geometry"
+	quirkLatLongMismatch = "latlong_mismatch"
+	quirkDuplicateRNAL   = "duplicate_rnal"
+)
+
+// defectOrder fixes the order defects are drawn in, so a seed always yields
+// the same output
+var defectOrder = []string{
+	defectInvalidRNAL, defectMissingCoordinates, defectInvalidCoordinates,
+	defectInvalidPostalCode, defectInvalidEmail, defectNegativeCapacity,
+}
+
+// rates holds the share of features given each defect or quirk
+type rates map[string]float64
+
+// generator builds features from the place and modalidade tables
+type generator struct {
+	rng   *rand.Rand
+	rates rates
+
+	placeWeights      []int
+	modalidadeWeights []int
+
+	objectID int
+	nrRNAL   int
+	// emitted holds the valid NrRNAL values written so far, which duplicates
+	// are drawn from
+	emitted []int
+	// counts tallies the defects and quirks written, for the summary
+	counts map[string]int
+}
+
+func newGenerator(seed uint64, r rates) *generator {
+	g := &generator{
+		rng:    rand.New(rand.NewPCG(seed, seed)),
+		rates:  r,
+		counts: map[string]int{},
+	}
+	for _, p := range places {
+		g.placeWeights = append(g.placeWeights, p.weight)
+	}
+	for _, m := range modalidades {
+		g.modalidadeWeights = append(g.modalidadeWeights, m.weight)
+	}
+	return g
+}
+
+// registrationStart and registrationEnd bound the generated DataRegisto
+var (
+	registrationStart = time.Date(2008, 1, 1, 0, 0, 0, 0, time.UTC)
+	registrationEnd   = time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+)
+
+// next returns the following feature
+func (g *generator) next() Feature {
+	p := places[g.pick(g.placeWeights)]
+	m := modalidades[g.pick(g.modalidadeWeights)]
+
+	g.objectID++
+	g.nrRNAL += 1 + g.rng.IntN(3)
+	nrRNAL := g.nrRNAL
+	duplicate := len(g.emitted) > 0 && g.chance(quirkDuplicateRNAL)
+	if duplicate {
+		nrRNAL = g.emitted[g.rng.IntN(len(g.emitted))]
+	}
+
+	name := g.choose(namePrefixes) + " " + g.choose(nameSuffixes)
+	if g.rng.IntN(3) == 0 {
+		name += " " + p.localidade
+	}
+
+	email := ""
+	if g.rng.IntN(20) != 0 {
+		local := strings.ReplaceAll(divisions.Normalize(name), " ", ".")
+		email = fmt.Sprintf("%s%d@%s", local, g.rng.IntN(100), g.choose(emailDomains))
+	}
+
+	endereco := fmt.Sprintf("%s %d", g.choose(streets), 1+g.rng.IntN(250))
+	if floor := g.choose(floors); floor != "" {
+		endereco += " " + floor
+	}
+
+	registo := registrationStart.Add(time.Duration(g.rng.Int64N(int64(registrationEnd.Sub(registrationStart)))))
+	registo = registo.Truncate(time.Second)
+	abertura := registo.Truncate(24*time.Hour).AddDate(0, 0, g.rng.IntN(90))
+
+	// Spread positions around the concelho seat, a few kilometres across
+	lat := p.lat + g.rng.NormFloat64()*0.015
+	lng := p.lng + g.rng.NormFloat64()*0.015/math.Cos(p.lat*math.Pi/180)
+	lat, lng = round(lat, 7), round(lng, 7)
+
+	fiabilidade := "Fiavel"
+	if g.chance(quirkUnreliableGeo) {
+		fiabilidade = "NaoFiavel"
+	}
+
+	selo := "Não"
+	if g.rng.IntN(5) < 2 {
+		selo = "Sim"
+	}
+
+	f := Feature{
+		Type: "Feature",
+		Properties: Properties{
+			OBJECTID:            g.objectID,
+			NrRNAL:              nrRNAL,
+			Denominacao:         name,
+			DataRegisto:         registo.Format(time.RFC3339),
+			DataAberturaPublico: abertura.Format(time.RFC3339),
+			Modalidade:          m.name,
+			NrUtentes:           m.minUtentes + g.rng.IntN(m.maxUtentes-m.minUtentes+1),
+			Email:               email,
+			Endereco:            endereco,
+			CodigoPostal:        fmt.Sprintf("%s-%03d", p.cp4, 1+g.rng.IntN(999)),
+			LOCALIDADE:          p.localidade,
+			LatLong:             latLong(lat, lng),
+			FiabilidadeGeo:      fiabilidade,
+			Freguesia:           p.freguesia,
+			Concelho:            p.concelho,
+			Distrito:            p.distrito,
+			NUTSIII:             p.nutsIII,
+			NUTSII:              p.nutsII,
+			ERT:                 p.ert,
+			SeloCleanSafe:       selo,
+		},
+		Geometry: &Geometry{Type: "Point", Coordinates: []float64{lng, lat}},
+	}
+
+	if g.chance(quirkLatLongMismatch) {
+		// Move the LatLong property one to five kilometres off the geometry
+		offset := 0.01 + g.rng.Float64()*0.04
+		f.Properties.LatLong = latLong(round(lat+offset, 7), lng)
+	}
+	if g.chance(quirkMissingGeometry) {
+		f.Geometry = nil
+	}
+
+	g.injectDefect(&f)
+	if !duplicate && f.Properties.NrRNAL == nrRNAL {
+		g.emitted = append(g.emitted, nrRNAL)
+	}
+	return f
+}
+
+// injectDefect gives the feature at most one of the defects, with the
+// configured probabilities
+func (g *generator) injectDefect(f *Feature) {
+	u := g.rng.Float64()
+	for _, defect := range defectOrder {
+		if u >= g.rates[defect] {
+			u -= g.rates[defect]
+			continue
+		}
+
+		g.counts[defect]++
+		p := &f.Properties
+		switch defect {
+		case defectInvalidRNAL:
+			p.NrRNAL = -g.rng.IntN(2)
+		case defectMissingCoordinates:
+			f.Geometry = nil
+			p.LatLong = ""
+		case defectInvalidCoordinates:
+			// Out of range, as written by exports that mix up projected units
+			lat, lng := 91+g.rng.Float64()*90, -181-g.rng.Float64()*90
+			f.Geometry = &Geometry{Type: "Point", Coordinates: []float64{lng, lat}}
+			p.LatLong = latLong(lat, lng)
+		case defectInvalidPostalCode:
+			p.CodigoPostal = g.choose([]string{
+				p.CodigoPostal[:4],
+				strings.Replace(p.CodigoPostal, "-", " ", 1),
+				p.CodigoPostal + "0",
+				"CP " + p.CodigoPostal,
+			})
+		case defectInvalidEmail:
+			local := fmt.Sprintf("reservas%d", g.rng.IntN(1000))
+			p.Email = g.choose([]string{local, local + "@", "@" + g.choose(emailDomains), local + " @sapo.pt"})
+		case defectNegativeCapacity:
+			p.NrUtentes = -1 - g.rng.IntN(5)
+		}
+		return
+	}
+}
+
+// chance draws whether a feature gets the quirk, counting it when it does
+func (g *generator) chance(quirk string) bool {
+	rate := g.rates[quirk]
+	if rate <= 0 || g.rng.Float64() >= rate {
+		return false
+	}
+	g.counts[quirk]++
+	return true
+}
+
+// pick returns an index drawn with the given weights
+func (g *generator) pick(weights []int) int {
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	n := g.rng.IntN(total)
+	for i, w := range weights {
+		if n < w {
+			return i
+		}
+		n -= w
+	}
+	return len(weights) - 1
+}
+
+func (g *generator) choose(values []string) string {
+	return values[g.rng.IntN(len(values))]
+}
+
+// latLong formats a position as the LatLong property, "lat ; lng" with
+// Portuguese decimal commas
+func latLong(lat, lng float64) string {
+	format := func(v float64) string {
+		return strings.Replace(strconv.FormatFloat(v, 'f', -1, 64), ".", ",", 1)
+	}
+	return format(lat) + " ; " + format(lng)
+}
+
+func round(v float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(v*scale) / scale
+}
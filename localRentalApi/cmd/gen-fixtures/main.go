@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"sort"
+)
+
+// collectionHeader opens the FeatureCollection, named and tagged like the
+// published RNAL export
+const collectionHeader = `{
+"type": "FeatureCollection",
+"name": "Estabelecimentos_de_Alojamento_Local",
+"crs": { "type": "name", "properties": { "name": "urn:ogc:def:crs:OGC:1.3:CRS84" } },
+"features": [
+`
+
+func main() {
+	count := flag.Int("count", 1000, "Number of features to generate")
+	seed := flag.Uint64("seed", 1, "Random seed; the same seed and flags always produce the same file")
+	output := flag.String("output", "fixtures.geojson", "Output GeoJSON file (- for stdout)")
+
+	badRate := flag.Float64("bad-rate", 0, "Default rate of each rejected defect whose own flag is not set")
+	defectFlags := map[string]*float64{
+		defectInvalidRNAL:        flag.Float64("invalid-rnal", -1, "Rate of zero or negative NrRNAL"),
+		defectMissingCoordinates: flag.Float64("missing-coordinates", -1, "Rate of features without geometry or LatLong"),
+		defectInvalidCoordinates: flag.Float64("invalid-coordinates", -1, "Rate of out-of-range coordinates"),
+		defectInvalidPostalCode:  flag.Float64("invalid-postal-code", -1, "Rate of postal codes not in NNNN-NNN format"),
+		defectInvalidEmail:       flag.Float64("invalid-email", -1, "Rate of malformed emails"),
+		defectNegativeCapacity:   flag.Float64("negative-capacity", -1, "Rate of negative NrUtentes"),
+	}
+	quirkFlags := map[string]*float64{
+		quirkUnreliableGeo:   flag.Float64("unreliable-geo", 0.25, "Rate of FiabilidadeGeo NaoFiavel"),
+		quirkMissingGeometry: flag.Float64("missing-geometry", 0, "Rate of features without geometry but with a LatLong property"),
+		quirkLatLongMismatch: flag.Float64("latlong-mismatch", 0, "Rate of LatLong properties kilometres away from the geometry"),
+		quirkDuplicateRNAL:   flag.Float64("duplicate-rnal", 0, "Rate of features repeating an earlier NrRNAL"),
+	}
+	flag.Parse()
+
+	if *count < 0 {
+		log.Fatalf("-count must not be negative")
+	}
+
+	r := rates{}
+	rejected := 0.0
+	for name, rate := range defectFlags {
+		r[name] = *rate
+		if *rate < 0 {
+			r[name] = *badRate
+		}
+		rejected += r[name]
+	}
+	for name, rate := range quirkFlags {
+		r[name] = *rate
+	}
+	for name, rate := range r {
+		if rate < 0 || rate > 1 {
+			log.Fatalf("Rate of %s must be between 0 and 1, got %g", name, rate)
+		}
+	}
+	if rejected > 1 {
+		log.Fatalf("Defect rates add up to %g; a feature gets at most one defect, so they must not exceed 1", rejected)
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("Failed to create output: %v", err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	g := newGenerator(*seed, r)
+	if err := writeCollection(out, g, *count); err != nil {
+		log.Fatalf("Failed to write features: %v", err)
+	}
+
+	logSummary(g, *count, *output)
+}
+
+// writeCollection streams count features as a FeatureCollection, one per line
+func writeCollection(out io.Writer, g *generator, count int) error {
+	w := bufio.NewWriter(out)
+	if _, err := w.WriteString(collectionHeader); err != nil {
+		return err
+	}
+
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			if _, err := w.WriteString(",\n"); err != nil {
+				return err
+			}
+		}
+		data, err := json.Marshal(g.next())
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.WriteString("\n]\n}\n"); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// logSummary reports how many features got each defect and quirk, so tests
+// know the rejections and special cases to expect from the importer
+func logSummary(g *generator, count int, output string) {
+	log.Printf("Wrote %d features to %s", count, output)
+
+	names := make([]string, 0, len(g.counts))
+	for name := range g.counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rejected := 0
+	for _, defect := range defectOrder {
+		rejected += g.counts[defect]
+	}
+	for _, name := range names {
+		log.Printf("  %-20s: %d", name, g.counts[name])
+	}
+	log.Printf("  %-20s: %d", "expected rejections", rejected)
+}
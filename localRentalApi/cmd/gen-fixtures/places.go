@@ -0,0 +1,100 @@
+package main
+
+// place is a concelho records are spread around, with one of its freguesias,
+// the four-digit postal code prefix (CP4) of its seat and the regions it
+// belongs to. weight sets its share of the generated records.
+type place struct {
+	distrito, concelho, freguesia, localidade string
+	nutsIII, nutsII, ert                      string
+	lat, lng                                  float64
+	cp4                                       string
+	weight                                    int
+}
+
+// Turismo regions (ERT) as written in the RNAL data
+const (
+	ertNorte    = "Turismo do Porto e Norte de Portugal"
+	ertCentro   = "Turismo Centro de Portugal"
+	ertLisboa   = "Turismo de Lisboa"
+	ertAlentejo = "Turismo do Alentejo"
+	ertAlgarve  = "Região de Turismo do Algarve"
+	ertMadeira  = "Região Autónoma da Madeira"
+	ertAcores   = "Região Autónoma dos Açores"
+)
+
+// places covers every distrito and the main islands, weighted roughly like the
+// real register, where Lisboa, Porto and the Algarve hold most registrations
+var places = []place{
+	{"Aveiro", "Aveiro", "Glória e Vera Cruz", "Aveiro", "Região de Aveiro", "Centro", ertCentro, 40.6405, -8.6538, "3810", 3},
+	{"Aveiro", "Ílhavo", "Gafanha da Nazaré", "Gafanha da Nazaré", "Região de Aveiro", "Centro", ertCentro, 40.6350, -8.7130, "3830", 2},
+	{"Beja", "Beja", "Beja (Santiago Maior e São João Baptista)", "Beja", "Baixo Alentejo", "Alentejo", ertAlentejo, 38.0151, -7.8632, "7800", 1},
+	{"Beja", "Odemira", "Vila Nova de Milfontes", "Vila Nova de Milfontes", "Alentejo Litoral", "Alentejo", ertAlentejo, 37.7250, -8.7830, "7645", 2},
+	{"Braga", "Braga", "São Víctor", "Braga", "Cávado", "Norte", ertNorte, 41.5510, -8.4200, "4710", 3},
+	{"Braga", "Guimarães", "Oliveira, São Paio e São Sebastião", "Guimarães", "Ave", "Norte", ertNorte, 41.4430, -8.2960, "4810", 2},
+	{"Bragança", "Bragança", "Sé, Santa Maria e Meixedo", "Bragança", "Terras de Trás-os-Montes", "Norte", ertNorte, 41.8060, -6.7570, "5300", 1},
+	{"Castelo Branco", "Castelo Branco", "Castelo Branco", "Castelo Branco", "Beira Baixa", "Centro", ertCentro, 39.8220, -7.4910, "6000", 1},
+	{"Castelo Branco", "Covilhã", "Covilhã e Canhoso", "Covilhã", "Beiras e Serra da Estrela", "Centro", ertCentro, 40.2810, -7.5040, "6200", 1},
+	{"Coimbra", "Coimbra", "Coimbra (Sé Nova, Santa Cruz, Almedina e São Bartolomeu)", "Coimbra", "Região de Coimbra", "Centro", ertCentro, 40.2090, -8.4190, "3000", 3},
+	{"Coimbra", "Figueira da Foz", "Buarcos e São Julião", "Figueira da Foz", "Região de Coimbra", "Centro", ertCentro, 40.1510, -8.8610, "3080", 2},
+	{"Évora", "Évora", "Évora (São Mamede, Sé, São Pedro e Santo Antão)", "Évora", "Alentejo Central", "Alentejo", ertAlentejo, 38.5710, -7.9090, "7000", 2},
+	{"Faro", "Albufeira", "Albufeira e Olhos de Água", "Albufeira", "Algarve", "Algarve", ertAlgarve, 37.0890, -8.2500, "8200", 12},
+	{"Faro", "Lagos", "São Gonçalo de Lagos", "Lagos", "Algarve", "Algarve", ertAlgarve, 37.1020, -8.6730, "8600", 6},
+	{"Faro", "Loulé", "Quarteira", "Quarteira", "Algarve", "Algarve", ertAlgarve, 37.0690, -8.1000, "8125", 8},
+	{"Faro", "Olhão", "Quelfes", "Olhão", "Algarve", "Algarve", ertAlgarve, 37.0650, -7.8270, "8700", 3},
+	{"Faro", "Portimão", "Portimão", "Portimão", "Algarve", "Algarve", ertAlgarve, 37.1380, -8.5370, "8500", 6},
+	{"Guarda", "Guarda", "Guarda", "Guarda", "Beiras e Serra da Estrela", "Centro", ertCentro, 40.5370, -7.2670, "6300", 1},
+	{"Leiria", "Leiria", "Leiria, Pousos, Barreira e Cortes", "Leiria", "Região de Leiria", "Centro", ertCentro, 39.7440, -8.8070, "2400", 1},
+	{"Leiria", "Nazaré", "Nazaré", "Nazaré", "Oeste", "Centro", ertCentro, 39.6020, -9.0700, "2450", 2},
+	{"Leiria", "Peniche", "Peniche", "Peniche", "Oeste", "Centro", ertCentro, 39.3560, -9.3810, "2520", 2},
+	{"Lisboa", "Lisboa", "Santa Maria Maior", "Lisboa", "Área Metropolitana de Lisboa", "Área Metropolitana de Lisboa", ertLisboa, 38.7110, -9.1360, "1100", 12},
+	{"Lisboa", "Lisboa", "Misericórdia", "Lisboa", "Área Metropolitana de Lisboa", "Área Metropolitana de Lisboa", ertLisboa, 38.7110, -9.1470, "1200", 10},
+	{"Lisboa", "Lisboa", "Arroios", "Lisboa", "Área Metropolitana de Lisboa", "Área Metropolitana de Lisboa", ertLisboa, 38.7280, -9.1350, "1150", 6},
+	{"Lisboa", "Cascais", "Cascais e Estoril", "Cascais", "Área Metropolitana de Lisboa", "Área Metropolitana de Lisboa", ertLisboa, 38.6970, -9.4210, "2750", 4},
+	{"Lisboa", "Sintra", "Sintra (Santa Maria e São Miguel, São Martinho e São Pedro de Penaferrim)", "Sintra", "Área Metropolitana de Lisboa", "Área Metropolitana de Lisboa", ertLisboa, 38.8000, -9.3810, "2710", 2},
+	{"Lisboa", "Lourinhã", "Lourinhã e Atalaia", "Lourinhã", "Oeste", "Centro", ertCentro, 39.2420, -9.3120, "2530", 1},
+	{"Portalegre", "Portalegre", "Sé e São Lourenço", "Portalegre", "Alto Alentejo", "Alentejo", ertAlentejo, 39.2930, -7.4310, "7300", 1},
+	{"Portalegre", "Marvão", "Santa Maria de Marvão", "Marvão", "Alto Alentejo", "Alentejo", ertAlentejo, 39.3940, -7.3770, "7330", 1},
+	{"Porto", "Porto", "Cedofeita, Santo Ildefonso, Sé, Miragaia, São Nicolau e Vitória", "Porto", "Área Metropolitana do Porto", "Norte", ertNorte, 41.1470, -8.6110, "4050", 12},
+	{"Porto", "Vila Nova de Gaia", "Santa Marinha e São Pedro da Afurada", "Vila Nova de Gaia", "Área Metropolitana do Porto", "Norte", ertNorte, 41.1330, -8.6170, "4400", 4},
+	{"Porto", "Matosinhos", "Matosinhos e Leça da Palmeira", "Matosinhos", "Área Metropolitana do Porto", "Norte", ertNorte, 41.1840, -8.6900, "4450", 2},
+	{"Santarém", "Santarém", "Santarém (Marvila), Santa Iria da Ribeira de Santarém, Santarém (São Salvador) e Santarém (São Nicolau)", "Santarém", "Lezíria do Tejo", "Alentejo", ertAlentejo, 39.2360, -8.6870, "2000", 1},
+	{"Santarém", "Tomar", "Tomar (São João Baptista) e Santa Maria dos Olivais", "Tomar", "Médio Tejo", "Centro", ertCentro, 39.6010, -8.4090, "2300", 1},
+	{"Setúbal", "Setúbal", "Setúbal (São Julião, Nossa Senhora da Anunciada e Santa Maria da Graça)", "Setúbal", "Área Metropolitana de Lisboa", "Área Metropolitana de Lisboa", ertLisboa, 38.5240, -8.8930, "2900", 2},
+	{"Setúbal", "Sesimbra", "Sesimbra (Castelo)", "Sesimbra", "Área Metropolitana de Lisboa", "Área Metropolitana de Lisboa", ertLisboa, 38.4440, -9.1010, "2970", 2},
+	{"Setúbal", "Grândola", "Carvalhal", "Carvalhal", "Alentejo Litoral", "Alentejo", ertAlentejo, 38.3030, -8.7720, "7570", 1},
+	{"Viana do Castelo", "Viana do Castelo", "Viana do Castelo (Santa Maria Maior e Monserrate) e Meadela", "Viana do Castelo", "Alto Minho", "Norte", ertNorte, 41.6940, -8.8320, "4900", 2},
+	{"Viana do Castelo", "Ponte de Lima", "Arca e Ponte de Lima", "Ponte de Lima", "Alto Minho", "Norte", ertNorte, 41.7670, -8.5840, "4990", 1},
+	{"Vila Real", "Vila Real", "Vila Real", "Vila Real", "Douro", "Norte", ertNorte, 41.3010, -7.7450, "5000", 1},
+	{"Vila Real", "Peso da Régua", "Peso da Régua e Godim", "Peso da Régua", "Douro", "Norte", ertNorte, 41.1630, -7.7890, "5050", 1},
+	{"Viseu", "Viseu", "Viseu", "Viseu", "Viseu Dão Lafões", "Centro", ertCentro, 40.6570, -7.9140, "3500", 1},
+	{"Viseu", "Lamego", "Lamego (Almacave e Sé)", "Lamego", "Douro", "Norte", ertNorte, 41.0970, -7.8100, "5100", 1},
+	{"Ilha da Madeira", "Funchal", "Sé", "Funchal", "Região Autónoma da Madeira", "Região Autónoma da Madeira", ertMadeira, 32.6480, -16.9080, "9000", 5},
+	{"Ilha da Madeira", "Santa Cruz", "Caniço", "Caniço", "Região Autónoma da Madeira", "Região Autónoma da Madeira", ertMadeira, 32.6500, -16.8380, "9125", 2},
+	{"Ilha de São Miguel", "Ponta Delgada", "Ponta Delgada (São Sebastião)", "Ponta Delgada", "Região Autónoma dos Açores", "Região Autónoma dos Açores", ertAcores, 37.7400, -25.6680, "9500", 3},
+	{"Ilha Terceira", "Angra do Heroísmo", "Angra do Heroísmo (Sé)", "Angra do Heroísmo", "Região Autónoma dos Açores", "Região Autónoma dos Açores", ertAcores, 38.6550, -27.2190, "9700", 1},
+}
+
+// modalidade is a registration type with its share of the register and the
+// usual range of guests
+type modalidade struct {
+	name                   string
+	weight                 int
+	minUtentes, maxUtentes int
+}
+
+// modalidades lists the Modalidade values of the RNAL data
+var modalidades = []modalidade{
+	{"Apartamento", 55, 2, 8},
+	{"Moradia", 33, 4, 16},
+	{"Estabelecimento de hospedagem", 8, 6, 40},
+	{"Quartos", 4, 1, 9},
+}
+
+// Words combined into names, streets and emails
+var (
+	namePrefixes = []string{"Casa", "Apartamento", "Vila", "Quinta", "Refúgio", "Estúdio", "Cantinho", "Solar", "Monte", "Terraço"}
+	nameSuffixes = []string{"do Mar", "da Sé", "Azul", "do Sol", "das Flores", "da Ribeira", "do Castelo", "da Praia", "Verde", "do Rio", "dos Avós", "da Serra"}
+	streets      = []string{"Rua Direita", "Rua da Liberdade", "Avenida da República", "Rua do Comércio", "Largo da Igreja", "Rua 25 de Abril", "Travessa do Poço", "Rua de Santo António", "Rua Nova", "Avenida Marginal"}
+	floors       = []string{"", "R/c", "1º Esq", "1º Dto", "2º Esq", "2º Dto", "3º", "4º Frente"}
+	emailDomains = []string{"gmail.com", "hotmail.com", "sapo.pt", "outlook.pt", "yahoo.com", "mail.telepac.pt"}
+)
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// arcgisPage is one page of an ArcGIS FeatureServer query with f=geojson.
+// Depending on the server version exceededTransferLimit is reported either at
+// the top level or inside the collection properties.
+type arcgisPage struct {
+	Features              []Feature `json:"features"`
+	ExceededTransferLimit bool      `json:"exceededTransferLimit"`
+	Properties            struct {
+		ExceededTransferLimit bool `json:"exceededTransferLimit"`
+	} `json:"properties"`
+	Error *arcgisError `json:"error"`
+}
+
+// arcgisError is the error object ArcGIS returns, often with an HTTP 200 status
+type arcgisError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// transientError marks a failure that is worth retrying
+type transientError struct {
+	err error
+}
+
+func (e transientError) Error() string { return e.err.Error() }
+func (e transientError) Unwrap() error { return e.err }
+
+// arcgisSource pages through an ArcGIS FeatureServer layer query using
+// resultOffset/resultRecordCount, holding only the current page in memory
+type arcgisSource struct {
+	client     *http.Client
+	queryURL   *url.URL
+	pageSize   int
+	maxRetries int
+	retryDelay time.Duration
+	checksum   io.Writer
+
+	offset int
+	page   []Feature
+	pos    int
+	done   bool
+}
+
+// newArcGISSource prepares a paged query against a FeatureServer layer. The URL
+// may point at the layer or at its /query endpoint; missing query parameters are
+// filled in so that every feature is returned as WGS84 GeoJSON in a stable order.
+// Each page body is written to checksum once it has been fetched successfully.
+func newArcGISSource(client *http.Client, rawURL string, pageSize int, checksum io.Writer) (*arcgisSource, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid FeatureServer URL: %w", err)
+	}
+
+	if !strings.HasSuffix(strings.TrimSuffix(u.Path, "/"), "/query") {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/query"
+	}
+
+	q := u.Query()
+	defaults := map[string]string{
+		"where":          "1=1",
+		"outFields":      "*",
+		"orderByFields":  "OBJECTID",
+		"returnGeometry": "true",
+		"outSR":          "4326",
+	}
+	for key, value := range defaults {
+		if q.Get(key) == "" {
+			q.Set(key, value)
+		}
+	}
+	q.Set("f", "geojson")
+	u.RawQuery = q.Encode()
+
+	if pageSize <= 0 {
+		return nil, errors.New("page size must be positive")
+	}
+
+	return &arcgisSource{
+		client:     client,
+		queryURL:   u,
+		pageSize:   pageSize,
+		maxRetries: 5,
+		retryDelay: time.Second,
+		checksum:   checksum,
+	}, nil
+}
+
+// Next returns the next feature, fetching further pages as needed
+func (s *arcgisSource) Next() (Feature, error) {
+	for s.pos >= len(s.page) {
+		if s.done {
+			return Feature{}, io.EOF
+		}
+		if err := s.fetchNextPage(); err != nil {
+			return Feature{}, err
+		}
+	}
+
+	feature := s.page[s.pos]
+	s.pos++
+	return feature, nil
+}
+
+func (s *arcgisSource) fetchNextPage() error {
+	u := *s.queryURL
+	q := u.Query()
+	q.Set("resultOffset", strconv.Itoa(s.offset))
+	q.Set("resultRecordCount", strconv.Itoa(s.pageSize))
+	u.RawQuery = q.Encode()
+
+	var body []byte
+	var page arcgisPage
+	var err error
+	for attempt := 0; ; attempt++ {
+		body, page, err = s.fetch(u.String())
+		if err == nil {
+			break
+		}
+
+		var transient transientError
+		if !errors.As(err, &transient) || attempt >= s.maxRetries {
+			return fmt.Errorf("failed to fetch features at offset %d: %w", s.offset, err)
+		}
+
+		delay := s.retryDelay << attempt
+		log.Printf("Warning: %v, retrying in %s (attempt %d/%d)", err, delay, attempt+1, s.maxRetries)
+		time.Sleep(delay)
+	}
+
+	if s.checksum != nil {
+		s.checksum.Write(body)
+	}
+
+	s.page = page.Features
+	s.pos = 0
+	s.offset += len(page.Features)
+
+	if len(page.Features) == 0 || !(page.ExceededTransferLimit || page.Properties.ExceededTransferLimit) {
+		s.done = true
+	}
+
+	log.Printf("Fetched %d features from FeatureServer (offset %d)", len(page.Features), s.offset)
+
+	return nil
+}
+
+// fetch requests one page. Network failures, throttling and server errors,
+// including ArcGIS error objects carrying those codes, are reported as transient.
+func (s *arcgisSource) fetch(pageURL string) ([]byte, arcgisPage, error) {
+	var page arcgisPage
+
+	resp, err := s.client.Get(pageURL)
+	if err != nil {
+		return nil, page, transientError{err}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, page, transientError{fmt.Errorf("failed to read response: %w", err)}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("unexpected status %s", resp.Status)
+		if isTransientStatus(resp.StatusCode) {
+			return nil, page, transientError{err}
+		}
+		return nil, page, err
+	}
+
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, page, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if page.Error != nil {
+		err := fmt.Errorf("FeatureServer error %d: %s", page.Error.Code, page.Error.Message)
+		if isTransientStatus(page.Error.Code) {
+			return nil, page, transientError{err}
+		}
+		return nil, page, err
+	}
+
+	return body, page, nil
+}
+
+func isTransientStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// drainArcGIS reads every feature from s and returns their NrRNAL values
+func drainArcGIS(t *testing.T, s *arcgisSource) ([]int, error) {
+	t.Helper()
+
+	var rnals []int
+	for {
+		feature, err := s.Next()
+		if err == io.EOF {
+			return rnals, nil
+		}
+		if err != nil {
+			return rnals, err
+		}
+		rnals = append(rnals, feature.Properties.NrRNAL)
+	}
+}
+
+// arcgisFeatures renders a page of point features with consecutive NrRNAL
+// values starting at first
+func arcgisFeatures(first, count int) string {
+	features := make([]string, count)
+	for i := range features {
+		features[i] = fmt.Sprintf(
+			`{"type":"Feature","properties":{"NrRNAL":%d},"geometry":{"type":"Point","coordinates":[-8.9,38.5]}}`,
+			first+i)
+	}
+	return strings.Join(features, ",")
+}
+
+func TestArcGISSourcePagesUntilTransferLimitClears(t *testing.T) {
+	const total = 5
+
+	var offsets []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/FeatureServer/0/query" || q.Get("f") != "geojson" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		offsets = append(offsets, q.Get("resultOffset"))
+
+		offset, _ := strconv.Atoi(q.Get("resultOffset"))
+		count, _ := strconv.Atoi(q.Get("resultRecordCount"))
+		count = min(count, total-offset)
+		more := offset+count < total
+
+		// Older servers flag the limit at the top level, newer ones in properties
+		if offset == 0 {
+			fmt.Fprintf(w, `{"type":"FeatureCollection","features":[%s],"exceededTransferLimit":%t}`,
+				arcgisFeatures(offset+1, count), more)
+			return
+		}
+		fmt.Fprintf(w, `{"type":"FeatureCollection","features":[%s],"properties":{"exceededTransferLimit":%t}}`,
+			arcgisFeatures(offset+1, count), more)
+	}))
+	defer server.Close()
+
+	var checksum bytes.Buffer
+	s, err := newArcGISSource(server.Client(), server.URL+"/FeatureServer/0", 2, &checksum)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rnals, err := drainArcGIS(t, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []int{1, 2, 3, 4, 5}; fmt.Sprint(rnals) != fmt.Sprint(want) {
+		t.Errorf("got features %v, want %v", rnals, want)
+	}
+	if want := []string{"0", "2", "4"}; fmt.Sprint(offsets) != fmt.Sprint(want) {
+		t.Errorf("requested offsets %v, want %v", offsets, want)
+	}
+	if checksum.Len() == 0 {
+		t.Error("page bodies were not written to the checksum")
+	}
+}
+
+func TestArcGISSourceRetriesTransientErrors(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, `{"type":"FeatureCollection","features":[%s]}`, arcgisFeatures(1, 2))
+	}))
+	defer server.Close()
+
+	var checksum bytes.Buffer
+	s, err := newArcGISSource(server.Client(), server.URL+"/FeatureServer/0/query", 10, &checksum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.retryDelay = 0
+
+	rnals, err := drainArcGIS(t, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rnals) != 2 {
+		t.Errorf("got %d features, want 2", len(rnals))
+	}
+	if requests != 2 {
+		t.Errorf("made %d requests, want 2", requests)
+	}
+	if strings.Contains(checksum.String(), "busy") {
+		t.Error("the failed response was written to the checksum")
+	}
+}
+
+func TestArcGISSourceErrorBody(t *testing.T) {
+	tests := []struct {
+		name         string
+		code         int
+		wantRequests int
+	}{
+		{"client error fails at once", 400, 1},
+		{"server error is retried", 500, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				// ArcGIS reports errors in the body of a 200 response
+				fmt.Fprintf(w, `{"error":{"code":%d,"message":"Invalid or missing input parameters."}}`, tt.code)
+			}))
+			defer server.Close()
+
+			s, err := newArcGISSource(server.Client(), server.URL+"/FeatureServer/0", 10, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.maxRetries = 2
+			s.retryDelay = 0
+
+			_, err = drainArcGIS(t, s)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if want := fmt.Sprintf("FeatureServer error %d: Invalid or missing input parameters.", tt.code); !strings.Contains(err.Error(), want) {
+				t.Errorf("error %q does not contain %q", err, want)
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("made %d requests, want %d", requests, tt.wantRequests)
+			}
+		})
+	}
+}
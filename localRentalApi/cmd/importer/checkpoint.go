@@ -0,0 +1,148 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// checkpoint records how far an import of a given input got. Every feature
+// up to and including LastIndex has been committed or rejected.
+type checkpoint struct {
+	SourceSHA256 string
+	Mode         string
+	LastIndex    int
+	SyncTime     sql.NullTime
+}
+
+// loadCheckpoint returns the checkpoint left for the input with this checksum,
+// or nil when there is none
+func loadCheckpoint(db *sql.DB, checksum string) (*checkpoint, error) {
+	cp := checkpoint{SourceSHA256: checksum}
+	err := db.QueryRow(`
+		SELECT mode, last_index, sync_time
+		FROM import_checkpoints
+		WHERE source_sha256 = $1
+	`, checksum).Scan(&cp.Mode, &cp.LastIndex, &cp.SyncTime)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+	return &cp, nil
+}
+
+// clearCheckpoint removes the checkpoint once the input has been fully imported
+func clearCheckpoint(db *sql.DB, checksum string) error {
+	if _, err := db.Exec("DELETE FROM import_checkpoints WHERE source_sha256 = $1", checksum); err != nil {
+		return fmt.Errorf("failed to clear checkpoint: %w", err)
+	}
+	return nil
+}
+
+// checkpointTracker turns the out-of-order completions of the import pipeline
+// into a checkpoint. The saved index only advances past features whose
+// predecessors are all done, so a resumed run never skips uncommitted work.
+type checkpointTracker struct {
+	db         *sql.DB
+	sourceFile string
+	cp         checkpoint
+
+	mu   sync.Mutex
+	next int          // lowest index not yet known to be done
+	done map[int]bool // finished indices at or above next
+}
+
+func newCheckpointTracker(db *sql.DB, sourceFile, checksum, mode string, syncTime time.Time, start int) *checkpointTracker {
+	t := &checkpointTracker{
+		db:         db,
+		sourceFile: sourceFile,
+		cp: checkpoint{
+			SourceSHA256: checksum,
+			Mode:         mode,
+			LastIndex:    start - 1,
+		},
+		next: start,
+		done: make(map[int]bool),
+	}
+	if !syncTime.IsZero() {
+		t.cp.SyncTime = sql.NullTime{Time: syncTime, Valid: true}
+	}
+	return t
+}
+
+// complete marks features as finished without saving, as for rejected
+// features that are never written
+func (t *checkpointTracker) complete(indices ...int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.advance(indices)
+}
+
+// commit marks the features of a committed batch as finished and saves the
+// checkpoint if it moved
+func (t *checkpointTracker) commit(indices []int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.advance(indices)
+	if t.next-1 == t.cp.LastIndex {
+		return nil
+	}
+	t.cp.LastIndex = t.next - 1
+
+	_, err := t.db.Exec(`
+		INSERT INTO import_checkpoints (source_sha256, source_file, mode, last_index, sync_time, updated_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+		ON CONFLICT (source_sha256) DO UPDATE SET
+			source_file = EXCLUDED.source_file,
+			mode = EXCLUDED.mode,
+			last_index = EXCLUDED.last_index,
+			sync_time = EXCLUDED.sync_time,
+			updated_at = EXCLUDED.updated_at
+	`, t.cp.SourceSHA256, t.sourceFile, t.cp.Mode, t.cp.LastIndex, t.cp.SyncTime)
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
+}
+
+func (t *checkpointTracker) advance(indices []int) {
+	for _, i := range indices {
+		if i >= t.next {
+			t.done[i] = true
+		}
+	}
+	for t.done[t.next] {
+		delete(t.done, t.next)
+		t.next++
+	}
+}
+
+// resumeFrom applies the checkpoint left by an interrupted run of the same
+// input, returning how many features to skip. A sync run reuses the original
+// run timestamp so rows committed before the interruption are not taken as
+// missing.
+func resumeFrom(db *sql.DB, checksum string, opts *importOptions) (int, error) {
+	cp, err := loadCheckpoint(db, checksum)
+	if err != nil {
+		return 0, err
+	}
+	if cp == nil {
+		log.Println("No checkpoint found for this input, starting from the beginning")
+		return 0, nil
+	}
+
+	if cp.Mode != opts.Mode() {
+		return 0, fmt.Errorf("checkpoint was written by a %s run, cannot resume in %s mode", cp.Mode, opts.Mode())
+	}
+	if opts.Sync && cp.SyncTime.Valid {
+		opts.syncTime = cp.SyncTime.Time
+	}
+
+	log.Printf("Resuming after feature %d", cp.LastIndex)
+	return cp.LastIndex + 1, nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// checkpointStore stands in for the import_checkpoints table: it records the
+// last_index of every save and answers loads with a fixed row
+type checkpointStore struct {
+	mu    sync.Mutex
+	saved []int64
+	row   []driver.Value // mode, last_index, sync_time; nil for no checkpoint
+}
+
+var (
+	checkpointStoresMu sync.Mutex
+	checkpointStores   = map[string]*checkpointStore{}
+)
+
+func init() {
+	sql.Register("checkpointstore", checkpointDriver{})
+}
+
+// openCheckpointStore returns a database backed by a fresh checkpointStore
+func openCheckpointStore(t *testing.T, row []driver.Value) (*sql.DB, *checkpointStore) {
+	t.Helper()
+
+	store := &checkpointStore{row: row}
+	checkpointStoresMu.Lock()
+	checkpointStores[t.Name()] = store
+	checkpointStoresMu.Unlock()
+
+	db, err := sql.Open("checkpointstore", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, store
+}
+
+type checkpointDriver struct{}
+
+func (checkpointDriver) Open(name string) (driver.Conn, error) {
+	checkpointStoresMu.Lock()
+	defer checkpointStoresMu.Unlock()
+	return checkpointConn{checkpointStores[name]}, nil
+}
+
+type checkpointConn struct{ store *checkpointStore }
+
+func (c checkpointConn) Prepare(string) (driver.Stmt, error) { return checkpointStmt(c), nil }
+func (checkpointConn) Close() error                          { return nil }
+func (checkpointConn) Begin() (driver.Tx, error)             { return nil, errors.New("not supported") }
+
+type checkpointStmt struct{ store *checkpointStore }
+
+func (checkpointStmt) Close() error  { return nil }
+func (checkpointStmt) NumInput() int { return -1 }
+
+func (s checkpointStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	// source_sha256, source_file, mode, last_index, sync_time
+	s.store.saved = append(s.store.saved, args[3].(int64))
+	return driver.RowsAffected(1), nil
+}
+
+func (s checkpointStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &checkpointRows{row: s.store.row}, nil
+}
+
+type checkpointRows struct{ row []driver.Value }
+
+func (*checkpointRows) Columns() []string { return []string{"mode", "last_index", "sync_time"} }
+func (*checkpointRows) Close() error      { return nil }
+
+func (r *checkpointRows) Next(dest []driver.Value) error {
+	if r.row == nil {
+		return io.EOF
+	}
+	copy(dest, r.row)
+	r.row = nil
+	return nil
+}
+
+func TestCheckpointTrackerAdvance(t *testing.T) {
+	tests := []struct {
+		name     string
+		start    int
+		batches  [][]int
+		wantNext []int // next after each batch
+	}{
+		{"in order", 0, [][]int{{0, 1, 2}, {3, 4}}, []int{3, 5}},
+		{"out of order", 0, [][]int{{2, 3}, {5}, {0}, {1}, {4}}, []int{0, 0, 1, 4, 6}},
+		{"within a batch", 0, [][]int{{3, 1, 2, 0}}, []int{4}},
+		{"resumed", 100, [][]int{{101}, {100}, {102}}, []int{100, 102, 103}},
+		{"already passed", 10, [][]int{{3, 9, 10}, {10, 11}}, []int{11, 12}},
+		{"duplicates", 0, [][]int{{1, 1}, {0, 0}, {1}}, []int{0, 2, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newCheckpointTracker(nil, "in.geojson", "abc", "insert", time.Time{}, tt.start)
+			for i, batch := range tt.batches {
+				tracker.advance(batch)
+				if tracker.next != tt.wantNext[i] {
+					t.Errorf("after %v: next = %d, want %d", batch, tracker.next, tt.wantNext[i])
+				}
+			}
+			for i := range tracker.done {
+				if i < tracker.next {
+					t.Errorf("index %d below next %d still pending", i, tracker.next)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckpointTrackerCommit(t *testing.T) {
+	tests := []struct {
+		name      string
+		start     int
+		rejected  []int   // reported through complete before the commits
+		batches   [][]int // committed in this order
+		wantSaved []int64
+	}{
+		// A batch finishing ahead of an earlier one saves nothing until the gap closes
+		{"out of order", 0, nil, [][]int{{3, 4}, {0, 1}, {2}}, []int64{1, 4}},
+		{"rejected fill the gap", 0, []int{2}, [][]int{{3, 4}, {0, 1}}, []int64{4}},
+		{"rejected at the start", 0, []int{0, 1}, [][]int{{2}}, []int64{2}},
+		{"resumed", 50, nil, [][]int{{50, 51}, {52}}, []int64{51, 52}},
+		// Committing indices the checkpoint already covers does not save again
+		{"already passed", 50, nil, [][]int{{10, 49}, {50}, {50}}, []int64{50}},
+		{"nothing committed", 0, nil, [][]int{{}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, store := openCheckpointStore(t, nil)
+			tracker := newCheckpointTracker(db, "in.geojson", "abc", "insert", time.Time{}, tt.start)
+
+			tracker.complete(tt.rejected...)
+			for _, batch := range tt.batches {
+				if err := tracker.commit(batch); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !reflect.DeepEqual(store.saved, tt.wantSaved) {
+				t.Errorf("saved last_index %v, want %v", store.saved, tt.wantSaved)
+			}
+		})
+	}
+}
+
+func TestResumeFrom(t *testing.T) {
+	runTime := time.Date(2024, 3, 1, 2, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		row          []driver.Value
+		opts         importOptions
+		want         int
+		wantErr      bool
+		wantSyncTime time.Time
+	}{
+		{"no checkpoint", nil, importOptions{}, 0, false, time.Time{}},
+		{"same mode", []driver.Value{"insert", int64(41), nil}, importOptions{}, 42, false, time.Time{}},
+		{"sync reuses the run time", []driver.Value{"insert+sync", int64(9), runTime}, importOptions{Sync: true}, 10, false, runTime},
+		{"different mode", []driver.Value{"insert", int64(41), nil}, importOptions{Bulk: true}, 0, true, time.Time{}},
+		{"sync resumed without -sync", []driver.Value{"insert+sync", int64(9), runTime}, importOptions{}, 0, true, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := openCheckpointStore(t, tt.row)
+
+			opts := tt.opts
+			got, err := resumeFrom(db, "abc", &opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resumeFrom error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resumeFrom = %d, want %d", got, tt.want)
+			}
+			if !opts.syncTime.Equal(tt.wantSyncTime) {
+				t.Errorf("sync time = %v, want %v", opts.syncTime, tt.wantSyncTime)
+			}
+		})
+	}
+}
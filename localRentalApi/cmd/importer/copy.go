@@ -0,0 +1,182 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// stagingTable receives COPY data before it is merged into alojamentos
+const stagingTable = "alojamentos_staging"
+
+// stagedIndexColumn holds the input position of each staged feature, which
+// breaks ties between listings of the same registration
+const stagedIndexColumn = "input_index"
+
+// stagedFileColumn holds the position of the input file each staged feature
+// was read from, so that merge outcomes can be counted per file
+const stagedFileColumn = "input_file"
+
+// bulkImportData streams every feature into a temporary staging table through
+// the COPY protocol and merges the staged rows into alojamentos in the same
+// transaction, so a reload either lands completely or not at all
+func bulkImportData(db *sql.DB, validated *validatingSource, opts importOptions) (importStats, error) {
+	start := time.Now()
+	var stats importStats
+
+	tx, err := db.Begin()
+	if err != nil {
+		return stats, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	columns := strings.Join(alojamentoColumns, ", ")
+
+	if stats.Total, err = stageFeatures(tx, validated, opts); err != nil {
+		return stats, err
+	}
+
+	// Check before merging, so that a failed run leaves nothing behind
+	if err := checkRejectRatio(importStats{
+		Total:    stats.Total + validated.rejected,
+		Rejected: validated.rejected,
+	}, opts.MaxRejectRatio); err != nil {
+		return stats, err
+	}
+
+	log.Printf("Staged %d records in %s, merging...", stats.Total, time.Since(start))
+
+	if opts.Sync {
+		// A source listing the same registration twice would make the upsert
+		// touch a row twice, so keep one staged row per nr_rnal: the highest
+		// OBJECTID, then the last listing, as a batched sync would leave it
+		err = countMerged(tx, fmt.Sprintf(`
+			WITH chosen AS (
+				SELECT DISTINCT ON (nr_rnal) * FROM %s ORDER BY nr_rnal, object_id DESC, %s DESC
+			), merged AS (
+				INSERT INTO alojamentos (%s, updated_at, last_seen_at)
+				SELECT %s, $1::timestamp, $1::timestamp
+				FROM chosen
+				%sRETURNING nr_rnal, %s
+			)
+		`, stagingTable, stagedIndexColumn, columns, columns, syncConflictClause(), syncReturning), &stats, opts.syncTime)
+	} else {
+		// The first listing of a registration wins, as in a batched import
+		err = countMerged(tx, fmt.Sprintf(`
+			WITH chosen AS (
+				SELECT DISTINCT ON (nr_rnal) * FROM %s ORDER BY nr_rnal, %s
+			), merged AS (
+				INSERT INTO alojamentos (%s)
+				SELECT %s FROM chosen
+				ON CONFLICT (nr_rnal) DO NOTHING
+				RETURNING nr_rnal, TRUE AS inserted, FALSE AS changed
+			)
+		`, stagingTable, stagedIndexColumn, columns, columns), &stats)
+	}
+	if err != nil {
+		return stats, fmt.Errorf("failed to merge staged records: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return stats, fmt.Errorf("failed to commit bulk load: %w", err)
+	}
+
+	return stats, nil
+}
+
+// countMerged runs a merge of the staging table and counts its outcome per
+// input file. The query is a WITH clause defining chosen, the staged row kept
+// for each registration, and merged, returning the nr_rnal, inserted and
+// changed flags of every row written. Staged rows that were not written were
+// duplicates or unchanged and count as skipped.
+func countMerged(tx *sql.Tx, with string, stats *importStats, args ...any) error {
+	rows, err := tx.Query(with+fmt.Sprintf(`
+		SELECT s.%[1]s,
+		       COUNT(m.nr_rnal) FILTER (WHERE m.inserted),
+		       COUNT(m.nr_rnal) FILTER (WHERE NOT m.inserted AND m.changed),
+		       COUNT(*)
+		FROM %[2]s s
+		LEFT JOIN chosen c ON c.%[3]s = s.%[3]s
+		LEFT JOIN merged m ON m.nr_rnal = c.nr_rnal
+		GROUP BY s.%[1]s
+	`, stagedFileColumn, stagingTable, stagedIndexColumn), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var file, imported, updated, staged int
+		if err := rows.Scan(&file, &imported, &updated, &staged); err != nil {
+			return err
+		}
+
+		counts := stats.file(file)
+		counts.Imported += imported
+		counts.Updated += updated
+		counts.Skipped += staged - imported - updated
+		stats.Imported += imported
+		stats.Updated += updated
+		stats.Skipped += staged - imported - updated
+	}
+	return rows.Err()
+}
+
+// stageFeatures creates the temporary staging table and copies every feature
+// from source into it. The table is dropped when the transaction ends.
+func stageFeatures(tx *sql.Tx, source featureSource, opts importOptions) (int, error) {
+	start := time.Now()
+	staged := 0
+
+	// The staging table copies the column types but not the id sequence or constraints
+	if _, err := tx.Exec(fmt.Sprintf(
+		"CREATE TEMP TABLE %s ON COMMIT DROP AS SELECT %s, NULL::integer AS %s, NULL::integer AS %s FROM alojamentos WITH NO DATA",
+		stagingTable, strings.Join(alojamentoColumns, ", "), stagedIndexColumn, stagedFileColumn,
+	)); err != nil {
+		return 0, fmt.Errorf("failed to create staging table: %w", err)
+	}
+
+	copyColumns := append(append([]string(nil), alojamentoColumns...), stagedIndexColumn, stagedFileColumn)
+	stmt, err := tx.Prepare(pq.CopyIn(stagingTable, copyColumns...))
+	if err != nil {
+		return 0, fmt.Errorf("failed to start COPY: %w", err)
+	}
+
+	for {
+		feature, err := source.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			stmt.Close()
+			return staged, fmt.Errorf("failed to read feature: %w", err)
+		}
+
+		if _, err := stmt.Exec(append(featureValues(feature, opts), staged, filePosition(source))...); err != nil {
+			stmt.Close()
+			return staged, fmt.Errorf("failed to copy record %d: %w", staged, err)
+		}
+		staged++
+
+		if staged%opts.BatchSize == 0 {
+			rate := float64(staged) / time.Since(start).Seconds()
+			log.Printf("Progress: %d records staged - %.0f records/sec", staged, rate)
+		}
+	}
+
+	// Flush buffered COPY data
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return staged, fmt.Errorf("failed to flush COPY: %w", err)
+	}
+	if err := stmt.Close(); err != nil {
+		return staged, fmt.Errorf("failed to finish COPY: %w", err)
+	}
+
+	return staged, nil
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"localRental/pkg/geo"
+	pkgValidator "localRental/pkg/validator"
+)
+
+// csvRow accumulates the mapped values of one CSV record
+type csvRow struct {
+	feature  Feature
+	lat, lng *float64
+}
+
+// csvSetter stores a raw CSV value into the row being built
+type csvSetter func(row *csvRow, value string) error
+
+// csvColumns maps alojamentos columns to the feature field they populate. The
+// latitude and longitude columns build the point geometry.
+var csvColumns = map[string]csvSetter{
+	"object_id":             intSetter(func(p *Properties) *int { return &p.OBJECTID }),
+	"nr_rnal":               intSetter(func(p *Properties) *int { return &p.NrRNAL }),
+	"denominacao":           stringSetter(func(p *Properties) *string { return &p.Denominacao }),
+	"data_registo":          stringSetter(func(p *Properties) *string { return &p.DataRegisto }),
+	"data_abertura_publico": stringSetter(func(p *Properties) *string { return &p.DataAberturaPublico }),
+	"modalidade":            stringSetter(func(p *Properties) *string { return &p.Modalidade }),
+	"nr_utentes":            intSetter(func(p *Properties) *int { return &p.NrUtentes }),
+	"email":                 stringSetter(func(p *Properties) *string { return &p.Email }),
+	"endereco":              stringSetter(func(p *Properties) *string { return &p.Endereco }),
+	"codigo_postal":         stringSetter(func(p *Properties) *string { return &p.CodigoPostal }),
+	"localidade":            stringSetter(func(p *Properties) *string { return &p.LOCALIDADE }),
+	"lat_long":              stringSetter(func(p *Properties) *string { return &p.LatLong }),
+	"fiabilidade_geo":       stringSetter(func(p *Properties) *string { return &p.FiabilidadeGeo }),
+	"freguesia":             stringSetter(func(p *Properties) *string { return &p.Freguesia }),
+	"concelho":              stringSetter(func(p *Properties) *string { return &p.Concelho }),
+	"distrito":              stringSetter(func(p *Properties) *string { return &p.Distrito }),
+	"nuts_iii":              stringSetter(func(p *Properties) *string { return &p.NUTSIII }),
+	"nuts_ii":               stringSetter(func(p *Properties) *string { return &p.NUTSII }),
+	"ert":                   stringSetter(func(p *Properties) *string { return &p.ERT }),
+	"selo_clean_safe":       stringSetter(func(p *Properties) *string { return &p.SeloCleanSafe }),
+	"latitude":              coordinateSetter(func(row *csvRow) **float64 { return &row.lat }),
+	"longitude":             coordinateSetter(func(row *csvRow) **float64 { return &row.lng }),
+}
+
+// defaultCSVMapping is used when no mapping file is given. It accepts the GeoJSON
+// property names; headers that already match a column name are always accepted.
+var defaultCSVMapping = map[string]string{
+	"OBJECTID":            "object_id",
+	"NrRNAL":              "nr_rnal",
+	"Denominacao":         "denominacao",
+	"DataRegisto":         "data_registo",
+	"DataAberturaPublico": "data_abertura_publico",
+	"Modalidade":          "modalidade",
+	"NrUtentes":           "nr_utentes",
+	"Email":               "email",
+	"Endereco":            "endereco",
+	"CodigoPostal":        "codigo_postal",
+	"LOCALIDADE":          "localidade",
+	"LatLong":             "lat_long",
+	"FiabilidadeGeo":      "fiabilidade_geo",
+	"Freguesia":           "freguesia",
+	"Concelho":            "concelho",
+	"Distrito":            "distrito",
+	"NUTSIII":             "nuts_iii",
+	"NUTSII":              "nuts_ii",
+	"ERT":                 "ert",
+	"SeloCleanSafe":       "selo_clean_safe",
+}
+
+// loadCSVMapping reads a JSON object mapping CSV headers to alojamentos columns
+func loadCSVMapping(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV mapping: %w", err)
+	}
+
+	var mapping map[string]string
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to parse CSV mapping: %w", err)
+	}
+
+	for header, column := range mapping {
+		if _, ok := csvColumns[column]; !ok {
+			return nil, fmt.Errorf("CSV mapping for %q: unknown column %q (known: %s)",
+				header, column, strings.Join(knownCSVColumns(), ", "))
+		}
+	}
+
+	return mapping, nil
+}
+
+func knownCSVColumns() []string {
+	columns := make([]string, 0, len(csvColumns))
+	for column := range csvColumns {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// csvDecoder turns each CSV record into a Feature so it can feed the same
+// insert pipeline as GeoJSON input
+type csvDecoder struct {
+	r       *csv.Reader
+	setters []csvSetter
+	// columns holds the alojamentos column each CSV column maps to, for parse errors
+	columns []string
+	line    int
+	// crs is the system the coordinate columns are converted from
+	crs geo.CRS
+}
+
+// newCSVDecoder reads the header row and resolves each header through the
+// mapping. A zero delimiter is detected from the header row. With a projected
+// crs, the longitude and latitude columns hold the easting and northing.
+func newCSVDecoder(r io.Reader, mapping map[string]string, delimiter rune, crs geo.CRS) (*csvDecoder, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+
+	if delimiter == 0 {
+		delimiter = detectDelimiter(br)
+	}
+
+	cr := csv.NewReader(br)
+	cr.Comma = delimiter
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	if mapping == nil {
+		mapping = defaultCSVMapping
+	}
+	lookup := make(map[string]string, len(mapping))
+	for h, column := range mapping {
+		lookup[normalizeHeader(h)] = column
+	}
+
+	d := &csvDecoder{r: cr, setters: make([]csvSetter, len(header)), columns: make([]string, len(header)), line: 1, crs: crs}
+	mapped := 0
+	for i, h := range header {
+		h = normalizeHeader(h)
+		column, ok := lookup[h]
+		if !ok {
+			column = h
+		}
+		if setter, ok := csvColumns[column]; ok {
+			d.setters[i] = setter
+			d.columns[i] = column
+			mapped++
+		}
+	}
+
+	if mapped == 0 {
+		return nil, errors.New("no CSV headers match the column mapping")
+	}
+
+	return d, nil
+}
+
+// Next converts the next CSV record into a feature. A value that does not
+// parse is left unset and recorded on the feature, so validation rejects the
+// row; only records the CSV reader cannot split are an error.
+func (d *csvDecoder) Next() (Feature, error) {
+	record, err := d.r.Read()
+	if err == io.EOF {
+		return Feature{}, io.EOF
+	}
+	d.line++
+	if err != nil {
+		return Feature{}, fmt.Errorf("failed to read CSV line %d: %w", d.line, err)
+	}
+
+	return d.convert(record), nil
+}
+
+// convert maps the values of one record onto a feature
+func (d *csvDecoder) convert(record []string) Feature {
+	row := csvRow{feature: Feature{Type: "Feature"}}
+	for i, value := range record {
+		if i >= len(d.setters) || d.setters[i] == nil {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if err := d.setters[i](&row, value); err != nil {
+			row.feature.parseErrors = append(row.feature.parseErrors, pkgValidator.FieldError{
+				Field:   d.columns[i],
+				Rule:    "parse",
+				Message: fmt.Sprintf("CSV line %d, column %d: %v", d.line, i+1, err),
+			})
+		}
+	}
+
+	if row.lat != nil && row.lng != nil {
+		row.feature.Geometry = Geometry{
+			Type:        "Point",
+			Coordinates: []float64{*row.lng, *row.lat},
+		}
+		reproject(&row.feature, d.crs)
+	}
+
+	return row.feature
+}
+
+// detectDelimiter picks ';' or ',' by counting them in the header row
+func detectDelimiter(br *bufio.Reader) rune {
+	peek, _ := br.Peek(br.Size())
+	if i := bytes.IndexByte(peek, '\n'); i >= 0 {
+		peek = peek[:i]
+	}
+
+	if bytes.Count(peek, []byte(";")) > bytes.Count(peek, []byte(",")) {
+		return ';'
+	}
+	return ','
+}
+
+// normalizeHeader trims whitespace and a UTF-8 byte order mark and lowercases
+// the header so that mapping lookups are case-insensitive
+func normalizeHeader(h string) string {
+	return strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+}
+
+func stringSetter(field func(p *Properties) *string) csvSetter {
+	return func(row *csvRow, value string) error {
+		*field(&row.feature.Properties) = value
+		return nil
+	}
+}
+
+func intSetter(field func(p *Properties) *int) csvSetter {
+	return func(row *csvRow, value string) error {
+		n, err := parseCSVInt(value)
+		if err != nil {
+			return err
+		}
+		*field(&row.feature.Properties) = n
+		return nil
+	}
+}
+
+func coordinateSetter(field func(row *csvRow) **float64) csvSetter {
+	return func(row *csvRow, value string) error {
+		f, err := parseDecimal(value)
+		if err != nil {
+			return err
+		}
+		*field(row) = &f
+		return nil
+	}
+}
+
+// parseCSVInt parses an integer, ignoring a registration suffix such as
+// "1234/AL" and thousands separators such as "12.345"
+func parseCSVInt(value string) (int, error) {
+	raw := value
+	if i := strings.IndexByte(value, '/'); i >= 0 {
+		value = value[:i]
+	}
+
+	digits, ok := ungroup(strings.TrimSpace(value))
+	if !ok {
+		return 0, fmt.Errorf("invalid integer %q", raw)
+	}
+	n, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer %q", raw)
+	}
+	return n, nil
+}
+
+// parseDecimal parses a number written with either a decimal point or a
+// Portuguese decimal comma, with optional thousands separators as in
+// "-87 277,686" or "1.234.567,5". The last mark is the decimal separator
+// unless it repeats, so "1.234" reads as a decimal and "1.234.567" as an
+// integer.
+func parseDecimal(value string) (float64, error) {
+	raw := value
+	value = strings.TrimSpace(value)
+
+	whole, fraction := value, ""
+	if i := strings.LastIndexAny(value, ".,"); i >= 0 && strings.Count(value, value[i:i+1]) == 1 {
+		whole, fraction = value[:i], value[i+1:]
+	}
+
+	digits, ok := ungroup(whole)
+	if !ok {
+		return 0, fmt.Errorf("invalid number %q", raw)
+	}
+	if fraction != "" {
+		digits += "." + fraction
+	}
+
+	f, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", raw)
+	}
+	return f, nil
+}
+
+// ungroup removes the thousands separators ('.', ',' or a space) from the
+// integer part of a number, reporting false when the digits between them are
+// not groups of three
+func ungroup(value string) (string, bool) {
+	sign := ""
+	if strings.HasPrefix(value, "-") || strings.HasPrefix(value, "+") {
+		sign, value = value[:1], value[1:]
+	}
+
+	groups := strings.Split(strings.Map(func(r rune) rune {
+		if isThousandsSeparator(r) {
+			return ' '
+		}
+		return r
+	}, value), " ")
+	if len(groups) == 1 {
+		return sign + value, true
+	}
+	for i, g := range groups {
+		if g == "" || len(g) > 3 || (i > 0 && len(g) != 3) {
+			return "", false
+		}
+	}
+	return sign + strings.Join(groups, ""), true
+}
+
+func isThousandsSeparator(r rune) bool {
+	return r == '.' || r == ',' || r == ' ' || r == '\u00a0'
+}
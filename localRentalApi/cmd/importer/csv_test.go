@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+
+	"localRental/pkg/geo"
+)
+
+func TestParseCSVInt(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"1234", 1234, false},
+		{" 42 ", 42, false},
+		{"1234/AL", 1234, false},
+		{"12.345", 12345, false},
+		{"1 234 567", 1234567, false},
+		{"-1.000", -1000, false},
+		{"12.345/AL", 12345, false},
+		{"1.5", 0, true},
+		{"1.2345", 0, true},
+		{"1..234", 0, true},
+		{"abc", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseCSVInt(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCSVInt(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseCSVInt(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{"37.0657410006129", 37.0657410006129, false},
+		{"37,0657410006129", 37.0657410006129, false},
+		{" -7,82703800016578 ", -7.82703800016578, false},
+		{"-9", -9, false},
+		{"-87 277,686", -87277.686, false},
+		{"-87\u00a0277,686", -87277.686, false},
+		{"1.234.567,5", 1234567.5, false},
+		{"1,234,567.5", 1234567.5, false},
+		{"1.234.567", 1234567, false},
+		// A single mark is always the decimal separator
+		{"1.234", 1.234, false},
+		{"1,234", 1.234, false},
+		{"12,34,5", 0, true},
+		{"1.2.3,4", 0, true},
+		{"37,06,", 0, true},
+		{"north", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDecimal(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDecimal(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseDecimal(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDetectDelimiter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   rune
+	}{
+		{"NrRNAL;Denominacao;Latitude;Longitude\n1;A,B;1;2\n", ';'},
+		{"NrRNAL,Denominacao,Latitude,Longitude\n1,A;B,1,2\n", ','},
+		{"NrRNAL", ','},
+	}
+
+	for _, tt := range tests {
+		if got := detectDelimiter(bufio.NewReader(strings.NewReader(tt.header))); got != tt.want {
+			t.Errorf("detectDelimiter(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+// readCSV decodes every feature of a CSV document
+func readCSV(t *testing.T, input string, mapping map[string]string) []Feature {
+	t.Helper()
+
+	d, err := newCSVDecoder(strings.NewReader(input), mapping, 0, geo.WGS84)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var features []Feature
+	for {
+		feature, err := d.Next()
+		if err == io.EOF {
+			return features
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		features = append(features, feature)
+	}
+}
+
+func TestCSVDecoderDefaultMapping(t *testing.T) {
+	// A semicolon separated export with a byte order mark, mixing GeoJSON
+	// property names and column names in any case, and an unmapped column
+	input := "\ufeffNrRNAL;DENOMINACAO;nr_utentes;Latitude;Longitude;Notes\n" +
+		"1234/AL;Casa do Mar;4;37,0657410006129;-7,82703800016578;ignored\n"
+
+	features := readCSV(t, input, nil)
+	if len(features) != 1 {
+		t.Fatalf("got %d features, want 1", len(features))
+	}
+
+	f := features[0]
+	if f.Properties.NrRNAL != 1234 || f.Properties.Denominacao != "Casa do Mar" || f.Properties.NrUtentes != 4 {
+		t.Errorf("properties = %+v", f.Properties)
+	}
+	if f.Geometry.Type != "Point" || len(f.Geometry.Coordinates) != 2 ||
+		f.Geometry.Coordinates[0] != -7.82703800016578 || f.Geometry.Coordinates[1] != 37.0657410006129 {
+		t.Errorf("geometry = %+v", f.Geometry)
+	}
+}
+
+func TestCSVDecoderCustomMapping(t *testing.T) {
+	mapping := map[string]string{
+		"Registo": "nr_rnal",
+		"Nome":    "denominacao",
+		"Lat":     "latitude",
+	}
+	input := "registo,NOME,Lat\n" +
+		"77,\"Quinta, Lda\",38.7\n"
+
+	features := readCSV(t, input, mapping)
+	if len(features) != 1 {
+		t.Fatalf("got %d features, want 1", len(features))
+	}
+
+	f := features[0]
+	if f.Properties.NrRNAL != 77 || f.Properties.Denominacao != "Quinta, Lda" {
+		t.Errorf("properties = %+v", f.Properties)
+	}
+	// A latitude without a longitude builds no geometry
+	if f.Geometry.Type != "" {
+		t.Errorf("geometry = %+v, want none", f.Geometry)
+	}
+}
+
+func TestCSVDecoderNoMappedHeaders(t *testing.T) {
+	if _, err := newCSVDecoder(strings.NewReader("a;b;c\n1;2;3\n"), nil, 0, geo.WGS84); err == nil {
+		t.Fatal("expected an error for a header with no mapped columns")
+	}
+}
+
+func TestCSVDecoderRecordsParseErrors(t *testing.T) {
+	input := "NrRNAL;NrUtentes;Latitude;Longitude\n" +
+		"1;4;38,7;-9,1\n" +
+		"2;four;38,7;-9,1\n" +
+		"3;6;38,7;-9,1\n"
+
+	features := readCSV(t, input, nil)
+	if len(features) != 3 {
+		t.Fatalf("got %d features, want 3", len(features))
+	}
+
+	// The bad value is left unset and recorded, so validation rejects the row
+	// instead of the run failing
+	bad := features[1]
+	if bad.Properties.NrRNAL != 2 || bad.Properties.NrUtentes != 0 {
+		t.Errorf("properties = %+v", bad.Properties)
+	}
+	fieldErrors := validateFeature(bad, nil)
+	if len(fieldErrors) != 1 {
+		t.Fatalf("got %d field errors, want 1: %+v", len(fieldErrors), fieldErrors)
+	}
+	if e := fieldErrors[0]; e.Field != "nr_utentes" || e.Rule != "parse" || !strings.Contains(e.Message, "line 3, column 2") {
+		t.Errorf("field error = %+v", e)
+	}
+
+	for _, i := range []int{0, 2} {
+		if fieldErrors := validateFeature(features[i], nil); len(fieldErrors) != 0 {
+			t.Errorf("feature %d: unexpected field errors %+v", i, fieldErrors)
+		}
+	}
+}
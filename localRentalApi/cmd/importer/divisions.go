@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"localRental/pkg/divisions"
+)
+
+// divisionCodes holds the DICOFRE codes a record resolved to; unresolved
+// levels are nil
+type divisionCodes struct {
+	distrito, concelho, freguesia *string
+}
+
+// divisionResolver matches the free-text distrito, concelho and freguesia of a
+// record against the reference tables. It is read-only once loaded, so it can
+// be shared by the pipeline workers.
+type divisionResolver struct {
+	distritos map[string]string
+	// concelhos is keyed by distrito code and name key; concelhosByName lists
+	// every code sharing a name key, for records whose distrito did not resolve
+	concelhos       map[[2]string]string
+	concelhosByName map[string][]string
+	freguesias      map[[2]string]string
+
+	unresolved atomic.Int64
+}
+
+// loadDivisionResolver reads the reference tables into memory
+func loadDivisionResolver(db *sql.DB) (*divisionResolver, error) {
+	r := &divisionResolver{
+		distritos:       make(map[string]string),
+		concelhos:       make(map[[2]string]string),
+		concelhosByName: make(map[string][]string),
+		freguesias:      make(map[[2]string]string),
+	}
+
+	rows, err := db.Query("SELECT code, name_key FROM distritos")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load distritos: %w", err)
+	}
+	err = scanPairs(rows, func(code, key string) {
+		r.distritos[key] = code
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load distritos: %w", err)
+	}
+
+	rows, err = db.Query("SELECT code, name_key FROM concelhos")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load concelhos: %w", err)
+	}
+	err = scanPairs(rows, func(code, key string) {
+		r.concelhos[[2]string{code[:divisions.DistritoCodeLength], key}] = code
+		r.concelhosByName[key] = append(r.concelhosByName[key], code)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load concelhos: %w", err)
+	}
+
+	rows, err = db.Query("SELECT code, name_key FROM freguesias")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load freguesias: %w", err)
+	}
+	err = scanPairs(rows, func(code, key string) {
+		r.freguesias[[2]string{code[:divisions.ConcelhoCodeLength], key}] = code
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load freguesias: %w", err)
+	}
+
+	log.Printf("Loaded %d distritos, %d concelhos and %d freguesias",
+		len(r.distritos), len(r.concelhos), len(r.freguesias))
+	return r, nil
+}
+
+// scanPairs calls fn with the two text columns of every row and closes rows
+func scanPairs(rows *sql.Rows, fn func(a, b string)) error {
+	defer rows.Close()
+	for rows.Next() {
+		var a, b string
+		if err := rows.Scan(&a, &b); err != nil {
+			return err
+		}
+		fn(a, b)
+	}
+	return rows.Err()
+}
+
+// resolve returns the codes for a record's names. A concelho is looked up
+// within its distrito, or by name alone when the distrito did not resolve and
+// the name is unambiguous, in which case it also gives the distrito. A
+// freguesia is only looked up within a resolved concelho.
+func (r *divisionResolver) resolve(distrito, concelho, freguesia string) divisionCodes {
+	var codes divisionCodes
+	if r == nil {
+		return codes
+	}
+
+	if code, ok := r.distritos[divisions.Normalize(distrito)]; ok {
+		codes.distrito = &code
+	}
+
+	key := divisions.Normalize(concelho)
+	if codes.distrito != nil {
+		if code, ok := r.concelhos[[2]string{*codes.distrito, key}]; ok {
+			codes.concelho = &code
+		}
+	} else if matches := r.concelhosByName[key]; len(matches) == 1 {
+		code := matches[0]
+		parent := code[:divisions.DistritoCodeLength]
+		codes.concelho = &code
+		codes.distrito = &parent
+	}
+
+	if codes.concelho != nil {
+		if code, ok := r.freguesias[[2]string{*codes.concelho, divisions.Normalize(freguesia)}]; ok {
+			codes.freguesia = &code
+		}
+	}
+
+	unresolved := (distrito != "" && codes.distrito == nil) ||
+		(concelho != "" && codes.concelho == nil) ||
+		(freguesia != "" && codes.freguesia == nil)
+	if unresolved {
+		r.unresolved.Add(1)
+	}
+
+	return codes
+}
+
+// divisionRow is one freguesia of the reference list with its parents' names
+type divisionRow struct {
+	code                          string
+	distrito, concelho, freguesia string
+}
+
+// divisionHeaders maps the normalized headers of the reference list to the
+// field they fill. The official list names the code DICOFRE or DTMNFR and the
+// distrito column "Distrito/Ilha".
+var divisionHeaders = map[string]string{
+	"dicofre":       "code",
+	"dtmnfr":        "code",
+	"codigo":        "code",
+	"distrito":      "distrito",
+	"distrito/ilha": "distrito",
+	"ilha":          "distrito",
+	"concelho":      "concelho",
+	"municipio":     "concelho",
+	"freguesia":     "freguesia",
+}
+
+// readDivisions parses the reference list: one freguesia per row, with its
+// six-digit code and the names of the freguesia, concelho and distrito
+func readDivisions(r io.Reader) ([]divisionRow, error) {
+	br := bufio.NewReader(r)
+	cr := csv.NewReader(br)
+	cr.Comma = detectDelimiter(br)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	index := map[string]int{}
+	for i, h := range header {
+		if field, ok := divisionHeaders[divisions.Normalize(normalizeHeader(h))]; ok {
+			index[field] = i
+		}
+	}
+	for _, field := range []string{"code", "distrito", "concelho", "freguesia"} {
+		if _, ok := index[field]; !ok {
+			return nil, fmt.Errorf("missing %s column", field)
+		}
+	}
+
+	var result []divisionRow
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read line %d: %w", line, err)
+		}
+
+		field := func(name string) string {
+			if i := index[name]; i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row := divisionRow{
+			code:      field("code"),
+			distrito:  field("distrito"),
+			concelho:  field("concelho"),
+			freguesia: field("freguesia"),
+		}
+		// Spreadsheets drop the leading zero of codes in distritos 01 to 09
+		if len(row.code) == divisions.FreguesiaCodeLength-1 {
+			row.code = "0" + row.code
+		}
+		if !divisions.IsCode(row.code, divisions.FreguesiaCodeLength) {
+			return nil, fmt.Errorf("line %d: invalid freguesia code %q", line, row.code)
+		}
+		if row.distrito == "" || row.concelho == "" || row.freguesia == "" {
+			return nil, fmt.Errorf("line %d: missing division name", line)
+		}
+		result = append(result, row)
+	}
+
+	if len(result) == 0 {
+		return nil, errors.New("no divisions found")
+	}
+	return result, nil
+}
+
+// loadDivisions upserts the reference list into the distritos, concelhos and
+// freguesias tables. Divisions missing from the list are kept, since records
+// may still refer to them.
+func loadDivisions(db *sql.DB, rows []divisionRow) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	seen := map[string]bool{}
+	for _, row := range rows {
+		distrito := row.code[:divisions.DistritoCodeLength]
+		concelho := row.code[:divisions.ConcelhoCodeLength]
+
+		if !seen[distrito] {
+			seen[distrito] = true
+			if _, err := tx.Exec(`
+				INSERT INTO distritos (code, name, name_key) VALUES ($1, $2, $3)
+				ON CONFLICT (code) DO UPDATE SET name = EXCLUDED.name, name_key = EXCLUDED.name_key
+			`, distrito, row.distrito, divisions.Normalize(row.distrito)); err != nil {
+				return fmt.Errorf("failed to load distrito %s: %w", distrito, err)
+			}
+		}
+
+		if !seen[concelho] {
+			seen[concelho] = true
+			if _, err := tx.Exec(`
+				INSERT INTO concelhos (code, distrito_code, name, name_key) VALUES ($1, $2, $3, $4)
+				ON CONFLICT (code) DO UPDATE SET name = EXCLUDED.name, name_key = EXCLUDED.name_key
+			`, concelho, distrito, row.concelho, divisions.Normalize(row.concelho)); err != nil {
+				return fmt.Errorf("failed to load concelho %s: %w", concelho, err)
+			}
+		}
+
+		if _, err := tx.Exec(`
+			INSERT INTO freguesias (code, concelho_code, name, name_key) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (code) DO UPDATE SET name = EXCLUDED.name, name_key = EXCLUDED.name_key
+		`, row.code, concelho, row.freguesia, divisions.Normalize(row.freguesia)); err != nil {
+			return fmt.Errorf("failed to load freguesia %s: %w", row.code, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit divisions: %w", err)
+	}
+	return nil
+}
+
+// resolveExisting re-resolves the codes of every stored record, one distinct
+// combination of names at a time, and returns the number of records changed
+func resolveExisting(db *sql.DB, resolver *divisionResolver) (int, error) {
+	rows, err := db.Query(`
+		SELECT DISTINCT COALESCE(distrito, ''), COALESCE(concelho, ''), COALESCE(freguesia, '')
+		FROM alojamentos
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read record divisions: %w", err)
+	}
+	var names [][3]string
+	for rows.Next() {
+		var n [3]string
+		if err := rows.Scan(&n[0], &n[1], &n[2]); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to read record divisions: %w", err)
+		}
+		names = append(names, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read record divisions: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	changed := 0
+	for _, n := range names {
+		codes := resolver.resolve(n[0], n[1], n[2])
+		result, err := tx.Exec(`
+			UPDATE alojamentos
+			SET distrito_code = $4, concelho_code = $5, freguesia_code = $6
+			WHERE COALESCE(distrito, '') = $1 AND COALESCE(concelho, '') = $2 AND COALESCE(freguesia, '') = $3
+			  AND (distrito_code, concelho_code, freguesia_code) IS DISTINCT FROM ($4::varchar, $5::varchar, $6::varchar)
+		`, n[0], n[1], n[2], codes.distrito, codes.concelho, codes.freguesia)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update record divisions: %w", err)
+		}
+		affected, _ := result.RowsAffected()
+		changed += int(affected)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit record divisions: %w", err)
+	}
+	return changed, nil
+}
+
+// loadDivisionsCommand loads the official list of freguesias into the
+// reference tables and resolves the stored records against it
+func loadDivisionsCommand(args []string) {
+	fs := flag.NewFlagSet("divisions", flag.ExitOnError)
+	inputFile := fs.String("input", "freguesias.csv", "CSV list of freguesias with their DICOFRE code, concelho and distrito")
+	dbConn := fs.String("db", "postgres://localhost/alojamentos?sslmode=disable", "PostgreSQL connection string")
+	fs.Parse(args)
+
+	file, err := os.Open(*inputFile)
+	if err != nil {
+		log.Fatalf("Failed to open input: %v", err)
+	}
+	defer file.Close()
+
+	rows, err := readDivisions(file)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", *inputFile, err)
+	}
+
+	db, err := initDatabase(*dbConn)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	if err := loadDivisions(db, rows); err != nil {
+		log.Fatalf("Failed to load divisions: %v", err)
+	}
+
+	resolver, err := loadDivisionResolver(db)
+	if err != nil {
+		log.Fatalf("Failed to load divisions: %v", err)
+	}
+
+	changed, err := resolveExisting(db, resolver)
+	if err != nil {
+		log.Fatalf("Failed to resolve records: %v", err)
+	}
+
+	if err := writeHistory(db, time.Time{}); err != nil {
+		log.Fatalf("Resolved records, but failed to update history: %v", err)
+	}
+
+	log.Printf("Loaded %d freguesias; updated the division codes of %d records", len(rows), changed)
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// dryRunReport describes what an import would change, without changing anything
+type dryRunReport struct {
+	Source   string         `json:"source"`
+	Mode     string         `json:"mode"`
+	Total    int            `json:"total"`
+	Rejected int            `json:"rejected"`
+	Inserted []dryRunRecord `json:"inserted"`
+	Updated  []dryRunUpdate `json:"updated"`
+	Removed  []dryRunRecord `json:"removed"`
+}
+
+// dryRunRecord identifies a registration that would be inserted or removed
+type dryRunRecord struct {
+	ID          int    `json:"id,omitempty"`
+	NrRNAL      int    `json:"nr_rnal"`
+	Denominacao string `json:"denominacao"`
+}
+
+// dryRunUpdate lists the field-level changes a sync would apply to a row
+type dryRunUpdate struct {
+	ID      int           `json:"id"`
+	NrRNAL  int           `json:"nr_rnal"`
+	Changes []fieldChange `json:"changes"`
+}
+
+// fieldChange is one column whose value would change
+type fieldChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+// dryRun stages the validated features exactly as a bulk import would and
+// compares them with alojamentos. The transaction is always rolled back.
+// Updates and removals are reported in sync and swap modes; a plain or bulk
+// import without -sync leaves existing rows untouched. A swap without -sync
+// drops every row missing from the input, deregistered or not, while sync
+// deregisters the active ones.
+func dryRun(db *sql.DB, source featureSource, opts importOptions) (*dryRunReport, error) {
+	report := &dryRunReport{
+		Mode:     opts.Mode(),
+		Inserted: []dryRunRecord{},
+		Updated:  []dryRunUpdate{},
+		Removed:  []dryRunRecord{},
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	validated := newValidatingSource(source, opts.DeadLetter, opts.geocoder)
+	staged, err := stageFeatures(tx, validated, opts)
+	if err != nil {
+		return nil, err
+	}
+	report.Rejected = validated.rejected
+	report.Total = staged + validated.rejected
+
+	// One staged row per registration, as the sync merge does
+	latest := fmt.Sprintf(`(
+		SELECT DISTINCT ON (nr_rnal) * FROM %s ORDER BY nr_rnal, object_id DESC, %s DESC
+	)`, stagingTable, stagedIndexColumn)
+
+	if report.Inserted, err = dryRunRecords(tx, fmt.Sprintf(`
+		SELECT 0, s.nr_rnal, COALESCE(s.denominacao, '')
+		FROM %s s
+		WHERE NOT EXISTS (SELECT 1 FROM alojamentos a WHERE a.nr_rnal = s.nr_rnal)
+		ORDER BY s.nr_rnal
+	`, latest)); err != nil {
+		return nil, fmt.Errorf("failed to diff inserted records: %w", err)
+	}
+
+	if !opts.Sync && !opts.Swap {
+		return report, nil
+	}
+
+	if report.Updated, err = dryRunUpdates(tx, latest); err != nil {
+		return nil, fmt.Errorf("failed to diff updated records: %w", err)
+	}
+
+	if !opts.Sync {
+		if report.Removed, err = dryRunRecords(tx, fmt.Sprintf(`
+			SELECT a.id, a.nr_rnal, COALESCE(a.denominacao, '')
+			FROM alojamentos a
+			WHERE NOT EXISTS (SELECT 1 FROM %s s WHERE s.nr_rnal = a.nr_rnal)
+			ORDER BY a.nr_rnal
+		`, stagingTable)); err != nil {
+			return nil, fmt.Errorf("failed to diff removed records: %w", err)
+		}
+		return report, nil
+	}
+
+	keep := validated.rejectedRNAL
+	if keep == nil {
+		keep = []int{}
+	}
+
+	if report.Removed, err = dryRunRecords(tx, fmt.Sprintf(`
+		SELECT a.id, a.nr_rnal, COALESCE(a.denominacao, '')
+		FROM alojamentos a
+		WHERE a.deregistered_at IS NULL
+		  AND NOT EXISTS (SELECT 1 FROM %s s WHERE s.nr_rnal = a.nr_rnal)
+		  AND NOT (a.nr_rnal = ANY($1))
+		ORDER BY a.nr_rnal
+	`, stagingTable), pq.Array(keep)); err != nil {
+		return nil, fmt.Errorf("failed to diff removed records: %w", err)
+	}
+
+	return report, nil
+}
+
+func dryRunRecords(tx *sql.Tx, query string, args ...any) ([]dryRunRecord, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []dryRunRecord{}
+	for rows.Next() {
+		var r dryRunRecord
+		if err := rows.Scan(&r.ID, &r.NrRNAL, &r.Denominacao); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+
+	return records, rows.Err()
+}
+
+// dryRunUpdates selects every existing row whose tracked columns differ from
+// the staged values, or that would be re-listed, and diffs it column by column
+func dryRunUpdates(tx *sql.Tx, latest string) ([]dryRunUpdate, error) {
+	var tracked, incoming, current []string
+	for _, col := range alojamentoColumns {
+		if col == "nr_rnal" {
+			continue
+		}
+		tracked = append(tracked, col)
+		incoming = append(incoming, "s."+col)
+		current = append(current, "a."+col)
+	}
+
+	rows, err := tx.Query(fmt.Sprintf(`
+		SELECT a.id, a.nr_rnal, a.deregistered_at IS NOT NULL, %s, %s
+		FROM %s s
+		JOIN alojamentos a ON a.nr_rnal = s.nr_rnal
+		WHERE a.deregistered_at IS NOT NULL OR (%s) IS DISTINCT FROM (%s)
+		ORDER BY a.nr_rnal
+	`, strings.Join(current, ", "), strings.Join(incoming, ", "), latest,
+		strings.Join(current, ", "), strings.Join(incoming, ", ")))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	n := len(tracked)
+	updates := []dryRunUpdate{}
+	for rows.Next() {
+		var u dryRunUpdate
+		var deregistered bool
+		values := make([]any, 2*n)
+		dest := []any{&u.ID, &u.NrRNAL, &deregistered}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		if deregistered {
+			u.Changes = append(u.Changes, fieldChange{Field: "deregistered_at", Old: "set", New: nil})
+		}
+		for i, col := range tracked {
+			before, after := normalizeValue(values[i]), normalizeValue(values[n+i])
+			if !reflect.DeepEqual(before, after) {
+				u.Changes = append(u.Changes, fieldChange{Field: col, Old: before, New: after})
+			}
+		}
+		updates = append(updates, u)
+	}
+
+	return updates, rows.Err()
+}
+
+// normalizeValue makes driver values comparable and readable in JSON
+func normalizeValue(v any) any {
+	switch val := v.(type) {
+	case []byte:
+		return string(val)
+	case time.Time:
+		return val.UTC().Format(time.RFC3339)
+	default:
+		return val
+	}
+}
+
+// writeText prints a human-readable summary followed by every change
+func (r *dryRunReport) writeText(w io.Writer) {
+	fmt.Fprintf(w, "Dry run of %s (%s)\n", r.Source, r.Mode)
+	fmt.Fprintf(w, "  Records: %d (rejected: %d)\n", r.Total, r.Rejected)
+	fmt.Fprintf(w, "  Would insert: %d\n", len(r.Inserted))
+	if strings.HasSuffix(r.Mode, "+sync") || strings.HasPrefix(r.Mode, "swap") {
+		fmt.Fprintf(w, "  Would update: %d\n", len(r.Updated))
+		fmt.Fprintf(w, "  Would remove: %d\n", len(r.Removed))
+	}
+
+	for _, rec := range r.Inserted {
+		fmt.Fprintf(w, "+ %d %s\n", rec.NrRNAL, rec.Denominacao)
+	}
+	for _, u := range r.Updated {
+		fmt.Fprintf(w, "~ %d (id %d)\n", u.NrRNAL, u.ID)
+		for _, c := range u.Changes {
+			fmt.Fprintf(w, "    %s: %v -> %v\n", c.Field, c.Old, c.New)
+		}
+	}
+	for _, rec := range r.Removed {
+		fmt.Fprintf(w, "- %d %s (id %d)\n", rec.NrRNAL, rec.Denominacao, rec.ID)
+	}
+}
+
+// writeJSON writes the full report as JSON
+func (r *dryRunReport) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Geocode sources recorded in the geocode_source column
+const (
+	geocodeOriginal       = "original"
+	geocodePostalCentroid = "postal_centroid"
+)
+
+// unreliableGeo is the FiabilidadeGeo value of features whose position the
+// source itself does not trust
+const unreliableGeo = "NaoFiavel"
+
+// location is the position written for a feature. When the position comes
+// from a postal code centroid, originalLat and originalLng keep the source
+// coordinates, or are nil if the feature had none.
+type location struct {
+	lat, lng                 float64
+	source                   string
+	originalLat, originalLng *float64
+}
+
+// postalGeocoder places features on the centroid of their CP4-CP3 postal code
+// when their own coordinates are missing or flagged as unreliable. It is
+// read-only once loaded, so it can be shared by the pipeline workers.
+type postalGeocoder struct {
+	centroids map[string][2]float64
+
+	geocoded atomic.Int64
+	missing  atomic.Int64
+}
+
+// postalCentroidHeaders maps the normalized headers of the centroid file to
+// the field they fill. The postal code is either one column or split into its
+// four-digit (CP4) and three-digit (CP3) parts.
+var postalCentroidHeaders = map[string]string{
+	"codigo_postal": "code",
+	"codigopostal":  "code",
+	"cod_postal":    "code",
+	"postal_code":   "code",
+	"cp":            "code",
+	"cp4":           "cp4",
+	"cp3":           "cp3",
+	"latitude":      "lat",
+	"lat":           "lat",
+	"longitude":     "lng",
+	"lon":           "lng",
+	"lng":           "lng",
+}
+
+// loadPostalGeocoder reads the centroid reference file at path
+func loadPostalGeocoder(path string) (*postalGeocoder, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	centroids, err := readPostalCentroids(file)
+	if err != nil {
+		return nil, err
+	}
+	return &postalGeocoder{centroids: centroids}, nil
+}
+
+// readPostalCentroids parses a CSV of postal codes and their centroid in WGS84
+// latitude/longitude, keyed by the NNNN-NNN form of the code
+func readPostalCentroids(r io.Reader) (map[string][2]float64, error) {
+	br := bufio.NewReader(r)
+	cr := csv.NewReader(br)
+	cr.Comma = detectDelimiter(br)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	index := map[string]int{}
+	for i, h := range header {
+		if field, ok := postalCentroidHeaders[normalizeHeader(h)]; ok {
+			index[field] = i
+		}
+	}
+	_, hasCode := index["code"]
+	_, hasCP4 := index["cp4"]
+	_, hasCP3 := index["cp3"]
+	if !hasCode && !(hasCP4 && hasCP3) {
+		return nil, errors.New("missing postal code column (codigo_postal, or cp4 and cp3)")
+	}
+	for _, field := range []string{"lat", "lng"} {
+		if _, ok := index[field]; !ok {
+			return nil, fmt.Errorf("missing %s column", field)
+		}
+	}
+
+	centroids := map[string][2]float64{}
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read line %d: %w", line, err)
+		}
+
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		raw := field("code")
+		if !hasCode {
+			// Spreadsheets drop the leading zeros of CP3 values such as 001
+			cp3 := field("cp3")
+			if len(cp3) > 0 && len(cp3) < 3 {
+				cp3 = strings.Repeat("0", 3-len(cp3)) + cp3
+			}
+			raw = field("cp4") + "-" + cp3
+		}
+		code, ok := normalizePostalCode(raw)
+		if !ok {
+			return nil, fmt.Errorf("line %d: invalid postal code %q", line, raw)
+		}
+
+		lat, err := parseDecimal(field("lat"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		lng, err := parseDecimal(field("lng"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+			return nil, fmt.Errorf("line %d: centroid (%g, %g) is not a latitude/longitude", line, lat, lng)
+		}
+
+		centroids[code] = [2]float64{lat, lng}
+	}
+
+	if len(centroids) == 0 {
+		return nil, errors.New("no postal codes found")
+	}
+	return centroids, nil
+}
+
+// normalizePostalCode returns a postal code as NNNN-NNN, accepting the seven
+// digits without the hyphen as well
+func normalizePostalCode(value string) (string, bool) {
+	digits := strings.ReplaceAll(strings.TrimSpace(value), "-", "")
+	if len(digits) != 7 {
+		return "", false
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return "", false
+		}
+	}
+	return digits[:4] + "-" + digits[4:], true
+}
+
+// locate returns the position to store for a feature: its own coordinates,
+// or the centroid of its postal code when they are missing or unreliable and
+// the code is in the reference. count tallies the outcome for the summary and
+// is only set on the write path, so validation does not count features twice.
+func (g *postalGeocoder) locate(feature Feature, count bool) location {
+	lat, lng := featureCoordinates(feature)
+	loc := location{lat: lat, lng: lng, source: geocodeOriginal}
+
+	hasCoordinates := lat != 0 || lng != 0
+	unreliable := strings.EqualFold(strings.TrimSpace(feature.Properties.FiabilidadeGeo), unreliableGeo)
+	if g == nil || (hasCoordinates && !unreliable) {
+		return loc
+	}
+
+	code, _ := normalizePostalCode(feature.Properties.CodigoPostal)
+	centroid, ok := g.centroids[code]
+	if !ok {
+		if count {
+			g.missing.Add(1)
+		}
+		return loc
+	}
+
+	if hasCoordinates {
+		loc.originalLat, loc.originalLng = &lat, &lng
+	}
+	loc.lat, loc.lng = centroid[0], centroid[1]
+	loc.source = geocodePostalCentroid
+	if count {
+		g.geocoded.Add(1)
+	}
+	return loc
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"localRental/pkg/geo"
+	pkgValidator "localRental/pkg/validator"
+)
+
+// GeoJSON structure
+type Feature struct {
+	Type       string     `json:"type"`
+	Properties Properties `json:"properties"`
+	Geometry   Geometry   `json:"geometry"`
+	// parseErrors holds the fields a source could not parse, which get the
+	// feature rejected by validation
+	parseErrors []pkgValidator.FieldError
+}
+
+type Properties struct {
+	OBJECTID            int    `json:"OBJECTID"`
+	NrRNAL              int    `json:"NrRNAL"`
+	Denominacao         string `json:"Denominacao"`
+	DataRegisto         string `json:"DataRegisto"`
+	DataAberturaPublico string `json:"DataAberturaPublico"`
+	Modalidade          string `json:"Modalidade"`
+	NrUtentes           int    `json:"NrUtentes"`
+	Email               string `json:"Email"`
+	Endereco            string `json:"Endereco"`
+	CodigoPostal        string `json:"CodigoPostal"`
+	LOCALIDADE          string `json:"LOCALIDADE"`
+	LatLong             string `json:"LatLong"`
+	FiabilidadeGeo      string `json:"FiabilidadeGeo"`
+	Freguesia           string `json:"Freguesia"`
+	Concelho            string `json:"Concelho"`
+	Distrito            string `json:"Distrito"`
+	NUTSIII             string `json:"NUTSIII"`
+	NUTSII              string `json:"NUTSII"`
+	ERT                 string `json:"ERT"`
+	SeloCleanSafe       string `json:"SeloCleanSafe"`
+}
+
+type Geometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"` // [longitude, latitude]
+}
+
+// featureSource yields features one at a time and returns io.EOF when exhausted
+type featureSource interface {
+	Next() (Feature, error)
+}
+
+// geoJSONDecoder streams the features of a GeoJSON FeatureCollection through a
+// token-level decoder, so only one feature is held in memory at a time
+type geoJSONDecoder struct {
+	dec   *json.Decoder
+	name  string
+	count int
+	done  bool
+
+	// crs is the system coordinates are converted from; forced is set when it
+	// was given on the command line, which overrides the crs member
+	crs    geo.CRS
+	forced bool
+}
+
+// crsMember is the crs member of a GeoJSON 2008 collection, as still written
+// by GDAL for data that is not in CRS84
+type crsMember struct {
+	Type       string `json:"type"`
+	Properties struct {
+		Name string `json:"name"`
+		Code int    `json:"code"`
+	} `json:"properties"`
+}
+
+// parse resolves the member to a supported CRS
+func (m crsMember) parse() (geo.CRS, error) {
+	switch m.Type {
+	case "name":
+		return geo.ParseCRS(m.Properties.Name)
+	case "EPSG":
+		return geo.ParseCRS(fmt.Sprintf("EPSG:%d", m.Properties.Code))
+	default:
+		return geo.CRS{}, fmt.Errorf("unsupported crs type %q", m.Type)
+	}
+}
+
+// newGeoJSONDecoder reads the collection header up to the start of the
+// "features" array. Coordinates are converted from sourceCRS when it is set,
+// and otherwise from the collection's crs member, defaulting to CRS84.
+func newGeoJSONDecoder(r io.Reader, sourceCRS string) (*geoJSONDecoder, error) {
+	d := &geoJSONDecoder{
+		dec: json.NewDecoder(bufio.NewReaderSize(r, 1<<20)),
+		crs: geo.WGS84,
+	}
+
+	if sourceCRS != "" {
+		crs, err := geo.ParseCRS(sourceCRS)
+		if err != nil {
+			return nil, err
+		}
+		d.crs = crs
+		d.forced = true
+	}
+
+	if err := d.expectDelim('{'); err != nil {
+		return nil, fmt.Errorf("invalid FeatureCollection: %w", err)
+	}
+
+	for d.dec.More() {
+		key, err := d.readKey()
+		if err != nil {
+			return nil, err
+		}
+
+		switch key {
+		case "features":
+			if err := d.expectDelim('['); err != nil {
+				return nil, fmt.Errorf("invalid features array: %w", err)
+			}
+			return d, nil
+		case "name":
+			if err := d.dec.Decode(&d.name); err != nil {
+				return nil, fmt.Errorf("invalid collection name: %w", err)
+			}
+		case "crs":
+			crs, err := d.readCRS()
+			if err != nil {
+				return nil, err
+			}
+			if !d.forced {
+				d.crs = crs
+			}
+		default:
+			if err := d.skipValue(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return nil, errors.New("invalid FeatureCollection: missing features array")
+}
+
+// Name returns the collection name, if it appeared before the features array
+func (d *geoJSONDecoder) Name() string {
+	return d.name
+}
+
+// Next decodes the next feature from the stream
+func (d *geoJSONDecoder) Next() (Feature, error) {
+	var feature Feature
+
+	if d.done {
+		return feature, io.EOF
+	}
+
+	if !d.dec.More() {
+		if err := d.finish(); err != nil {
+			return feature, err
+		}
+		d.done = true
+		return feature, io.EOF
+	}
+
+	if err := d.dec.Decode(&feature); err != nil {
+		return feature, fmt.Errorf("failed to decode feature %d: %w", d.count, err)
+	}
+	d.count++
+
+	reproject(&feature, d.crs)
+
+	return feature, nil
+}
+
+// CRS returns the system coordinates are converted from
+func (d *geoJSONDecoder) CRS() geo.CRS {
+	return d.crs
+}
+
+// readCRS decodes a crs member. An unsupported system is an error rather than
+// a reason to write coordinates that are not WGS84.
+func (d *geoJSONDecoder) readCRS() (geo.CRS, error) {
+	var member crsMember
+	if err := d.dec.Decode(&member); err != nil {
+		return geo.CRS{}, fmt.Errorf("invalid crs member: %w", err)
+	}
+
+	crs, err := member.parse()
+	if err != nil {
+		if d.forced {
+			log.Printf("Warning: Ignoring the crs member in favour of -source-crs: %v", err)
+			return d.crs, nil
+		}
+		return geo.CRS{}, err
+	}
+	return crs, nil
+}
+
+// reproject converts the point geometry of feature from crs to WGS84 in place.
+// A (0,0) position marks a missing location and is left as is.
+func reproject(feature *Feature, crs geo.CRS) {
+	coords := feature.Geometry.Coordinates
+	if crs.IsWGS84() || len(coords) < 2 || (coords[0] == 0 && coords[1] == 0) {
+		return
+	}
+	coords[0], coords[1] = crs.ToWGS84(coords[0], coords[1])
+}
+
+// finish consumes the end of the features array and any members that follow it
+func (d *geoJSONDecoder) finish() error {
+	if err := d.expectDelim(']'); err != nil {
+		return fmt.Errorf("invalid features array: %w", err)
+	}
+
+	for d.dec.More() {
+		key, err := d.readKey()
+		if err != nil {
+			return err
+		}
+
+		// The features have already been converted, so a crs member that
+		// arrives too late to be applied must agree with the one used
+		if key == "crs" {
+			crs, err := d.readCRS()
+			if err != nil {
+				return err
+			}
+			if crs.Name != d.crs.Name {
+				return fmt.Errorf("crs member %s follows the features, which were read as %s; pass -source-crs", crs.Name, d.crs.Name)
+			}
+			continue
+		}
+
+		if err := d.skipValue(); err != nil {
+			return err
+		}
+	}
+
+	if err := d.expectDelim('}'); err != nil {
+		return fmt.Errorf("invalid FeatureCollection: %w", err)
+	}
+
+	return nil
+}
+
+func (d *geoJSONDecoder) readKey() (string, error) {
+	tok, err := d.dec.Token()
+	if err != nil {
+		return "", fmt.Errorf("failed to read object key: %w", err)
+	}
+
+	key, ok := tok.(string)
+	if !ok {
+		return "", fmt.Errorf("expected object key, got %v", tok)
+	}
+
+	return key, nil
+}
+
+func (d *geoJSONDecoder) skipValue() error {
+	var skip json.RawMessage
+	if err := d.dec.Decode(&skip); err != nil {
+		return fmt.Errorf("failed to skip value: %w", err)
+	}
+	return nil
+}
+
+func (d *geoJSONDecoder) expectDelim(want json.Delim) error {
+	tok, err := d.dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if delim, ok := tok.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("expected %q, got %v", want, tok)
+	}
+
+	return nil
+}
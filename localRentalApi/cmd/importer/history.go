@@ -0,0 +1,85 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// historyTable keeps every version of a registration, valid from valid_from
+// until valid_to (SCD type 2)
+const historyTable = "alojamentos_history"
+
+// recordHistory brings alojamentos_history in line with alojamentos. The open
+// version of every registration that changed, was deregistered or disappeared
+// is closed at runTime, and a version starting at runTime is opened for every
+// active registration without one. Comparing whole tables makes this work the
+// same after any write mode, including a swap or a rollback.
+func recordHistory(db *sql.DB, runTime time.Time) (closed, opened int, err error) {
+	live := "a." + strings.Join(alojamentoColumns, ", a.")
+	versioned := "h." + strings.Join(alojamentoColumns, ", h.")
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(fmt.Sprintf(`
+		UPDATE %s h SET valid_to = $1
+		WHERE h.valid_to IS NULL
+		  AND NOT EXISTS (
+			SELECT 1 FROM alojamentos a
+			WHERE a.id = h.alojamento_id
+			  AND a.deregistered_at IS NULL
+			  AND (%s) IS NOT DISTINCT FROM (%s)
+		  )
+	`, historyTable, live, versioned), runTime)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to close history versions: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	closed = int(n)
+
+	result, err = tx.Exec(fmt.Sprintf(`
+		INSERT INTO %s (alojamento_id, %s, created_at, valid_from)
+		SELECT a.id, %s, a.created_at, $1
+		FROM alojamentos a
+		WHERE a.deregistered_at IS NULL
+		  AND NOT EXISTS (
+			SELECT 1 FROM %s h WHERE h.alojamento_id = a.id AND h.valid_to IS NULL
+		  )
+	`, historyTable, strings.Join(alojamentoColumns, ", "), live, historyTable), runTime)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open history versions: %w", err)
+	}
+	n, _ = result.RowsAffected()
+	opened = int(n)
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit history: %w", err)
+	}
+
+	return closed, opened, nil
+}
+
+// writeHistory records history versions at runTime, or at the current database
+// time when runTime is zero
+func writeHistory(db *sql.DB, runTime time.Time) error {
+	if runTime.IsZero() {
+		var err error
+		if runTime, err = syncTimestamp(db); err != nil {
+			return err
+		}
+	}
+
+	closed, opened, err := recordHistory(db, runTime)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("History: closed %d versions, opened %d", closed, opened)
+	return nil
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// inputExtensions lists the files picked up from directories and zip archives
+var inputExtensions = map[string]bool{
+	".geojson": true,
+	".json":    true,
+	".csv":     true,
+}
+
+// inputEntry is one data file of the input: a plain or gzipped file, or a
+// member of a zip archive
+type inputEntry struct {
+	// name identifies the entry in logs and in the ledger, e.g. "bundle.zip:lisboa.geojson"
+	name   string
+	format string
+	open   func() (io.ReadCloser, error)
+}
+
+// expandInput resolves -input to the files it names: a single file, every
+// match of a glob pattern, or every data file below a directory, in name order
+func expandInput(pattern string) ([]string, error) {
+	if info, err := os.Stat(pattern); err == nil {
+		if !info.IsDir() {
+			return []string{pattern}, nil
+		}
+
+		var paths []string
+		err := filepath.WalkDir(pattern, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && isInputFile(p) {
+				paths = append(paths, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", pattern, err)
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no input files in %s", pattern)
+		}
+		return paths, nil
+	}
+
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input pattern %q: %w", pattern, err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no input files match %s", pattern)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// isInputFile reports whether name looks like a data file, possibly compressed
+func isInputFile(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	if ext == ".zip" {
+		return true
+	}
+	if ext == ".gz" {
+		ext = strings.ToLower(path.Ext(strings.TrimSuffix(name, path.Ext(name))))
+	}
+	return inputExtensions[ext]
+}
+
+// inputEntries lists the data files inside paths. Zip archives contribute
+// every data file they hold; format, when set, overrides the format detected
+// from each entry's name.
+func inputEntries(paths []string, format string) ([]inputEntry, error) {
+	var entries []inputEntry
+	for _, p := range paths {
+		if !strings.EqualFold(filepath.Ext(p), ".zip") {
+			entries = append(entries, fileEntry(p, format))
+			continue
+		}
+
+		members, err := zipEntries(p, format)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, members...)
+	}
+	return entries, nil
+}
+
+// fileEntry opens a file, decompressing it when its name ends in .gz
+func fileEntry(p, format string) inputEntry {
+	gzipped := strings.EqualFold(filepath.Ext(p), ".gz")
+	if format == "" {
+		name := p
+		if gzipped {
+			name = strings.TrimSuffix(p, filepath.Ext(p))
+		}
+		format = detectFormat(name)
+	}
+
+	return inputEntry{
+		name:   p,
+		format: format,
+		open: func() (io.ReadCloser, error) {
+			file, err := os.Open(p)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open file: %w", err)
+			}
+			if !gzipped {
+				return file, nil
+			}
+
+			gz, err := gzip.NewReader(file)
+			if err != nil {
+				file.Close()
+				return nil, fmt.Errorf("failed to read gzip %s: %w", p, err)
+			}
+			return readCloser{Reader: gz, close: func() error {
+				gz.Close()
+				return file.Close()
+			}}, nil
+		},
+	}
+}
+
+// zipEntries lists the data files of a zip archive, skipping the metadata
+// folders some archivers add
+func zipEntries(p, format string) ([]inputEntry, error) {
+	archive, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip %s: %w", p, err)
+	}
+	defer archive.Close()
+
+	var entries []inputEntry
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") || !isInputFile(f.Name) {
+			continue
+		}
+
+		member := f.Name
+		gzipped := strings.EqualFold(path.Ext(member), ".gz")
+		entryFormat := format
+		if entryFormat == "" {
+			name := member
+			if gzipped {
+				name = strings.TrimSuffix(member, path.Ext(member))
+			}
+			entryFormat = detectFormat(name)
+		}
+
+		entries = append(entries, inputEntry{
+			name:   p + ":" + member,
+			format: entryFormat,
+			open: func() (io.ReadCloser, error) {
+				zr, err := zip.OpenReader(p)
+				if err != nil {
+					return nil, fmt.Errorf("failed to open zip %s: %w", p, err)
+				}
+				r, err := zr.Open(member)
+				if err != nil {
+					zr.Close()
+					return nil, fmt.Errorf("failed to open %s in %s: %w", member, p, err)
+				}
+
+				var body io.Reader = r
+				var gz *gzip.Reader
+				if gzipped {
+					if gz, err = gzip.NewReader(r); err != nil {
+						r.Close()
+						zr.Close()
+						return nil, fmt.Errorf("failed to read gzip %s in %s: %w", member, p, err)
+					}
+					body = gz
+				}
+
+				return readCloser{Reader: body, close: func() error {
+					if gz != nil {
+						gz.Close()
+					}
+					r.Close()
+					return zr.Close()
+				}}, nil
+			},
+		})
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no input files in %s", p)
+	}
+	return entries, nil
+}
+
+// readCloser pairs a reader with the function releasing what backs it
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r readCloser) Close() error { return r.close() }
+
+// hashFiles returns the hex-encoded SHA-256 of the files at paths, read one
+// after the other. For a single file this is the file's own checksum.
+func hashFiles(paths []string) (string, error) {
+	h := sha256.New()
+	for _, p := range paths {
+		file, err := os.Open(p)
+		if err != nil {
+			return "", fmt.Errorf("failed to open file: %w", err)
+		}
+		_, err = io.Copy(h, file)
+		file.Close()
+		if err != nil {
+			return "", fmt.Errorf("failed to read file: %w", err)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fileCount holds the counters reported for one input entry. SHA256 covers
+// the decompressed content and is only set once the entry was read to the end.
+type fileCount struct {
+	Name     string
+	SHA256   string
+	Features int
+	Imported int
+	Updated  int
+	Skipped  int
+	Failed   int
+	Rejected int
+}
+
+// add accumulates the counters of o into f
+func (f *fileCount) add(o fileCount) {
+	f.Features += o.Features
+	f.Imported += o.Imported
+	f.Updated += o.Updated
+	f.Skipped += o.Skipped
+	f.Failed += o.Failed
+	f.Rejected += o.Rejected
+}
+
+// multiSource reads the features of every input entry in turn, as one stream
+// with a single run of feature indices
+type multiSource struct {
+	entries []inputEntry
+	cfg     sourceConfig
+
+	next    int
+	current featureSource
+	body    io.ReadCloser
+	hash    hash.Hash
+	files   []fileCount
+}
+
+func newMultiSource(entries []inputEntry, cfg sourceConfig) *multiSource {
+	return &multiSource{entries: entries, cfg: cfg}
+}
+
+// Next returns the next feature, opening the following entry when the current
+// one is exhausted
+func (m *multiSource) Next() (Feature, error) {
+	for {
+		if m.current == nil {
+			if m.next == len(m.entries) {
+				return Feature{}, io.EOF
+			}
+			if err := m.open(m.entries[m.next]); err != nil {
+				return Feature{}, err
+			}
+			m.next++
+		}
+
+		feature, err := m.current.Next()
+		if err == io.EOF {
+			if err := m.finish(); err != nil {
+				return Feature{}, err
+			}
+			continue
+		}
+		file := &m.files[len(m.files)-1]
+		if err != nil {
+			return feature, fmt.Errorf("%s: %w", file.Name, err)
+		}
+
+		file.Features++
+		return feature, nil
+	}
+}
+
+func (m *multiSource) open(entry inputEntry) error {
+	body, err := entry.open()
+	if err != nil {
+		return err
+	}
+
+	m.hash = sha256.New()
+	cfg := m.cfg
+	cfg.Format = entry.format
+	if len(m.entries) > 1 {
+		log.Printf("Reading %s", entry.name)
+	}
+
+	source, err := newFeatureSource(io.TeeReader(body, m.hash), cfg)
+	if err != nil {
+		body.Close()
+		return fmt.Errorf("%s: %w", entry.name, err)
+	}
+
+	m.body = body
+	m.current = source
+	m.files = append(m.files, fileCount{Name: entry.name})
+	return nil
+}
+
+// finish hashes whatever trails the decoded data and closes the entry
+func (m *multiSource) finish() error {
+	file := &m.files[len(m.files)-1]
+	_, err := io.Copy(m.hash, m.body)
+	m.body.Close()
+	m.current = nil
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", file.Name, err)
+	}
+
+	file.SHA256 = hex.EncodeToString(m.hash.Sum(nil))
+	return nil
+}
+
+// Close releases the entry being read, if any
+func (m *multiSource) Close() error {
+	if m.current == nil {
+		return nil
+	}
+	m.current = nil
+	return m.body.Close()
+}
+
+// report returns the name, checksum and feature count of every entry read,
+// with the outcomes counted per entry during the import
+func (m *multiSource) report(counted []fileCount) []fileCount {
+	files := append([]fileCount(nil), m.files...)
+	for i := range min(len(files), len(counted)) {
+		files[i].add(counted[i])
+	}
+	return files
+}
+
+// filePosition returns the position in the input of the file that the last
+// feature read from source came from. Sources other than file inputs count as
+// a single file.
+func filePosition(source featureSource) int {
+	if v, ok := source.(*validatingSource); ok {
+		source = v.source
+	}
+	if m, ok := source.(*multiSource); ok {
+		return len(m.files) - 1
+	}
+	return 0
+}
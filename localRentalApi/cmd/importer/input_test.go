@@ -0,0 +1,109 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMultiSourceCountsPerFile(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.csv", "NrRNAL;Latitude;Longitude\n1;38,7;-9,1\n2;38,7;-9,1\n")
+	write("b.csv", "NrRNAL;Latitude;Longitude\n3;38,7;-9,1\n")
+	write("notes.txt", "ignored")
+
+	file, err := os.Create(filepath.Join(dir, "c.csv.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(file)
+	gz.Write([]byte("NrRNAL;Latitude;Longitude\n4;38,7;-9,1\n5;0;0\n6;38,7;-9,1\n"))
+	gz.Close()
+	file.Close()
+
+	paths, err := expandInput(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := inputEntries(paths, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source := newMultiSource(entries, sourceConfig{})
+	defer source.Close()
+	validated := newValidatingSource(source, nil, nil)
+
+	// Count written rows as the pipeline does, by the file each came from
+	var stats importStats
+	positions := map[int]int{}
+	for {
+		feature, err := validated.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		positions[feature.Properties.NrRNAL] = filePosition(validated)
+		stats.imported(filePosition(validated))
+	}
+	for i, rejected := range validated.rejectedFiles {
+		stats.file(i).Rejected += rejected
+	}
+
+	wantPositions := map[int]int{1: 0, 2: 0, 3: 1, 4: 2, 6: 2}
+	if !reflect.DeepEqual(positions, wantPositions) {
+		t.Errorf("file positions = %v, want %v", positions, wantPositions)
+	}
+
+	files := source.report(stats.Files)
+	if len(files) != 3 {
+		t.Fatalf("got %d files, want 3", len(files))
+	}
+	want := []struct {
+		name                         string
+		features, imported, rejected int
+	}{
+		{"a.csv", 2, 2, 0},
+		{"b.csv", 1, 1, 0},
+		{"c.csv.gz", 3, 2, 1},
+	}
+	for i, w := range want {
+		f := files[i]
+		if filepath.Base(f.Name) != w.name || f.Features != w.features || f.Imported != w.imported || f.Rejected != w.rejected {
+			t.Errorf("file %d = %+v, want %s with %d features, %d imported, %d rejected",
+				i, f, w.name, w.features, w.imported, w.rejected)
+		}
+		if f.SHA256 == "" {
+			t.Errorf("file %d has no checksum", i)
+		}
+	}
+	if stats.Imported != 5 {
+		t.Errorf("imported = %d, want 5", stats.Imported)
+	}
+}
+
+func TestImportStatsAddMergesFiles(t *testing.T) {
+	var total importStats
+	total.add(importStats{Total: 2, Imported: 2, Files: []fileCount{{Imported: 2}}})
+	total.add(importStats{Total: 3, Imported: 1, Skipped: 2, Files: []fileCount{{}, {Imported: 1}, {Skipped: 2}}})
+
+	want := []fileCount{{Imported: 2}, {Imported: 1}, {Skipped: 2}}
+	if !reflect.DeepEqual(total.Files, want) {
+		t.Errorf("files = %+v, want %+v", total.Files, want)
+	}
+	if total.Total != 5 || total.Imported != 3 || total.Skipped != 2 {
+		t.Errorf("totals = %+v", total)
+	}
+}
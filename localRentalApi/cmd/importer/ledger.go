@@ -0,0 +1,110 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Import run statuses recorded in the import_runs ledger
+const (
+	runStatusRunning   = "running"
+	runStatusSucceeded = "succeeded"
+	runStatusFailed    = "failed"
+)
+
+// runModeRollback is the mode recorded for a rollback of a swap import
+const runModeRollback = "rollback"
+
+// startImportRun records the start of an import run and returns its id
+func startImportRun(db *sql.DB, sourceFile string, opts importOptions) (int, error) {
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO import_runs (source_file, mode, status)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, sourceFile, opts.Mode(), runStatusRunning).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record import run: %w", err)
+	}
+
+	return id, nil
+}
+
+// recordRollback records a rollback in the import_runs ledger. It changes the
+// data like an import does, so anything watching the ledger for new data sees
+// it too. A rollback reads no input, so its counts stay zero.
+func recordRollback(db *sql.DB, rollbackErr error) (int, error) {
+	status := runStatusSucceeded
+	var errMsg sql.NullString
+	if rollbackErr != nil {
+		status = runStatusFailed
+		errMsg = sql.NullString{String: rollbackErr.Error(), Valid: true}
+	}
+
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO import_runs (source_file, mode, status, error, finished_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+		RETURNING id
+	`, previousTable, runModeRollback, status, errMsg).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record rollback: %w", err)
+	}
+
+	return id, nil
+}
+
+// finishImportRun stores the outcome of an import run, with one row per input
+// file. The checksum is only recorded when the whole source was read.
+func finishImportRun(db *sql.DB, id int, checksum string, stats importStats, importErr error) error {
+	status := runStatusSucceeded
+	var digest, errMsg sql.NullString
+
+	if importErr != nil {
+		status = runStatusFailed
+		errMsg = sql.NullString{String: importErr.Error(), Valid: true}
+	} else {
+		digest = sql.NullString{String: checksum, Valid: true}
+	}
+
+	_, err := db.Exec(`
+		UPDATE import_runs SET
+			source_sha256 = $2,
+			feature_count = $3,
+			imported_count = $4,
+			updated_count = $5,
+			skipped_count = $6,
+			failed_count = $7,
+			rejected_count = $8,
+			deregistered_count = $9,
+			status = $10,
+			error = $11,
+			finished_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, id, digest, stats.Total, stats.Imported, stats.Updated, stats.Skipped,
+		stats.Failed, stats.Rejected, stats.Deregistered, status, errMsg)
+	if err != nil {
+		return fmt.Errorf("failed to update import run %d: %w", id, err)
+	}
+
+	for i, file := range stats.Files {
+		var fileDigest sql.NullString
+		if file.SHA256 != "" {
+			fileDigest = sql.NullString{String: file.SHA256, Valid: true}
+		}
+
+		_, err := db.Exec(`
+			INSERT INTO import_run_files (
+				run_id, position, source_file, source_sha256, feature_count,
+				imported_count, updated_count, skipped_count, failed_count, rejected_count
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		`, id, i+1, file.Name, fileDigest, file.Features,
+			file.Imported, file.Updated, file.Skipped, file.Failed, file.Rejected)
+		if err != nil {
+			return fmt.Errorf("failed to record files of import run %d: %w", id, err)
+		}
+	}
+
+	return nil
+}
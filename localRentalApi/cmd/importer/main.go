@@ -1,67 +1,81 @@
 package main
 
 import (
+	"bufio"
+	"crypto/sha256"
 	"database/sql"
-	"encoding/json"
+	"encoding/hex"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"strings"
+	"runtime"
 	"time"
 
+	"localRental/pkg/database"
+	"localRental/pkg/geo"
+
 	_ "github.com/lib/pq"
 )
 
-// GeoJSON structure
-type GeoJSONCollection struct {
-	Type     string    `json:"type"`
-	Name     string    `json:"name"`
-	Features []Feature `json:"features"`
-}
-
-type Feature struct {
-	Type       string     `json:"type"`
-	Properties Properties `json:"properties"`
-	Geometry   Geometry   `json:"geometry"`
-}
-
-type Properties struct {
-	OBJECTID            int    `json:"OBJECTID"`
-	NrRNAL              int    `json:"NrRNAL"`
-	Denominacao         string `json:"Denominacao"`
-	DataRegisto         string `json:"DataRegisto"`
-	DataAberturaPublico string `json:"DataAberturaPublico"`
-	Modalidade          string `json:"Modalidade"`
-	NrUtentes           int    `json:"NrUtentes"`
-	Email               string `json:"Email"`
-	Endereco            string `json:"Endereco"`
-	CodigoPostal        string `json:"CodigoPostal"`
-	LOCALIDADE          string `json:"LOCALIDADE"`
-	LatLong             string `json:"LatLong"`
-	FiabilidadeGeo      string `json:"FiabilidadeGeo"`
-	Freguesia           string `json:"Freguesia"`
-	Concelho            string `json:"Concelho"`
-	Distrito            string `json:"Distrito"`
-	NUTSIII             string `json:"NUTSIII"`
-	NUTSII              string `json:"NUTSII"`
-	ERT                 string `json:"ERT"`
-	SeloCleanSafe       string `json:"SeloCleanSafe"`
-}
-
-type Geometry struct {
-	Type        string    `json:"type"`
-	Coordinates []float64 `json:"coordinates"` // [longitude, latitude]
-}
-
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "rollback":
+			rollback(os.Args[2:])
+			return
+		case "divisions":
+			loadDivisionsCommand(os.Args[2:])
+			return
+		}
+	}
+
 	// Parse command-line flags
-	inputFile := flag.String("input", "aa.geojson", "Input GeoJSON file path")
+	inputFile := flag.String("input", "aa.geojson", "Input GeoJSON or CSV file, optionally .gz or .zip, or a directory or glob of them")
+	format := flag.String("format", "", "Input format: geojson or csv (default: from file extension)")
+	csvMapping := flag.String("csv-mapping", "", "JSON file mapping CSV headers to alojamentos columns")
+	csvDelimiter := flag.String("csv-delimiter", "", "CSV field delimiter (default: detected from the header)")
+	sourceCRS := flag.String("source-crs", "", "Coordinate reference system of the input, e.g. EPSG:3763 (default: the GeoJSON crs member, or CRS84)")
+	featureServer := flag.String("feature-server", "", "ArcGIS FeatureServer layer or query URL to fetch instead of -input")
+	pageSize := flag.Int("page-size", 1000, "Features requested per FeatureServer page")
+	maxRetries := flag.Int("max-retries", 5, "Retries for transient FeatureServer errors")
 	dbConn := flag.String("db", "postgres://localhost/alojamentos?sslmode=disable", "PostgreSQL connection string")
 	batchSize := flag.Int("batch", 5000, "Batch size for insertions")
+	workers := flag.Int("workers", runtime.NumCPU(), "Goroutines validating and transforming features")
+	writers := flag.Int("writers", 4, "Goroutines inserting rows, each with its own transaction")
+	resume := flag.Bool("resume", false, "Skip the features an interrupted import of the same file already committed")
+	bulk := flag.Bool("bulk", false, "Load through COPY into a staging table and merge in one transaction")
+	swap := flag.Bool("swap", false, "Load into alojamentos_next and swap it in atomically once it passes the sanity check")
+	swapMinRatio := flag.Float64("swap-min-ratio", 0.9, "Refuse the swap when the new table has fewer active records than this share of the current one")
+	sync := flag.Bool("sync", false, "Update changed records and deregister records missing from the input")
+	deadLetterFile := flag.String("dead-letter", "", "JSONL file receiving features rejected by validation")
+	latLongTolerance := flag.Float64("latlong-tolerance", 100, "Distance in metres beyond which geometry and LatLong are flagged as disagreeing")
+	maxRejectRatio := flag.Float64("max-reject-ratio", 0.05, "Fail the import when more than this share of records is rejected (negative disables)")
+	postalCentroids := flag.String("postal-centroids", "", "CSV of CP4-CP3 postal code centroids used to place records with missing or unreliable (NaoFiavel) coordinates")
+	dryRunFlag := flag.Bool("dry-run", false, "Report the inserts, updates and removals the import would make without changing the database")
+	diffOutput := flag.String("diff-output", "", "Write the dry-run report as JSON to this file instead of printing it")
 	flag.Parse()
 
-	log.Printf("Starting import from %s to PostgreSQL", *inputFile)
+	sourceCfg := sourceConfig{
+		Path:             *inputFile,
+		Format:           *format,
+		SourceCRS:        *sourceCRS,
+		FeatureServerURL: *featureServer,
+		PageSize:         *pageSize,
+		MaxRetries:       *maxRetries,
+	}
+
+	if *sourceCRS != "" {
+		if *featureServer != "" {
+			log.Fatalf("-source-crs applies to file inputs; FeatureServer queries are always requested in WGS84")
+		}
+		if _, err := geo.ParseCRS(*sourceCRS); err != nil {
+			log.Fatalf("Invalid -source-crs: %v", err)
+		}
+	}
+
+	log.Printf("Starting import from %s to PostgreSQL", sourceCfg.Name())
 
 	// Initialize database
 	db, err := initDatabase(*dbConn)
@@ -70,225 +84,307 @@ func main() {
 	}
 	defer db.Close()
 
-	// Read and parse JSON file
-	log.Println("Reading JSON file...")
-	data, err := os.ReadFile(*inputFile)
-	if err != nil {
-		log.Fatalf("Failed to read file: %v", err)
+	opts := importOptions{
+		BatchSize:        *batchSize,
+		Bulk:             *bulk,
+		Sync:             *sync,
+		MaxRejectRatio:   *maxRejectRatio,
+		LatLongTolerance: *latLongTolerance,
+		Workers:          *workers,
+		Writers:          *writers,
+		Resume:           *resume,
+		Swap:             *swap,
+		SwapMinRatio:     *swapMinRatio,
+	}
+
+	if opts.divisions, err = loadDivisionResolver(db); err != nil {
+		log.Fatalf("Failed to load division reference tables: %v", err)
+	}
+
+	if *postalCentroids != "" {
+		if opts.geocoder, err = loadPostalGeocoder(*postalCentroids); err != nil {
+			log.Fatalf("Failed to load postal code centroids: %v", err)
+		}
+		log.Printf("Loaded %d postal code centroids", len(opts.geocoder.centroids))
+	}
+
+	if *resume && (sourceCfg.FeatureServerURL != "" || *bulk || *swap) {
+		log.Fatalf("-resume needs a file input and cannot be combined with -bulk or -swap, which commit in a single transaction")
+	}
+
+	var deadLetters *bufio.Writer
+	if *deadLetterFile != "" {
+		file, err := os.Create(*deadLetterFile)
+		if err != nil {
+			log.Fatalf("Failed to create dead-letter file: %v", err)
+		}
+		defer file.Close()
+
+		deadLetters = bufio.NewWriter(file)
+		opts.DeadLetter = deadLetters
+	}
+
+	if *csvMapping != "" {
+		if sourceCfg.CSVMapping, err = loadCSVMapping(*csvMapping); err != nil {
+			log.Fatalf("Failed to load CSV mapping: %v", err)
+		}
+	}
+	if *csvDelimiter != "" {
+		delim := []rune(*csvDelimiter)
+		if len(delim) != 1 {
+			log.Fatalf("CSV delimiter must be a single character, got %q", *csvDelimiter)
+		}
+		sourceCfg.CSVDelimiter = delim[0]
+	}
+
+	if *dryRunFlag {
+		err = runDryRun(db, sourceCfg, opts, *diffOutput)
+		if deadLetters != nil {
+			if flushErr := deadLetters.Flush(); flushErr != nil {
+				log.Printf("Warning: Failed to write dead-letter file: %v", flushErr)
+			}
+		}
+		if err != nil {
+			log.Fatalf("Dry run failed: %v", err)
+		}
+		return
+	}
+
+	// Files are hashed up front so that checkpoints can be keyed by their
+	// checksum; FeatureServer pages are hashed as they are fetched
+	checksum := sha256.New()
+	var digest string
+	if sourceCfg.FeatureServerURL == "" {
+		paths, err := expandInput(sourceCfg.Path)
+		if err != nil {
+			log.Fatalf("Failed to read input: %v", err)
+		}
+		if digest, err = hashFiles(paths); err != nil {
+			log.Fatalf("Failed to read input: %v", err)
+		}
+		opts.sourceFile = sourceCfg.Name()
+		opts.sourceSHA256 = digest
 	}
 
-	log.Println("Parsing JSON...")
-	var collection GeoJSONCollection
-	if err := json.Unmarshal(data, &collection); err != nil {
-		log.Fatalf("Failed to parse JSON: %v", err)
+	runID, err := startImportRun(db, sourceCfg.Name(), opts)
+	if err != nil {
+		log.Fatalf("Failed to start import run: %v", err)
 	}
 
-	log.Printf("Found %d accommodations to import", len(collection.Features))
+	var stats importStats
+	err = readSource(sourceCfg, checksum, func(source featureSource) error {
+		var err error
+		stats, err = writeFeatures(db, source, opts)
+		return err
+	})
+
+	if deadLetters != nil {
+		if flushErr := deadLetters.Flush(); flushErr != nil {
+			log.Printf("Warning: Failed to write dead-letter file: %v", flushErr)
+		}
+		if stats.Rejected > 0 {
+			log.Printf("Wrote %d rejected records to %s", stats.Rejected, *deadLetterFile)
+		}
+	}
 
-	// Import data in batches
-	if err := importData(db, collection.Features, *batchSize); err != nil {
+	if digest == "" {
+		digest = hex.EncodeToString(checksum.Sum(nil))
+	}
+	if ledgerErr := finishImportRun(db, runID, digest, stats, err); ledgerErr != nil {
+		log.Printf("Warning: %v", ledgerErr)
+	}
+	if err != nil {
 		log.Fatalf("Failed to import data: %v", err)
 	}
 
+	log.Printf("Recorded import run %d", runID)
 	log.Println("Import completed successfully!")
 	log.Println("\nNext steps:")
 	log.Println("  - Run queries: ./query -db \"your-connection-string\"")
 	log.Println("  - Use psql: psql -d alojamentos")
 }
 
-func initDatabase(connStr string) (*sql.DB, error) {
-	db, err := sql.Open("postgres", connStr)
+// rollback restores the table replaced by the last -swap import
+func rollback(args []string) {
+	fs := flag.NewFlagSet("rollback", flag.ExitOnError)
+	dbConn := fs.String("db", "postgres://localhost/alojamentos?sslmode=disable", "PostgreSQL connection string")
+	fs.Parse(args)
+
+	db, err := initDatabase(*dbConn)
 	if err != nil {
-		return nil, err
+		log.Fatalf("Failed to initialize database: %v", err)
 	}
+	defer db.Close()
 
-	// Test connection
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	if err := rollbackSwap(db); err != nil {
+		if _, ledgerErr := recordRollback(db, err); ledgerErr != nil {
+			log.Printf("Warning: %v", ledgerErr)
+		}
+		log.Fatalf("Rollback failed: %v", err)
 	}
 
-	log.Println("Connected to PostgreSQL")
+	// Record the rollback even if the history update fails, as the live data
+	// has already changed
+	historyErr := writeHistory(db, time.Time{})
+	if historyErr != nil {
+		historyErr = fmt.Errorf("rolled back, but failed to update history: %w", historyErr)
+	}
 
-	// Create table schema
-	schema := `
-	CREATE TABLE IF NOT EXISTS alojamentos (
-		id SERIAL PRIMARY KEY,
-		object_id INTEGER,
-		nr_rnal INTEGER UNIQUE,
-		denominacao TEXT,
-		data_registo TIMESTAMP,
-		data_abertura_publico TIMESTAMP,
-		modalidade TEXT,
-		nr_utentes INTEGER,
-		email TEXT,
-		endereco TEXT,
-		codigo_postal TEXT,
-		localidade TEXT,
-		latitude DOUBLE PRECISION,
-		longitude DOUBLE PRECISION,
-		fiabilidade_geo TEXT,
-		freguesia TEXT,
-		concelho TEXT,
-		distrito TEXT,
-		nuts_iii TEXT,
-		nuts_ii TEXT,
-		ert TEXT,
-		selo_clean_safe TEXT,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_nr_rnal ON alojamentos(nr_rnal);
-	CREATE INDEX IF NOT EXISTS idx_concelho ON alojamentos(concelho);
-	CREATE INDEX IF NOT EXISTS idx_distrito ON alojamentos(distrito);
-	CREATE INDEX IF NOT EXISTS idx_modalidade ON alojamentos(modalidade);
-	CREATE INDEX IF NOT EXISTS idx_location ON alojamentos(latitude, longitude);
-	`
-
-	if _, err := db.Exec(schema); err != nil {
-		return nil, fmt.Errorf("failed to create schema: %w", err)
+	runID, err := recordRollback(db, historyErr)
+	if historyErr != nil {
+		log.Fatalf("%v", historyErr)
+	}
+	if err != nil {
+		log.Fatalf("Rolled back, but %v", err)
 	}
 
-	log.Println("Database schema ready")
-	return db, nil
+	log.Printf("Recorded rollback as import run %d", runID)
+	log.Printf("Rolled back: %s restored as %s, the replaced data is now %s", previousTable, liveTable, previousTable)
 }
 
-func importData(db *sql.DB, features []Feature, batchSize int) error {
+// writeFeatures validates the features from source and streams the valid ones
+// into the database. A sync run then deregisters the records it did not see.
+func writeFeatures(db *sql.DB, source featureSource, opts importOptions) (importStats, error) {
 	start := time.Now()
-	total := len(features)
-	imported := 0
-	skipped := 0
-
-	// Prepare statement
-	stmt, err := db.Prepare(`
-		INSERT INTO alojamentos (
-			object_id, nr_rnal, denominacao, data_registo, data_abertura_publico,
-			modalidade, nr_utentes, email, endereco, codigo_postal, localidade,
-			latitude, longitude, fiabilidade_geo, freguesia, concelho, distrito,
-			nuts_iii, nuts_ii, ert, selo_clean_safe
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
-		ON CONFLICT (nr_rnal) DO NOTHING
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %w", err)
-	}
-	defer stmt.Close()
 
-	// Begin transaction
-	tx, err := db.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+	var err error
+	if opts.Sync {
+		if opts.syncTime, err = syncTimestamp(db); err != nil {
+			return importStats{}, err
+		}
 	}
 
-	txStmt := tx.Stmt(stmt)
-	defer txStmt.Close()
-
-	for i, feature := range features {
-		// Parse coordinates
-		var lat, lng float64
-		if len(feature.Geometry.Coordinates) == 2 {
-			lng = feature.Geometry.Coordinates[0]
-			lat = feature.Geometry.Coordinates[1]
+	if opts.Resume {
+		if opts.skip, err = resumeFrom(db, opts.sourceSHA256, &opts); err != nil {
+			return importStats{}, err
 		}
+	}
+	if opts.sourceSHA256 != "" && !opts.Bulk && !opts.Swap {
+		opts.checkpoints = newCheckpointTracker(db, opts.sourceFile, opts.sourceSHA256, opts.Mode(), opts.syncTime, opts.skip)
+	}
 
-		// Parse dates
-		dataRegisto := parseDate(feature.Properties.DataRegisto)
-		dataAbertura := parseDate(feature.Properties.DataAberturaPublico)
-
-		// Execute insert
-		result, err := txStmt.Exec(
-			feature.Properties.OBJECTID,
-			feature.Properties.NrRNAL,
-			feature.Properties.Denominacao,
-			dataRegisto,
-			dataAbertura,
-			feature.Properties.Modalidade,
-			feature.Properties.NrUtentes,
-			feature.Properties.Email,
-			feature.Properties.Endereco,
-			feature.Properties.CodigoPostal,
-			feature.Properties.LOCALIDADE,
-			lat,
-			lng,
-			feature.Properties.FiabilidadeGeo,
-			feature.Properties.Freguesia,
-			feature.Properties.Concelho,
-			feature.Properties.Distrito,
-			feature.Properties.NUTSIII,
-			feature.Properties.NUTSII,
-			feature.Properties.ERT,
-			feature.Properties.SeloCleanSafe,
-		)
+	validated := newValidatingSource(source, opts.DeadLetter, opts.geocoder)
+
+	// Import data in batches, in bulk through COPY, or through a table swap
+	var stats importStats
+	switch {
+	case opts.Swap:
+		stats, err = swapImportData(db, validated, opts)
+	case opts.Bulk:
+		stats, err = bulkImportData(db, validated, opts)
+	default:
+		stats, err = importData(db, validated, opts)
+	}
+	stats.Rejected = validated.rejected
+	stats.Total += validated.rejected
+	for i, rejected := range validated.rejectedFiles {
+		stats.file(i).Rejected += rejected
+	}
+	if files, ok := source.(*multiSource); ok {
+		stats.Files = files.report(stats.Files)
+	} else {
+		stats.Files = nil
+	}
+	if err != nil {
+		return stats, err
+	}
 
-		if err != nil {
-			if strings.Contains(err.Error(), "duplicate key") {
-				skipped++
-			} else {
-				log.Printf("Warning: Failed to insert record %d: %v", i, err)
-			}
-		} else {
-			rowsAffected, _ := result.RowsAffected()
-			if rowsAffected > 0 {
-				imported++
-			} else {
-				skipped++
+	// Batches are committed as they are written, so in batch mode the
+	// threshold cannot undo the import; it fails the run and skips the
+	// deregistration, but the history still records the committed rows. Bulk
+	// loads and swaps check before merging.
+	if !opts.Bulk && !opts.Swap {
+		if err := checkRejectRatio(stats, opts.MaxRejectRatio); err != nil {
+			if historyErr := writeHistory(db, opts.syncTime); historyErr != nil {
+				log.Printf("Warning: %v", historyErr)
 			}
+			return stats, err
 		}
+	}
 
-		// Commit batch
-		if (i+1)%batchSize == 0 {
-			if err := tx.Commit(); err != nil {
-				return fmt.Errorf("failed to commit batch: %w", err)
-			}
-
-			progress := float64(i+1) / float64(total) * 100
-			elapsed := time.Since(start)
-			rate := float64(i+1) / elapsed.Seconds()
+	if n := opts.divisions.unresolved.Load(); n > 0 {
+		log.Printf("Warning: %d records did not match the reference divisions and were written without some codes", n)
+	}
+	if opts.geocoder != nil {
+		log.Printf("Placed %d records on their postal code centroid; %d with missing or unreliable coordinates had no known postal code",
+			opts.geocoder.geocoded.Load(), opts.geocoder.missing.Load())
+	}
 
-			log.Printf("Progress: %d/%d (%.1f%%) - %.0f records/sec - Imported: %d, Skipped: %d",
-				i+1, total, progress, rate, imported, skipped)
+	// A swap deregisters missing records while it loads the new table
+	if opts.Sync && !opts.Swap {
+		if err := finishSync(db, opts.syncTime, validated.rejectedRNAL, &stats); err != nil {
+			return stats, err
+		}
+	}
 
-			// Start new transaction
-			tx, err = db.Begin()
-			if err != nil {
-				return fmt.Errorf("failed to begin new transaction: %w", err)
-			}
-			txStmt = tx.Stmt(stmt)
+	if opts.checkpoints != nil {
+		if err := clearCheckpoint(db, opts.sourceSHA256); err != nil {
+			log.Printf("Warning: %v", err)
 		}
 	}
 
-	// Commit remaining records
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit final batch: %w", err)
+	if err := writeHistory(db, opts.syncTime); err != nil {
+		return stats, err
 	}
 
-	elapsed := time.Since(start)
-	log.Printf("\nImport summary:")
-	log.Printf("  Imported: %d", imported)
-	log.Printf("  Skipped (duplicates): %d", skipped)
-	log.Printf("  Total: %d", total)
-	log.Printf("  Duration: %s", elapsed)
-	log.Printf("  Rate: %.0f records/sec", float64(total)/elapsed.Seconds())
+	stats.logSummary(time.Since(start), opts.Sync)
 
-	return nil
+	return stats, nil
 }
 
-func parseDate(dateStr string) *time.Time {
-	if dateStr == "" {
+// runDryRun diffs the input against the database and prints the report, or
+// writes it as JSON when diffOutput is set. Dry runs are not recorded in the
+// import run ledger.
+func runDryRun(db *sql.DB, cfg sourceConfig, opts importOptions, diffOutput string) error {
+	var report *dryRunReport
+	err := readSource(cfg, io.Discard, func(source featureSource) error {
+		var err error
+		report, err = dryRun(db, source, opts)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	report.Source = cfg.Name()
+
+	if diffOutput == "" {
+		report.writeText(os.Stdout)
 		return nil
 	}
 
-	// Try parsing ISO8601 format
-	formats := []string{
-		time.RFC3339,
-		"2006-01-02T15:04:05Z",
-		"2006-01-02",
+	file, err := os.Create(diffOutput)
+	if err != nil {
+		return fmt.Errorf("failed to create diff output: %w", err)
 	}
+	defer file.Close()
 
-	for _, format := range formats {
-		if t, err := time.Parse(format, dateStr); err == nil {
-			return &t
-		}
+	if err := report.writeJSON(file); err != nil {
+		return fmt.Errorf("failed to write diff output: %w", err)
 	}
 
+	log.Printf("Dry run: %d to insert, %d to update, %d to remove. Report written to %s",
+		len(report.Inserted), len(report.Updated), len(report.Removed), diffOutput)
 	return nil
 }
+
+func initDatabase(connStr string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Test connection
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	log.Println("Connected to PostgreSQL")
+
+	// Bring the schema up to date with the embedded migrations
+	if _, err := database.MigrateUp(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
+
+	log.Println("Database schema ready")
+	return db, nil
+}
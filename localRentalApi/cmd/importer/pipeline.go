@@ -0,0 +1,359 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// featureJob is a decoded feature with its position in the input and the
+// position of the input file it was read from
+type featureJob struct {
+	index   int
+	file    int
+	feature Feature
+}
+
+// rowJob holds the column values of a validated feature
+type rowJob struct {
+	index  int
+	file   int
+	values []any
+}
+
+// pipeline coordinates the stages of a concurrent import. The first error
+// closes done, which stops every stage.
+type pipeline struct {
+	done chan struct{}
+	once sync.Once
+	err  error
+
+	mu    sync.Mutex
+	stats importStats
+	start time.Time
+}
+
+func (p *pipeline) fail(err error) {
+	p.once.Do(func() {
+		p.err = err
+		close(p.done)
+	})
+}
+
+// record adds the counters of a committed batch to the run totals
+func (p *pipeline) record(batch importStats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stats.add(batch)
+
+	rate := float64(p.stats.Total) / time.Since(p.start).Seconds()
+	log.Printf("Progress: %d records - %.0f records/sec - Imported: %d, Updated: %d, Skipped: %d",
+		p.stats.Total, rate, p.stats.Imported, p.stats.Updated, p.stats.Skipped)
+}
+
+// importData writes features through a concurrent pipeline: one decoder reads
+// the source, opts.Workers goroutines validate features and build their column
+// values, and opts.Writers goroutines insert them, each through its own
+// transaction committed every opts.BatchSize rows. When opts.checkpoints is
+// set, progress is saved after every commit. Rows are routed to writers
+// by registration number, so two writers never touch the same row and cannot
+// deadlock on each other; the order in which rows are written is otherwise
+// unspecified.
+func importData(db *sql.DB, validated *validatingSource, opts importOptions) (importStats, error) {
+	workers := max(opts.Workers, 1)
+	writers := max(opts.Writers, 1)
+
+	// Prepare statement
+	stmt, err := db.Prepare(insertStatement(opts))
+	if err != nil {
+		return importStats{}, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	p := &pipeline{done: make(chan struct{}), start: time.Now()}
+
+	features := make(chan featureJob, workers*64)
+	rows := make([]chan rowJob, writers)
+	for i := range rows {
+		rows[i] = make(chan rowJob, 256)
+	}
+
+	// Decoder stage
+	decoded := make(chan struct{})
+	go func() {
+		defer close(decoded)
+		defer close(features)
+		for index := 0; ; index++ {
+			feature, err := validated.source.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				p.fail(fmt.Errorf("failed to read feature: %w", err))
+				return
+			}
+			select {
+			case features <- featureJob{index: index, file: filePosition(validated.source), feature: feature}:
+			case <-p.done:
+				return
+			}
+		}
+	}()
+
+	// Validation and transform stage
+	var workerWG sync.WaitGroup
+	for range workers {
+		workerWG.Add(1)
+		go func() {
+			defer workerWG.Done()
+			for job := range features {
+				ok, err := validated.check(job.index, job.file, job.feature)
+				if err != nil {
+					p.fail(err)
+					return
+				}
+				if !ok {
+					if opts.checkpoints != nil {
+						opts.checkpoints.complete(job.index)
+					}
+					continue
+				}
+
+				// Committed by the interrupted run being resumed. Skipped features
+				// are still validated so that rejections are reported in full.
+				if job.index < opts.skip {
+					continue
+				}
+
+				// Validation guarantees a positive registration number
+				row := rowJob{index: job.index, file: job.file, values: featureValues(job.feature, opts)}
+				select {
+				case rows[job.feature.Properties.NrRNAL%writers] <- row:
+				case <-p.done:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		workerWG.Wait()
+		for _, ch := range rows {
+			close(ch)
+		}
+	}()
+
+	// Writer stage
+	var writerWG sync.WaitGroup
+	for _, ch := range rows {
+		writerWG.Add(1)
+		go func() {
+			defer writerWG.Done()
+			if err := writeRows(db, stmt, ch, opts, p); err != nil {
+				p.fail(err)
+			}
+		}()
+	}
+	writerWG.Wait()
+
+	// The decoder may still be reading; wait so the caller owns the input again
+	<-decoded
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats, p.err
+}
+
+// insertStatement returns the per-row INSERT, or the upsert in sync mode where
+// every row is stamped with the run timestamp
+func insertStatement(opts importOptions) string {
+	if opts.Sync {
+		n := len(alojamentoColumns)
+		return fmt.Sprintf(`
+			INSERT INTO alojamentos (%s, updated_at, last_seen_at)
+			VALUES (%s, $%d, $%d)
+		`, strings.Join(alojamentoColumns, ", "), placeholders(n), n+1, n+1) + syncConflictClause() + "RETURNING " + syncReturning
+	}
+
+	return fmt.Sprintf(`
+		INSERT INTO alojamentos (%s)
+		VALUES (%s)
+		ON CONFLICT (nr_rnal) DO NOTHING
+	`, strings.Join(alojamentoColumns, ", "), placeholders(len(alojamentoColumns)))
+}
+
+// writeRows executes stmt for every row received, committing its own
+// transaction every opts.BatchSize rows. It stops without committing the open
+// batch when the pipeline fails.
+func writeRows(db *sql.DB, stmt *sql.Stmt, rows <-chan rowJob, opts importOptions, p *pipeline) error {
+	var tx *sql.Tx
+	var txStmt *sql.Stmt
+	var batch importStats
+	// pending keeps the rows of the open batch in case it has to be replayed
+	pending := make([]rowJob, 0, opts.BatchSize)
+	failed := false
+	var indices []int
+
+	for {
+		var row rowJob
+		var ok bool
+		select {
+		case row, ok = <-rows:
+		case <-p.done:
+			if tx != nil {
+				tx.Rollback()
+			}
+			return nil
+		}
+		if !ok {
+			break
+		}
+
+		// Begin transaction
+		if tx == nil {
+			var err error
+			if tx, err = db.Begin(); err != nil {
+				return fmt.Errorf("failed to begin transaction: %w", err)
+			}
+			txStmt = tx.Stmt(stmt)
+		}
+
+		batch.Total++
+		pending = append(pending, row)
+		indices = append(indices, row.index)
+		// A failed statement aborts the transaction, so the rest of the batch
+		// waits for the replay
+		if !failed {
+			failed = writeRow(txStmt, row, opts, &batch) != nil
+		}
+
+		// Commit batch
+		if len(pending) == opts.BatchSize {
+			stats, err := finishBatch(db, stmt, tx, pending, batch, failed, opts)
+			if err != nil {
+				return err
+			}
+			p.record(stats)
+
+			if opts.checkpoints != nil {
+				if err := opts.checkpoints.commit(indices); err != nil {
+					return err
+				}
+			}
+			tx, batch, pending, indices, failed = nil, importStats{}, pending[:0], indices[:0], false
+		}
+	}
+
+	// Commit remaining records
+	if tx != nil {
+		stats, err := finishBatch(db, stmt, tx, pending, batch, failed, opts)
+		if err != nil {
+			return err
+		}
+		p.record(stats)
+	}
+	if opts.checkpoints != nil {
+		if err := opts.checkpoints.commit(indices); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// finishBatch commits a batch written as plain statements. When one of them
+// failed, the transaction is rolled back instead and the batch replayed row
+// by row, each row under a savepoint so that a failure only loses that row.
+func finishBatch(db *sql.DB, stmt *sql.Stmt, tx *sql.Tx, rows []rowJob, batch importStats, failed bool, opts importOptions) (importStats, error) {
+	if !failed {
+		if err := tx.Commit(); err != nil {
+			return batch, fmt.Errorf("failed to commit batch: %w", err)
+		}
+		return batch, nil
+	}
+	tx.Rollback()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return batch, fmt.Errorf("failed to begin replay transaction: %w", err)
+	}
+	txStmt := tx.Stmt(stmt)
+
+	batch = importStats{Total: len(rows)}
+	for _, row := range rows {
+		if err := replayRow(tx, txStmt, row, opts, &batch); err != nil {
+			tx.Rollback()
+			return batch, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return batch, fmt.Errorf("failed to commit replayed batch: %w", err)
+	}
+	return batch, nil
+}
+
+// replayRow writes one row under a savepoint, counting a failure instead of
+// letting it abort the transaction
+func replayRow(tx *sql.Tx, txStmt *sql.Stmt, row rowJob, opts importOptions, stats *importStats) error {
+	if _, err := tx.Exec("SAVEPOINT replay_row"); err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+
+	if err := writeRow(txStmt, row, opts, stats); err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			stats.skipped(row.file)
+		} else {
+			stats.failed(row.file)
+			log.Printf("Warning: Failed to write record %d: %v", row.index, err)
+		}
+		if _, err := tx.Exec("ROLLBACK TO SAVEPOINT replay_row"); err != nil {
+			return fmt.Errorf("failed to roll back to savepoint: %w", err)
+		}
+		return nil
+	}
+
+	if _, err := tx.Exec("RELEASE SAVEPOINT replay_row"); err != nil {
+		return fmt.Errorf("failed to release savepoint: %w", err)
+	}
+	return nil
+}
+
+// writeRow inserts or upserts one row and counts the outcome in stats. A
+// failed statement is returned uncounted.
+func writeRow(txStmt *sql.Stmt, row rowJob, opts importOptions, stats *importStats) error {
+	if opts.Sync {
+		// Execute upsert
+		var inserted, changed bool
+		if err := txStmt.QueryRow(append(row.values, opts.syncTime)...).Scan(&inserted, &changed); err != nil {
+			return err
+		}
+		switch {
+		case inserted:
+			stats.imported(row.file)
+		case changed:
+			stats.updated(row.file)
+		default:
+			stats.skipped(row.file)
+		}
+		return nil
+	}
+
+	// Execute insert
+	result, err := txStmt.Exec(row.values...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected > 0 {
+		stats.imported(row.file)
+	} else {
+		stats.skipped(row.file)
+	}
+	return nil
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"localRental/pkg/divisions"
+	"localRental/pkg/geo"
+)
+
+// alojamentoColumns lists the columns written by the importer, in the order
+// returned by featureValues
+var alojamentoColumns = []string{
+	"object_id", "nr_rnal", "denominacao", "data_registo", "data_abertura_publico",
+	"modalidade", "nr_utentes", "email", "endereco", "codigo_postal", "localidade",
+	"latitude", "longitude", "fiabilidade_geo", "freguesia", "concelho", "distrito",
+	"nuts_iii", "nuts_ii", "ert", "selo_clean_safe",
+	"latlong_mismatch", "latlong_distance_m",
+	"distrito_code", "concelho_code", "freguesia_code",
+	"distrito_name_key", "concelho_name_key", "freguesia_name_key",
+	"geocode_source", "original_latitude", "original_longitude",
+}
+
+// featureValues converts a feature into column values matching alojamentoColumns
+func featureValues(feature Feature, opts importOptions) []any {
+	// Place unreliable or missing positions on their postal code centroid
+	loc := opts.geocoder.locate(feature, true)
+
+	distance, mismatch := latLongMismatch(feature, opts.LatLongTolerance)
+
+	// Parse dates
+	dataRegisto := parseDate(feature.Properties.DataRegisto)
+	dataAbertura := parseDate(feature.Properties.DataAberturaPublico)
+
+	// Resolve the administrative divisions to their DICOFRE codes
+	codes := opts.divisions.resolve(feature.Properties.Distrito, feature.Properties.Concelho, feature.Properties.Freguesia)
+
+	return []any{
+		feature.Properties.OBJECTID,
+		feature.Properties.NrRNAL,
+		feature.Properties.Denominacao,
+		dataRegisto,
+		dataAbertura,
+		feature.Properties.Modalidade,
+		feature.Properties.NrUtentes,
+		feature.Properties.Email,
+		feature.Properties.Endereco,
+		feature.Properties.CodigoPostal,
+		feature.Properties.LOCALIDADE,
+		loc.lat,
+		loc.lng,
+		feature.Properties.FiabilidadeGeo,
+		feature.Properties.Freguesia,
+		feature.Properties.Concelho,
+		feature.Properties.Distrito,
+		feature.Properties.NUTSIII,
+		feature.Properties.NUTSII,
+		feature.Properties.ERT,
+		feature.Properties.SeloCleanSafe,
+		mismatch,
+		distance,
+		codes.distrito,
+		codes.concelho,
+		codes.freguesia,
+		divisions.Normalize(feature.Properties.Distrito),
+		divisions.Normalize(feature.Properties.Concelho),
+		divisions.Normalize(feature.Properties.Freguesia),
+		loc.source,
+		loc.originalLat,
+		loc.originalLng,
+	}
+}
+
+// featureCoordinates returns the feature position as latitude/longitude. The
+// point geometry is preferred and the LatLong property is the fallback; (0,0)
+// is returned when neither is usable.
+func featureCoordinates(feature Feature) (lat, lng float64) {
+	if lat, lng, ok := geometryCoordinates(feature); ok {
+		return lat, lng
+	}
+	if lat, lng, ok := parseLatLong(feature.Properties.LatLong); ok {
+		return lat, lng
+	}
+	return 0, 0
+}
+
+// geometryCoordinates returns the point geometry, treating (0,0) as missing
+func geometryCoordinates(feature Feature) (lat, lng float64, ok bool) {
+	if len(feature.Geometry.Coordinates) < 2 {
+		return 0, 0, false
+	}
+
+	lng = feature.Geometry.Coordinates[0]
+	lat = feature.Geometry.Coordinates[1]
+	if lat == 0 && lng == 0 {
+		return 0, 0, false
+	}
+	return lat, lng, true
+}
+
+// parseLatLong parses the LatLong property, written as "lat ; lng" with
+// Portuguese decimal commas, e.g. "37,0657410006129 ; -7,82703800016578".
+// Values outside the valid latitude and longitude ranges are not usable.
+func parseLatLong(value string) (lat, lng float64, ok bool) {
+	parts := strings.Split(value, ";")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+
+	lat, err := parseDecimal(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	lng, err = parseDecimal(parts[1])
+	if err != nil {
+		return 0, 0, false
+	}
+
+	if lat == 0 && lng == 0 {
+		return 0, 0, false
+	}
+	if lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return 0, 0, false
+	}
+	return lat, lng, true
+}
+
+// latLongMismatch returns the distance between the geometry and the LatLong
+// property, and whether it is beyond tolerance metres. The distance is nil
+// unless the feature carries both.
+func latLongMismatch(feature Feature, tolerance float64) (*float64, bool) {
+	d, ok := latLongDistance(feature)
+	if !ok {
+		return nil, false
+	}
+	return &d, d > tolerance
+}
+
+// latLongDistance returns the distance in metres between the geometry and the
+// LatLong property, when the feature carries both
+func latLongDistance(feature Feature) (float64, bool) {
+	gLat, gLng, ok := geometryCoordinates(feature)
+	if !ok {
+		return 0, false
+	}
+
+	pLat, pLng, ok := parseLatLong(feature.Properties.LatLong)
+	if !ok {
+		return 0, false
+	}
+
+	return geo.DistanceMeters(gLat, gLng, pLat, pLng), true
+}
+
+// placeholders returns "$1, $2, ..., $n"
+func placeholders(n int) string {
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = fmt.Sprintf("$%d", i+1)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func parseDate(dateStr string) *time.Time {
+	if dateStr == "" {
+		return nil
+	}
+
+	// Try parsing ISO8601 format, then the day-first formats found in CSV exports
+	formats := []string{
+		time.RFC3339,
+		"2006-01-02T15:04:05Z",
+		"2006-01-02 15:04:05",
+		"2006-01-02",
+		"02/01/2006 15:04:05",
+		"02/01/2006",
+		"02-01-2006",
+	}
+
+	for _, format := range formats {
+		if t, err := time.Parse(format, dateStr); err == nil {
+			return &t
+		}
+	}
+
+	return nil
+}
+
+// importOptions controls how features are written to the database
+type importOptions struct {
+	BatchSize        int
+	Bulk             bool
+	Sync             bool
+	DeadLetter       io.Writer
+	MaxRejectRatio   float64
+	LatLongTolerance float64
+	// Workers validate and transform features, Writers each insert through
+	// their own transaction
+	Workers int
+	Writers int
+	// Swap loads into a fresh table and swaps it in once it passes the sanity
+	// check, which requires at least SwapMinRatio of the live active records
+	Swap         bool
+	SwapMinRatio float64
+	// Resume skips the features an interrupted run of the same input committed
+	Resume bool
+
+	// syncTime is the timestamp stamped on every row touched by a sync run
+	syncTime time.Time
+	// sourceFile and sourceSHA256 identify the input for checkpoints, which are
+	// only kept for file inputs
+	sourceFile   string
+	sourceSHA256 string
+	// skip is the number of leading features already committed by an
+	// interrupted run, and checkpoints records progress for the next one
+	skip        int
+	checkpoints *checkpointTracker
+	// divisions resolves records to the administrative division reference
+	// tables; records are written without codes when it is nil
+	divisions *divisionResolver
+	// geocoder replaces missing or unreliable coordinates with postal code
+	// centroids; coordinates are written as read when it is nil
+	geocoder *postalGeocoder
+}
+
+// Mode describes the write strategy, as recorded in the import_runs ledger
+func (o importOptions) Mode() string {
+	mode := "insert"
+	switch {
+	case o.Swap:
+		mode = "swap"
+	case o.Bulk:
+		mode = "bulk"
+	}
+	if o.Sync {
+		mode += "+sync"
+	}
+	return mode
+}
+
+// importStats holds the counters reported at the end of an import
+type importStats struct {
+	Total        int
+	Imported     int
+	Updated      int
+	Skipped      int
+	Failed       int
+	Rejected     int
+	Deregistered int
+	// Files breaks the counters down by input file, indexed by the position
+	// of the file in the input
+	Files []fileCount
+}
+
+// add accumulates the counters of o into s
+func (s *importStats) add(o importStats) {
+	s.Total += o.Total
+	s.Imported += o.Imported
+	s.Updated += o.Updated
+	s.Skipped += o.Skipped
+	s.Failed += o.Failed
+	s.Rejected += o.Rejected
+	s.Deregistered += o.Deregistered
+	for i, f := range o.Files {
+		s.file(i).add(f)
+	}
+}
+
+// file returns the counters of the input file at position i, growing Files
+// as needed
+func (s *importStats) file(i int) *fileCount {
+	for len(s.Files) <= i {
+		s.Files = append(s.Files, fileCount{})
+	}
+	return &s.Files[i]
+}
+
+// imported counts a row inserted from the input file at position file
+func (s *importStats) imported(file int) {
+	s.Imported++
+	s.file(file).Imported++
+}
+
+// updated counts a row of the input file at position file that changed an
+// existing registration
+func (s *importStats) updated(file int) {
+	s.Updated++
+	s.file(file).Updated++
+}
+
+// skipped counts a row of the input file at position file that was a
+// duplicate or left its registration unchanged
+func (s *importStats) skipped(file int) {
+	s.Skipped++
+	s.file(file).Skipped++
+}
+
+// failed counts a row of the input file at position file that could not be written
+func (s *importStats) failed(file int) {
+	s.Failed++
+	s.file(file).Failed++
+}
+
+func (s importStats) logSummary(elapsed time.Duration, sync bool) {
+	log.Printf("\nImport summary:")
+	log.Printf("  Imported: %d", s.Imported)
+	if sync {
+		log.Printf("  Updated: %d", s.Updated)
+		log.Printf("  Unchanged: %d", s.Skipped)
+		log.Printf("  Deregistered: %d", s.Deregistered)
+	} else {
+		log.Printf("  Skipped (duplicates): %d", s.Skipped)
+	}
+	if s.Failed > 0 {
+		log.Printf("  Failed: %d", s.Failed)
+	}
+	if s.Rejected > 0 {
+		log.Printf("  Rejected (validation): %d", s.Rejected)
+	}
+	log.Printf("  Total: %d", s.Total)
+	if len(s.Files) > 1 {
+		for _, f := range s.Files {
+			log.Printf("    %s: %d features, %d imported, %d updated, %d skipped, %d failed, %d rejected",
+				f.Name, f.Features, f.Imported, f.Updated, f.Skipped, f.Failed, f.Rejected)
+		}
+	}
+	log.Printf("  Duration: %s", elapsed)
+	log.Printf("  Rate: %.0f records/sec", float64(s.Total)/elapsed.Seconds())
+}
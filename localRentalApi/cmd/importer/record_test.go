@@ -0,0 +1,122 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseLatLong(t *testing.T) {
+	tests := []struct {
+		value    string
+		lat, lng float64
+		ok       bool
+	}{
+		{"37,0657410006129 ; -7,82703800016578", 37.0657410006129, -7.82703800016578, true},
+		{"37,0657410006129;-7,82703800016578", 37.0657410006129, -7.82703800016578, true},
+		{"  38,7077 ;  -9,1365  ", 38.7077, -9.1365, true},
+		{"38.7077 ; -9.1365", 38.7077, -9.1365, true},
+		{"90 ; 180", 90, 180, true},
+		{"-90 ; -180", -90, -180, true},
+
+		// Missing or extra separators
+		{"37,0657410006129 -7,82703800016578", 0, 0, false},
+		{"37,0657410006129 , -7,82703800016578", 0, 0, false},
+		{"37,06 ; -7,82 ; 1", 0, 0, false},
+		{"", 0, 0, false},
+
+		// Unparseable halves
+		{"37,06 ;", 0, 0, false},
+		{"; -7,82", 0, 0, false},
+		{"norte ; oeste", 0, 0, false},
+
+		// (0,0) stands for a missing position
+		{"0 ; 0", 0, 0, false},
+
+		// Out of range
+		{"90,5 ; -7,82", 0, 0, false},
+		{"-91 ; -7,82", 0, 0, false},
+		{"37,06 ; 180,1", 0, 0, false},
+		{"37,06 ; -780,27", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		lat, lng, ok := parseLatLong(tt.value)
+		if ok != tt.ok || lat != tt.lat || lng != tt.lng {
+			t.Errorf("parseLatLong(%q) = %v, %v, %v; want %v, %v, %v", tt.value, lat, lng, ok, tt.lat, tt.lng, tt.ok)
+		}
+	}
+}
+
+// pointFeature returns a feature with a point geometry and a LatLong property
+func pointFeature(lat, lng float64, latLong string) Feature {
+	f := Feature{Type: "Feature"}
+	f.Geometry = Geometry{Type: "Point", Coordinates: []float64{lng, lat}}
+	f.Properties.LatLong = latLong
+	return f
+}
+
+func TestLatLongMismatch(t *testing.T) {
+	// One thousandth of a degree of latitude, about 111 m
+	const step = 0.001
+	stepMetres := step * math.Pi / 180 * 6371008.8
+
+	tests := []struct {
+		name      string
+		feature   Feature
+		tolerance float64
+		distance  float64 // negative when none is reported
+		mismatch  bool
+	}{
+		{"same position", pointFeature(37.0657410006129, -7.82703800016578, "37,0657410006129 ; -7,82703800016578"), 100, 0, false},
+		{"beyond tolerance", pointFeature(37.065, -7.827, "37,066 ; -7,827"), 100, stepMetres, true},
+		{"within tolerance", pointFeature(37.065, -7.827, "37,066 ; -7,827"), 120, stepMetres, false},
+		{"zero tolerance", pointFeature(37.065, -7.827, "37,066 ; -7,827"), 0, stepMetres, true},
+		{"no LatLong", pointFeature(37.065, -7.827, ""), 100, -1, false},
+		{"unparseable LatLong", pointFeature(37.065, -7.827, "37,066 -7,827"), 100, -1, false},
+		{"no geometry", Feature{Properties: Properties{LatLong: "37,066 ; -7,827"}}, 100, -1, false},
+		{"zero geometry", pointFeature(0, 0, "37,066 ; -7,827"), 100, -1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			distance, mismatch := latLongMismatch(tt.feature, tt.tolerance)
+			if mismatch != tt.mismatch {
+				t.Errorf("mismatch = %v, want %v", mismatch, tt.mismatch)
+			}
+			switch {
+			case tt.distance < 0 && distance != nil:
+				t.Errorf("distance = %v, want none", *distance)
+			case tt.distance >= 0 && distance == nil:
+				t.Errorf("no distance, want %v", tt.distance)
+			case tt.distance >= 0 && math.Abs(*distance-tt.distance) > 0.01:
+				t.Errorf("distance = %v, want %v", *distance, tt.distance)
+			}
+		})
+	}
+
+	// The tolerance itself is not a mismatch
+	f := pointFeature(37.065, -7.827, "37,066 ; -7,827")
+	distance, _ := latLongMismatch(f, 0)
+	if _, mismatch := latLongMismatch(f, *distance); mismatch {
+		t.Errorf("distance equal to the tolerance flagged as a mismatch")
+	}
+}
+
+func TestFeatureCoordinatesFallsBackToLatLong(t *testing.T) {
+	tests := []struct {
+		name     string
+		feature  Feature
+		lat, lng float64
+	}{
+		{"geometry preferred", pointFeature(38.7077, -9.1365, "41,1456 ; -8,6109"), 38.7077, -9.1365},
+		{"zero geometry", pointFeature(0, 0, "41,1456 ; -8,6109"), 41.1456, -8.6109},
+		{"no geometry", Feature{Properties: Properties{LatLong: "41,1456 ; -8,6109"}}, 41.1456, -8.6109},
+		{"neither", Feature{Properties: Properties{LatLong: "n/a"}}, 0, 0},
+	}
+
+	for _, tt := range tests {
+		if lat, lng := featureCoordinates(tt.feature); lat != tt.lat || lng != tt.lng {
+			t.Errorf("%s: featureCoordinates = %v, %v; want %v, %v", tt.name, lat, lng, tt.lat, tt.lng)
+		}
+	}
+}
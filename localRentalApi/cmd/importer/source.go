@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"localRental/pkg/geo"
+)
+
+// Supported input formats
+const (
+	formatGeoJSON = "geojson"
+	formatCSV     = "csv"
+)
+
+// sourceConfig describes where to read the input from and how to decode it
+type sourceConfig struct {
+	Path         string
+	Format       string
+	CSVMapping   map[string]string
+	CSVDelimiter rune
+	// SourceCRS overrides the coordinate reference system of file inputs
+	SourceCRS        string
+	FeatureServerURL string
+	PageSize         int
+	MaxRetries       int
+}
+
+// Name identifies the input in logs and in the import_runs ledger
+func (c sourceConfig) Name() string {
+	if c.FeatureServerURL != "" {
+		return c.FeatureServerURL
+	}
+	return c.Path
+}
+
+// readSource opens the configured input and hands its features to fn. File
+// inputs are expanded into their data files, which are read in turn as one
+// source; FeatureServer responses are also written to checksum.
+func readSource(cfg sourceConfig, checksum io.Writer, fn func(source featureSource) error) error {
+	if cfg.FeatureServerURL != "" {
+		client := &http.Client{Timeout: 2 * time.Minute}
+		source, err := newArcGISSource(client, cfg.FeatureServerURL, cfg.PageSize, checksum)
+		if err != nil {
+			return err
+		}
+		source.maxRetries = cfg.MaxRetries
+		return fn(source)
+	}
+
+	paths, err := expandInput(cfg.Path)
+	if err != nil {
+		return err
+	}
+
+	entries, err := inputEntries(paths, cfg.Format)
+	if err != nil {
+		return err
+	}
+
+	source := newMultiSource(entries, cfg)
+	defer source.Close()
+
+	return fn(source)
+}
+
+// detectFormat infers the input format from the file extension
+func detectFormat(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return formatCSV
+	}
+	return formatGeoJSON
+}
+
+// newFeatureSource returns a decoder for r in the configured format
+func newFeatureSource(r io.Reader, cfg sourceConfig) (featureSource, error) {
+	switch cfg.Format {
+	case formatGeoJSON:
+		source, err := newGeoJSONDecoder(r, cfg.SourceCRS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+		log.Printf("Streaming features from collection %q", source.Name())
+		logCRS(source.CRS())
+		return source, nil
+	case formatCSV:
+		crs, err := geo.ParseCRS(cfg.SourceCRS)
+		if err != nil {
+			return nil, err
+		}
+		source, err := newCSVDecoder(r, cfg.CSVMapping, cfg.CSVDelimiter, crs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CSV: %w", err)
+		}
+		log.Printf("Streaming CSV records")
+		logCRS(crs)
+		return source, nil
+	default:
+		return nil, fmt.Errorf("unsupported input format %q", cfg.Format)
+	}
+}
+
+// logCRS reports when input coordinates are converted to WGS84
+func logCRS(crs geo.CRS) {
+	if !crs.IsWGS84() {
+		log.Printf("Converting coordinates from %s to WGS84", crs.Name)
+	}
+}
@@ -0,0 +1,481 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Tables involved in a blue/green load. Indexes and the id sequence of each
+// table carry the same suffix as the table name, so they can be renamed along
+// with it.
+const (
+	liveTable     = "alojamentos"
+	nextTable     = "alojamentos_next"
+	previousTable = "alojamentos_previous"
+	swapTable     = "alojamentos_swap"
+)
+
+// swapImportData loads the input into a fresh alojamentos_next table, builds
+// its indexes, checks that it is not much smaller than the live table and then
+// swaps it in with one transactional rename. The replaced table is kept as
+// alojamentos_previous for rollbackSwap.
+//
+// Registrations already present keep their id and created_at, and updated_at
+// only moves when their data changed. In sync mode, registrations missing from
+// the input are carried over as deregistered; otherwise they are dropped.
+func swapImportData(db *sql.DB, validated *validatingSource, opts importOptions) (importStats, error) {
+	start := time.Now()
+	var stats importStats
+
+	if opts.syncTime.IsZero() {
+		var err error
+		if opts.syncTime, err = syncTimestamp(db); err != nil {
+			return stats, err
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return stats, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := createNextTable(tx); err != nil {
+		return stats, err
+	}
+
+	if stats.Total, err = stageFeatures(tx, validated, opts); err != nil {
+		return stats, err
+	}
+
+	// Check before anything is swapped in
+	if err := checkRejectRatio(importStats{
+		Total:    stats.Total + validated.rejected,
+		Rejected: validated.rejected,
+	}, opts.MaxRejectRatio); err != nil {
+		return stats, err
+	}
+
+	log.Printf("Staged %d records in %s, loading %s...", stats.Total, time.Since(start), nextTable)
+
+	if err := loadNextTable(tx, opts.syncTime, &stats); err != nil {
+		return stats, err
+	}
+
+	if opts.Sync {
+		keep := validated.rejectedRNAL
+		if keep == nil {
+			keep = []int{}
+		}
+		if stats.Deregistered, err = carryOverMissing(tx, opts.syncTime, keep); err != nil {
+			return stats, err
+		}
+	}
+
+	if err := buildNextIndexes(tx); err != nil {
+		return stats, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return stats, fmt.Errorf("failed to commit %s: %w", nextTable, err)
+	}
+
+	if _, err := db.Exec("ANALYZE " + nextTable); err != nil {
+		return stats, fmt.Errorf("failed to analyze %s: %w", nextTable, err)
+	}
+
+	if err := checkNextTable(db, opts.SwapMinRatio); err != nil {
+		return stats, fmt.Errorf("%w; %s was kept for inspection", err, nextTable)
+	}
+
+	if err := swapTables(db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DROP TABLE IF EXISTS " + previousTable); err != nil {
+			return fmt.Errorf("failed to drop %s: %w", previousTable, err)
+		}
+		if err := renameTable(tx, liveTable, previousTable); err != nil {
+			return err
+		}
+		return renameTable(tx, nextTable, liveTable)
+	}); err != nil {
+		return stats, err
+	}
+
+	log.Printf("Swapped %s in as %s, previous data kept in %s", nextTable, liveTable, previousTable)
+
+	return stats, nil
+}
+
+// createNextTable creates an empty alojamentos_next with the columns, defaults
+// and check constraints of alojamentos, and an id sequence of its own that
+// continues after the live one
+func createNextTable(tx *sql.Tx) error {
+	statements := []string{
+		"DROP TABLE IF EXISTS " + nextTable,
+		fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS INCLUDING CONSTRAINTS)", nextTable, liveTable),
+		fmt.Sprintf("CREATE SEQUENCE %s_id_seq OWNED BY %s.id", nextTable, nextTable),
+		fmt.Sprintf("ALTER TABLE %s ALTER COLUMN id SET DEFAULT nextval('%s_id_seq')", nextTable, nextTable),
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create %s: %w", nextTable, err)
+		}
+	}
+
+	var liveSeq sql.NullString
+	if err := tx.QueryRow("SELECT pg_get_serial_sequence($1, 'id')", liveTable).Scan(&liveSeq); err != nil {
+		return fmt.Errorf("failed to find the %s id sequence: %w", liveTable, err)
+	}
+	if liveSeq.Valid {
+		if _, err := tx.Exec(fmt.Sprintf(
+			"SELECT setval('%s_id_seq', (SELECT last_value FROM %s))", nextTable, liveSeq.String,
+		)); err != nil {
+			return fmt.Errorf("failed to initialise the %s id sequence: %w", nextTable, err)
+		}
+	}
+
+	return nil
+}
+
+// loadNextTable fills alojamentos_next from the staging table, keeping the id
+// and created_at of registrations that already exist, and counts the outcome
+// per input file
+func loadNextTable(tx *sql.Tx, runTime time.Time, stats *importStats) error {
+	var current, incoming []string
+	for _, col := range alojamentoColumns {
+		if col == "nr_rnal" {
+			continue
+		}
+		current = append(current, "a."+col)
+		incoming = append(incoming, "s."+col)
+	}
+
+	columns := strings.Join(alojamentoColumns, ", ")
+	staged := "s." + strings.Join(alojamentoColumns, ", s.")
+
+	// A source listing the same registration twice keeps the highest
+	// OBJECTID, then its last listing, as the sync merge does
+	err := countMerged(tx, fmt.Sprintf(`
+		WITH chosen AS (
+			SELECT DISTINCT ON (nr_rnal) * FROM %s ORDER BY nr_rnal, object_id DESC, %s DESC
+		), merged AS (
+			INSERT INTO %s (id, %s, created_at, updated_at, last_seen_at, deregistered_at)
+			SELECT COALESCE(a.id, nextval('%s_id_seq')), %s,
+			       COALESCE(a.created_at, $1::timestamp),
+			       CASE
+			           WHEN a.id IS NULL OR a.deregistered_at IS NOT NULL
+			             OR (%s) IS DISTINCT FROM (%s)
+			           THEN $1::timestamp
+			           ELSE a.updated_at
+			       END,
+			       $1::timestamp, NULL
+			FROM chosen s
+			LEFT JOIN %s a ON a.nr_rnal = s.nr_rnal
+			RETURNING nr_rnal, (created_at = $1::timestamp) AS inserted, (updated_at = $1::timestamp) AS changed
+		)
+	`, stagingTable, stagedIndexColumn, nextTable, columns, nextTable, staged,
+		strings.Join(current, ", "), strings.Join(incoming, ", "),
+		liveTable), stats, runTime)
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", nextTable, err)
+	}
+
+	return nil
+}
+
+// carryOverMissing copies the live registrations that are absent from the input
+// into alojamentos_next, deregistering the active ones unless they are listed
+// in keep. It returns how many were newly deregistered.
+func carryOverMissing(tx *sql.Tx, runTime time.Time, keep []int) (int, error) {
+	columns := strings.Join(alojamentoColumns, ", ")
+	live := "a." + strings.Join(alojamentoColumns, ", a.")
+
+	result, err := tx.Exec(fmt.Sprintf(`
+		INSERT INTO %s (id, %s, created_at, updated_at, last_seen_at, deregistered_at)
+		SELECT a.id, %s, a.created_at, a.updated_at, a.last_seen_at,
+		       CASE WHEN a.nr_rnal = ANY($2) THEN a.deregistered_at ELSE COALESCE(a.deregistered_at, $1) END
+		FROM %s a
+		WHERE NOT EXISTS (SELECT 1 FROM %s n WHERE n.nr_rnal = a.nr_rnal)
+	`, nextTable, columns, live, liveTable, nextTable), runTime, pq.Array(keep))
+	if err != nil {
+		return 0, fmt.Errorf("failed to carry over missing records: %w", err)
+	}
+
+	var deregistered int
+	err = tx.QueryRow(
+		fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE deregistered_at = $1", nextTable), runTime,
+	).Scan(&deregistered)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count deregistered records: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n > 0 {
+		log.Printf("Carried over %d records missing from the input", n)
+	}
+	return deregistered, nil
+}
+
+// buildNextIndexes recreates every index of the live table, including the
+// primary key and unique constraints, on alojamentos_next
+func buildNextIndexes(tx *sql.Tx) error {
+	rows, err := tx.Query(`
+		SELECT i.indexname, i.indexdef, COALESCE(c.contype, '')
+		FROM pg_indexes i
+		LEFT JOIN pg_constraint c
+		       ON c.conname = i.indexname AND c.conrelid = to_regclass(i.schemaname || '.' || i.tablename)
+		WHERE i.schemaname = current_schema() AND i.tablename = $1
+		ORDER BY i.indexname
+	`, liveTable)
+	if err != nil {
+		return fmt.Errorf("failed to read %s indexes: %w", liveTable, err)
+	}
+
+	type index struct{ name, def, constraint string }
+	var indexes []index
+	for rows.Next() {
+		var idx index
+		if err := rows.Scan(&idx.name, &idx.def, &idx.constraint); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan %s indexes: %w", liveTable, err)
+		}
+		indexes = append(indexes, idx)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read %s indexes: %w", liveTable, err)
+	}
+
+	suffix := strings.TrimPrefix(nextTable, liveTable)
+	for _, idx := range indexes {
+		name := idx.name + suffix
+		def, ok := rewriteIndexDef(idx.def, idx.name, name)
+		if !ok {
+			return fmt.Errorf("cannot rebuild index %s: unexpected definition %q", idx.name, idx.def)
+		}
+
+		start := time.Now()
+		if _, err := tx.Exec(def); err != nil {
+			return fmt.Errorf("failed to build index %s: %w", name, err)
+		}
+
+		switch idx.constraint {
+		case "p":
+			_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s PRIMARY KEY USING INDEX %s", nextTable, name, name))
+		case "u":
+			_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s UNIQUE USING INDEX %s", nextTable, name, name))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to add constraint %s: %w", name, err)
+		}
+
+		log.Printf("Built index %s in %s", name, time.Since(start))
+	}
+
+	return nil
+}
+
+// rewriteIndexDef points a pg_indexes definition of the live table at
+// alojamentos_next under a new index name
+func rewriteIndexDef(def, name, newName string) (string, bool) {
+	head, tail, ok := strings.Cut(def, " INDEX "+name+" ON ")
+	if !ok {
+		return "", false
+	}
+
+	// The table may be schema-qualified, e.g. "public.alojamentos USING btree (...)"
+	table, rest, ok := strings.Cut(tail, " ")
+	if !ok || (table != liveTable && !strings.HasSuffix(table, "."+liveTable)) {
+		return "", false
+	}
+	table = strings.TrimSuffix(table, liveTable) + nextTable
+
+	return head + " INDEX " + newName + " ON " + table + " " + rest, true
+}
+
+// checkNextTable refuses the swap when alojamentos_next holds fewer active
+// registrations than minRatio times the live table
+func checkNextTable(db *sql.DB, minRatio float64) error {
+	var next, live int
+	err := db.QueryRow(fmt.Sprintf(`
+		SELECT (SELECT COUNT(*) FROM %s WHERE deregistered_at IS NULL),
+		       (SELECT COUNT(*) FROM %s WHERE deregistered_at IS NULL)
+	`, nextTable, liveTable)).Scan(&next, &live)
+	if err != nil {
+		return fmt.Errorf("failed to count records: %w", err)
+	}
+
+	log.Printf("Sanity check: %d active records in %s, %d in %s", next, nextTable, live, liveTable)
+
+	if next == 0 {
+		return errors.New("sanity check failed: the new table has no active records")
+	}
+	if live > 0 && float64(next) < minRatio*float64(live) {
+		return fmt.Errorf("sanity check failed: %d active records is below %.0f%% of the current %d",
+			next, minRatio*100, live)
+	}
+	return nil
+}
+
+// swapTables runs the renames in fn in one transaction, after taking an
+// exclusive lock on the live table so requests see either the old or the new
+// data, never a mix
+func swapTables(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(fmt.Sprintf("LOCK TABLE %s IN ACCESS EXCLUSIVE MODE", liveTable)); err != nil {
+		return fmt.Errorf("failed to lock %s: %w", liveTable, err)
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit swap: %w", err)
+	}
+	return nil
+}
+
+// renameTable renames a table together with its indexes, constraints and id
+// sequence, replacing the suffix the old table name has after alojamentos
+// with the suffix of the new one
+func renameTable(tx *sql.Tx, from, to string) error {
+	fromSuffix := strings.TrimPrefix(from, liveTable)
+	toSuffix := strings.TrimPrefix(to, liveTable)
+
+	rows, err := tx.Query(`
+		SELECT indexname FROM pg_indexes
+		WHERE schemaname = current_schema() AND tablename = $1
+	`, from)
+	if err != nil {
+		return fmt.Errorf("failed to read %s indexes: %w", from, err)
+	}
+	var indexes []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan %s indexes: %w", from, err)
+		}
+		indexes = append(indexes, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read %s indexes: %w", from, err)
+	}
+
+	// Renaming an index that backs a constraint renames the constraint too
+	for _, name := range indexes {
+		newName := strings.TrimSuffix(name, fromSuffix) + toSuffix
+		if _, err := tx.Exec(fmt.Sprintf("ALTER INDEX %s RENAME TO %s", pq.QuoteIdentifier(name), pq.QuoteIdentifier(newName))); err != nil {
+			return fmt.Errorf("failed to rename index %s: %w", name, err)
+		}
+	}
+
+	var seq sql.NullString
+	if err := tx.QueryRow("SELECT pg_get_serial_sequence($1, 'id')", from).Scan(&seq); err != nil {
+		return fmt.Errorf("failed to find the %s id sequence: %w", from, err)
+	}
+	if seq.Valid {
+		if _, err := tx.Exec(fmt.Sprintf("ALTER SEQUENCE %s RENAME TO %s", seq.String, pq.QuoteIdentifier(to+"_id_seq"))); err != nil {
+			return fmt.Errorf("failed to rename the %s id sequence: %w", from, err)
+		}
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s RENAME TO %s", from, to)); err != nil {
+		return fmt.Errorf("failed to rename %s to %s: %w", from, to, err)
+	}
+	return nil
+}
+
+// rollbackSwap puts alojamentos_previous back in place. The table it replaces
+// becomes alojamentos_previous, so running it again undoes the rollback.
+func rollbackSwap(db *sql.DB) error {
+	var exists bool
+	if err := db.QueryRow("SELECT to_regclass($1) IS NOT NULL", previousTable).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to look up %s: %w", previousTable, err)
+	}
+	if !exists {
+		return fmt.Errorf("no %s table to roll back to", previousTable)
+	}
+
+	// Migrations only alter the live table, so a table kept from before a
+	// migration lacks columns the API reads
+	if err := checkSameColumns(db, previousTable, liveTable); err != nil {
+		return fmt.Errorf("cannot roll back to %s: %w", previousTable, err)
+	}
+
+	return swapTables(db, func(tx *sql.Tx) error {
+		if err := renameTable(tx, liveTable, swapTable); err != nil {
+			return err
+		}
+		if err := renameTable(tx, previousTable, liveTable); err != nil {
+			return err
+		}
+		return renameTable(tx, swapTable, previousTable)
+	})
+}
+
+// checkSameColumns checks that two tables have the same column names and types
+func checkSameColumns(db *sql.DB, table, reference string) error {
+	columns := func(name string) (map[string]string, error) {
+		rows, err := db.Query(`
+			SELECT column_name, data_type
+			FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = $1
+		`, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the columns of %s: %w", name, err)
+		}
+		defer rows.Close()
+
+		types := make(map[string]string)
+		for rows.Next() {
+			var column, dataType string
+			if err := rows.Scan(&column, &dataType); err != nil {
+				return nil, fmt.Errorf("failed to read the columns of %s: %w", name, err)
+			}
+			types[column] = dataType
+		}
+		return types, rows.Err()
+	}
+
+	have, err := columns(table)
+	if err != nil {
+		return err
+	}
+	want, err := columns(reference)
+	if err != nil {
+		return err
+	}
+
+	var differences []string
+	for column, dataType := range want {
+		switch got, ok := have[column]; {
+		case !ok:
+			differences = append(differences, "missing "+column)
+		case got != dataType:
+			differences = append(differences, fmt.Sprintf("%s is %s instead of %s", column, got, dataType))
+		}
+	}
+	for column := range have {
+		if _, ok := want[column]; !ok {
+			differences = append(differences, "extra "+column)
+		}
+	}
+	if len(differences) > 0 {
+		sort.Strings(differences)
+		return fmt.Errorf("its columns differ from %s (%s); it predates a schema migration", reference, strings.Join(differences, ", "))
+	}
+	return nil
+}
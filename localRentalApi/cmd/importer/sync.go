@@ -0,0 +1,106 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// syncReturning lists the columns returned after syncConflictClause, which
+// report whether the row was inserted and whether it changed
+const syncReturning = "(xmax = 0) AS inserted, (updated_at = last_seen_at) AS changed"
+
+// syncConflictClause returns the ON CONFLICT clause used in sync mode. Existing
+// rows get every tracked column overwritten and last_seen_at stamped; updated_at
+// only moves when a tracked value actually changed or the row was deregistered.
+func syncConflictClause() string {
+	var set, current, incoming []string
+	for _, col := range alojamentoColumns {
+		if col == "nr_rnal" {
+			continue
+		}
+		set = append(set, fmt.Sprintf("%s = EXCLUDED.%s", col, col))
+		current = append(current, "alojamentos."+col)
+		incoming = append(incoming, "EXCLUDED."+col)
+	}
+
+	return fmt.Sprintf(`
+		ON CONFLICT (nr_rnal) DO UPDATE SET
+			%s,
+			updated_at = CASE
+				WHEN alojamentos.deregistered_at IS NOT NULL
+				  OR (%s) IS DISTINCT FROM (%s)
+				THEN EXCLUDED.last_seen_at
+				ELSE alojamentos.updated_at
+			END,
+			last_seen_at = EXCLUDED.last_seen_at,
+			deregistered_at = NULL
+	`, strings.Join(set, ",\n\t\t\t"), strings.Join(current, ", "), strings.Join(incoming, ", "))
+}
+
+// syncTimestamp returns the database's current timestamp. Every row touched by a
+// sync run is stamped with this value so that rows missing from the input can be
+// found afterwards by comparing last_seen_at.
+func syncTimestamp(db *sql.DB) (time.Time, error) {
+	var ts time.Time
+	if err := db.QueryRow("SELECT LOCALTIMESTAMP").Scan(&ts); err != nil {
+		return ts, fmt.Errorf("failed to read database timestamp: %w", err)
+	}
+	return ts, nil
+}
+
+// deregisterMissing soft-deletes every active row that was not seen by the sync
+// run started at runStart. Registrations listed in keep were present in the
+// input but rejected, so they are left alone.
+func deregisterMissing(db execer, runStart time.Time, keep []int) (int, error) {
+	// A nil slice would be sent as NULL, which matches nothing
+	if keep == nil {
+		keep = []int{}
+	}
+
+	result, err := db.Exec(`
+		UPDATE alojamentos
+		SET deregistered_at = $1
+		WHERE deregistered_at IS NULL
+		  AND (last_seen_at IS NULL OR last_seen_at < $1)
+		  AND NOT (nr_rnal = ANY($2))
+	`, runStart, pq.Array(keep))
+	if err != nil {
+		return 0, fmt.Errorf("failed to deregister missing records: %w", err)
+	}
+
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// finishSync deregisters missing records once the whole input has been applied.
+// It does nothing when the input was empty or records failed, since rows that
+// are present in the source would otherwise be deregistered.
+func finishSync(db execer, runStart time.Time, keep []int, stats *importStats) error {
+	if stats.Total == 0 {
+		log.Println("Warning: Input contained no records, skipping deregistration")
+		return nil
+	}
+
+	if stats.Failed > 0 {
+		log.Printf("Warning: %d records failed, skipping deregistration", stats.Failed)
+		return nil
+	}
+
+	n, err := deregisterMissing(db, runStart, keep)
+	if err != nil {
+		return err
+	}
+	stats.Deregistered = n
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	pkgValidator "localRental/pkg/validator"
+)
+
+// featureRules holds the values checked before a feature is written, with the
+// rules that apply to them. A missing geometry decodes as (0,0), which the
+// required rules reject.
+type featureRules struct {
+	NrRNAL       int     `validate:"gt=0"`
+	Latitude     float64 `validate:"required,latitude"`
+	Longitude    float64 `validate:"required,longitude"`
+	CodigoPostal string  `validate:"omitempty,pt_postal_code"`
+	Email        string  `validate:"omitempty,email"`
+	NrUtentes    int     `validate:"gte=0"`
+}
+
+// deadLetter is one line of the dead-letter file
+type deadLetter struct {
+	Index   int                       `json:"index"`
+	Rule    string                    `json:"rule"`
+	Errors  []pkgValidator.FieldError `json:"errors"`
+	Feature Feature                   `json:"feature"`
+}
+
+// validatingSource drops features that break a rule, writing them to the
+// dead-letter output, and passes the rest through. Coordinates are checked as
+// they will be written, after geocoding. check may be called from
+// several goroutines; the rejection bookkeeping is guarded by mu.
+type validatingSource struct {
+	source     featureSource
+	deadLetter *json.Encoder
+	geocoder   *postalGeocoder
+	index      int
+
+	mu       sync.Mutex
+	rejected int
+	// rejectedRNAL keeps the registrations of rejected features so that a sync
+	// run does not deregister them
+	rejectedRNAL []int
+	// rejectedFiles counts the rejections of each input file, by position
+	rejectedFiles []int
+}
+
+func newValidatingSource(source featureSource, deadLetter io.Writer, geocoder *postalGeocoder) *validatingSource {
+	v := &validatingSource{source: source, geocoder: geocoder}
+	if deadLetter != nil {
+		v.deadLetter = json.NewEncoder(deadLetter)
+	}
+	return v
+}
+
+// Next returns the next feature that passes validation
+func (v *validatingSource) Next() (Feature, error) {
+	for {
+		feature, err := v.source.Next()
+		if err != nil {
+			return feature, err
+		}
+		index := v.index
+		v.index++
+
+		ok, err := v.check(index, filePosition(v.source), feature)
+		if err != nil || ok {
+			return feature, err
+		}
+	}
+}
+
+// check reports whether the feature at index, read from the input file at
+// position file, passes validation, recording it as rejected otherwise
+func (v *validatingSource) check(index, file int, feature Feature) (bool, error) {
+	fieldErrors := validateFeature(feature, v.geocoder)
+	if len(fieldErrors) == 0 {
+		return true, nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.rejected++
+	for len(v.rejectedFiles) <= file {
+		v.rejectedFiles = append(v.rejectedFiles, 0)
+	}
+	v.rejectedFiles[file]++
+	if feature.Properties.NrRNAL > 0 {
+		v.rejectedRNAL = append(v.rejectedRNAL, feature.Properties.NrRNAL)
+	}
+
+	if v.deadLetter == nil {
+		return false, nil
+	}
+
+	entry := deadLetter{
+		Index:   index,
+		Rule:    fieldErrors[0].Field + "." + fieldErrors[0].Rule,
+		Errors:  fieldErrors,
+		Feature: feature,
+	}
+	if err := v.deadLetter.Encode(entry); err != nil {
+		return false, fmt.Errorf("failed to write dead letter: %w", err)
+	}
+	return false, nil
+}
+
+// validateFeature returns the rules the feature breaks
+func validateFeature(feature Feature, geocoder *postalGeocoder) []pkgValidator.FieldError {
+	loc := geocoder.locate(feature, false)
+
+	rules := featureRules{
+		NrRNAL:       feature.Properties.NrRNAL,
+		Latitude:     loc.lat,
+		Longitude:    loc.lng,
+		CodigoPostal: feature.Properties.CodigoPostal,
+		Email:        feature.Properties.Email,
+		NrUtentes:    feature.Properties.NrUtentes,
+	}
+
+	fieldErrors := append([]pkgValidator.FieldError(nil), feature.parseErrors...)
+	if err := pkgValidator.Validate(rules); err != nil {
+		fieldErrors = append(fieldErrors, pkgValidator.FieldErrors(err)...)
+	}
+	return fieldErrors
+}
+
+// checkRejectRatio fails the run when too large a share of the input was rejected
+func checkRejectRatio(stats importStats, maxRatio float64) error {
+	if stats.Total == 0 || maxRatio < 0 {
+		return nil
+	}
+
+	ratio := float64(stats.Rejected) / float64(stats.Total)
+	if ratio > maxRatio {
+		return fmt.Errorf("rejected %d of %d records (%.1f%%), above the %.1f%% threshold",
+			stats.Rejected, stats.Total, ratio*100, maxRatio*100)
+	}
+
+	if stats.Rejected > 0 {
+		log.Printf("Warning: Rejected %d of %d records (%.1f%%)", stats.Rejected, stats.Total, ratio*100)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"localRental/pkg/database"
+
+	_ "github.com/lib/pq"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: migrate [flags] <command>
+
+Commands:
+  up           Apply all pending migrations
+  down [n]     Revert the last n applied migrations (default: 1)
+  status       List migrations and whether they have been applied
+
+Flags:
+`)
+	flag.PrintDefaults()
+}
+
+func main() {
+	dbConn := flag.String("db", "postgres://localhost/alojamentos?sslmode=disable", "PostgreSQL connection string")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	db, err := sql.Open("postgres", *dbConn)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Test connection
+	if err := db.Ping(); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	switch command := flag.Arg(0); command {
+	case "up":
+		applied, err := database.MigrateUp(db)
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		if len(applied) == 0 {
+			log.Println("Schema is up to date")
+		}
+
+	case "down":
+		steps := 1
+		if flag.NArg() > 1 {
+			if steps, err = strconv.Atoi(flag.Arg(1)); err != nil || steps < 1 {
+				log.Fatalf("Invalid number of migrations to revert: %q", flag.Arg(1))
+			}
+		}
+		reverted, err := database.MigrateDown(db, steps)
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		if len(reverted) == 0 {
+			log.Println("No applied migrations to revert")
+		}
+
+	case "status":
+		statuses, err := database.MigrationStatuses(db)
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-30s %s\n", s.Version, s.Name, applied)
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", command)
+		usage()
+		os.Exit(2)
+	}
+}
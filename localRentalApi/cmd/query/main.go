@@ -24,7 +24,7 @@ func main() {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	fmt.Println("=== Alojamentos Locais Database Queries ===\n")
+	fmt.Print("=== Alojamentos Locais Database Queries ===\n\n")
 
 	totalCount(db)
 
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"log/slog"
@@ -12,6 +13,7 @@ import (
 	"localRental/middleware"
 	"localRental/pkg/config"
 	"localRental/pkg/database"
+	"localRental/pkg/spatial"
 
 	httpSwagger "github.com/swaggo/http-swagger"
 )
@@ -38,10 +40,24 @@ func Execute() {
 	}
 	defer database.Close(db)
 
+	// Refuse to serve against a schema older than this binary expects
+	if err := database.CheckSchema(db); err != nil {
+		log.Fatalf("Database schema check failed: %v", err)
+	}
+
 	// Initialize router and logger
 	mux := http.NewServeMux()
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
+	// Build the spatial index for nearest neighbour queries, and rebuild it
+	// whenever an import finishes
+	spatialIndex := spatial.NewStore(db)
+	if err := spatialIndex.Load(context.Background()); err != nil {
+		log.Fatalf("Failed to build spatial index: %v", err)
+	}
+	logger.Info("spatial index built", "points", spatialIndex.Index().Len())
+	go spatialIndex.Watch(context.Background(), cfg.SpatialIndex.RefreshInterval, logger)
+
 	// Health check endpoints (no authentication required)
 	mux.HandleFunc("GET /health", handlers.HealthCheck)
 	mux.HandleFunc("GET /ready", handlers.ReadinessCheck)
@@ -49,17 +65,31 @@ func Execute() {
 	// Register routes - alojamentos endpoints
 	mux.HandleFunc("GET /alojamentos", handlers.GetAlojamentos)
 	mux.HandleFunc("GET /alojamentos/{id}", handlers.GetAlojamentoByID)
+	mux.HandleFunc("GET /alojamentos/{id}/history", handlers.GetAlojamentoHistory)
+	mux.HandleFunc("GET /alojamentos/{id}/nearby", handlers.GetAlojamentoNearby)
+	mux.HandleFunc("GET /alojamentos/nearest", handlers.GetAlojamentosNearest)
 	mux.HandleFunc("GET /alojamentos/search", handlers.SearchAlojamentos)
+	mux.HandleFunc("POST /alojamentos/search", handlers.SearchAlojamentosByShape)
 	mux.HandleFunc("GET /alojamentos/stats", handlers.GetAlojamentosStats)
+	mux.HandleFunc("GET /alojamentos/clusters", handlers.GetAlojamentosClusters)
+
+	// Register routes - vector tiles ({y} is the row followed by .mvt)
+	mux.HandleFunc("GET /tiles/{z}/{x}/{y}", handlers.GetTile)
+
+	// Register routes - import run ledger endpoints
+	mux.HandleFunc("GET /imports", handlers.GetImportRuns)
+	mux.HandleFunc("GET /imports/{id}", handlers.GetImportRunByID)
 
 	// Swagger documentation
 	mux.HandleFunc("/swagger/", httpSwagger.WrapHandler)
 
-	// Apply middleware chain: DatabaseMiddleware -> RateLimit -> ServerHeader -> LogRequest
+	// Apply middleware chain: DatabaseMiddleware -> SpatialIndexMiddleware -> RateLimit -> ServerHeader -> LogRequest
 	server := middleware.DatabaseMiddleware(db)(
-		middleware.RateLimit(cfg.RateLimit.RequestsPerSecond, cfg.RateLimit.Burst)(
-			middleware.ServerHeader(
-				middleware.LogRequest(logger, mux),
+		middleware.SpatialIndexMiddleware(spatialIndex)(
+			middleware.RateLimit(cfg.RateLimit.RequestsPerSecond, cfg.RateLimit.Burst)(
+				middleware.ServerHeader(
+					middleware.LogRequest(logger, mux),
+				),
 			),
 		),
 	)
@@ -53,6 +53,18 @@ const docTemplate = `{
                         "description": "Sort order (asc, desc)",
                         "name": "order",
                         "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include deregistered accommodations (default: false)",
+                        "name": "include_deregistered",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Return accommodations as they were at the end of this date (YYYY-MM-DD)",
+                        "name": "as_of",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -77,6 +89,186 @@ const docTemplate = `{
                 }
             }
         },
+        "/alojamentos/clusters": {
+            "get": {
+                "description": "Group the accommodations matching the search filters into clusters on a grid of 64 pixel cells at the given zoom, with their count, centroid and count per modalidade. Cells holding a single accommodation, and every accommodation beyond zoom 16, are returned as points. Results are cached per filters and zoom until the next import.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "alojamentos"
+                ],
+                "summary": "Get map clusters",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Visible box as min_lng,min_lat,max_lng,max_lat",
+                        "name": "bbox",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Map zoom level (0-22)",
+                        "name": "zoom",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by parish (DICOFRE code or name)",
+                        "name": "freguesia",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by municipality (DICOFRE code or name)",
+                        "name": "concelho",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by district (DICOFRE code or name)",
+                        "name": "distrito",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by accommodation type",
+                        "name": "modalidade",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by email",
+                        "name": "email",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum capacity",
+                        "name": "min_capacity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum capacity",
+                        "name": "max_capacity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Latitude of the radius filter centre (with lng and radius_m)",
+                        "name": "lat",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Longitude of the radius filter centre (with lat and radius_m)",
+                        "name": "lng",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Radius in metres (up to 100000)",
+                        "name": "radius_m",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include deregistered accommodations (default: false)",
+                        "name": "include_deregistered",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cluster the records as of the end of this date (YYYY-MM-DD)",
+                        "name": "as_of",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ClustersResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/alojamentos/nearest": {
+            "get": {
+                "description": "List the active accommodations nearest to a point, nearest first, from the in-memory spatial index",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "alojamentos"
+                ],
+                "summary": "Get the accommodations nearest a point",
+                "parameters": [
+                    {
+                        "type": "number",
+                        "description": "Latitude",
+                        "name": "lat",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "number",
+                        "description": "Longitude",
+                        "name": "lng",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of accommodations (default: 10, max: 100)",
+                        "name": "k",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.NearbyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/alojamentos/search": {
             "get": {
                 "description": "Search accommodations with various filters and pagination",
@@ -105,7 +297,7 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Sort field (id, nr_rnal, denominacao, concelho, distrito, created_at)",
+                        "description": "Sort field (id, nr_rnal, denominacao, concelho, distrito, created_at, distance)",
                         "name": "sort",
                         "in": "query"
                     },
@@ -117,13 +309,19 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Filter by municipality",
+                        "description": "Filter by parish, as a DICOFRE code or a name (case- and accent-insensitive)",
+                        "name": "freguesia",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by municipality, as a DICOFRE code or a name (case- and accent-insensitive)",
                         "name": "concelho",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Filter by district",
+                        "description": "Filter by district, as a DICOFRE code or a name (case- and accent-insensitive)",
                         "name": "distrito",
                         "in": "query"
                     },
@@ -174,6 +372,80 @@ const docTemplate = `{
                         "description": "Maximum longitude",
                         "name": "max_lng",
                         "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Latitude of the radius search centre (with lng and radius_m)",
+                        "name": "lat",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Longitude of the radius search centre (with lat and radius_m)",
+                        "name": "lng",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Radius search distance in metres (max: 100000); results carry distance_m",
+                        "name": "radius_m",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include deregistered accommodations (default: false)",
+                        "name": "include_deregistered",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Search accommodations as they were at the end of this date (YYYY-MM-DD)",
+                        "name": "as_of",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.PaginatedResponse-models_AlojamentoResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Search accommodations inside a GeoJSON Polygon or MultiPolygon (holes supported), combined with the filters of the GET search, which are given as body fields",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "alojamentos"
+                ],
+                "summary": "Search accommodations within a polygon",
+                "parameters": [
+                    {
+                        "description": "Search filters and geometry",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SearchRequest"
+                        }
                     }
                 ],
                 "responses": {
@@ -211,6 +483,20 @@ const docTemplate = `{
                     "alojamentos"
                 ],
                 "summary": "Get accommodation statistics",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Include deregistered accommodations (default: false)",
+                        "name": "include_deregistered",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Compute statistics as of the end of this date (YYYY-MM-DD)",
+                        "name": "as_of",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -218,6 +504,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.StatsResponse"
                         }
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -247,6 +539,12 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return the accommodation even if it was deregistered (default: false)",
+                        "name": "include_deregistered",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -276,48 +574,603 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "models.AlojamentoResponse": {
-            "type": "object",
-            "properties": {
-                "codigo_postal": {
-                    "type": "string"
-                },
-                "concelho": {
-                    "type": "string"
-                },
-                "created_at": {
-                    "type": "string"
-                },
-                "data_abertura_publico": {
-                    "type": "string"
-                },
-                "data_registo": {
-                    "type": "string"
-                },
-                "denominacao": {
-                    "type": "string"
-                },
-                "distrito": {
-                    "type": "string"
-                },
-                "email": {
-                    "type": "string"
-                },
+        },
+        "/alojamentos/{id}/history": {
+            "get": {
+                "description": "List every recorded version of an accommodation, oldest first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "alojamentos"
+                ],
+                "summary": "Get accommodation history",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Accommodation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.AlojamentoHistoryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/alojamentos/{id}/nearby": {
+            "get": {
+                "description": "List the active accommodations nearest to an accommodation, nearest first, from the in-memory spatial index",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "alojamentos"
+                ],
+                "summary": "Get nearby accommodations",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Accommodation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of neighbours (default: 10, max: 100)",
+                        "name": "k",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.NearbyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/health": {
+            "get": {
+                "description": "Returns the health status of the service",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Health check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.HealthResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/imports": {
+            "get": {
+                "description": "Get a paginated list of data import runs, most recent first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "imports"
+                ],
+                "summary": "List import runs",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page number (default: 1)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Items per page (default: 20, max: 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by status (running, succeeded, failed)",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.PaginatedResponse-models_ImportRunResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/imports/{id}": {
+            "get": {
+                "description": "Get a single data import run by its ID, with the counts of each input file",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "imports"
+                ],
+                "summary": "Get import run by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Import run ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ImportRunResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/ready": {
+            "get": {
+                "description": "Returns the readiness status of the service including database connectivity and schema version",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ReadinessResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ReadinessResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/tiles/{z}/{x}/{y}.mvt": {
+            "get": {
+                "description": "Mapbox Vector Tile of the accommodations matching the search filters, as points in an \"alojamentos\" layer with id, modalidade, nr_utentes and concelho properties. Up to zoom 12 a tile keeps one point, the lowest id, per pixel of a 256 pixel tile, and no tile holds more than 50000 points. Tiles are cached until the next import.",
+                "produces": [
+                    "application/vnd.mapbox-vector-tile"
+                ],
+                "tags": [
+                    "tiles"
+                ],
+                "summary": "Get a vector tile of accommodations",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Zoom level (0-22)",
+                        "name": "z",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Tile column",
+                        "name": "x",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Tile row, followed by .mvt",
+                        "name": "y",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by parish (DICOFRE code or name)",
+                        "name": "freguesia",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by municipality (DICOFRE code or name)",
+                        "name": "concelho",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by district (DICOFRE code or name)",
+                        "name": "distrito",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by accommodation type",
+                        "name": "modalidade",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by email",
+                        "name": "email",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum capacity",
+                        "name": "min_capacity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum capacity",
+                        "name": "max_capacity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Latitude of the radius filter centre (with lng and radius_m)",
+                        "name": "lat",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Longitude of the radius filter centre (with lat and radius_m)",
+                        "name": "lng",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Radius in metres (up to 100000)",
+                        "name": "radius_m",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include deregistered accommodations (default: false)",
+                        "name": "include_deregistered",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Show the records as of the end of this date (YYYY-MM-DD)",
+                        "name": "as_of",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "handlers.HealthResponse": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.ReadinessResponse": {
+            "type": "object",
+            "properties": {
+                "database": {
+                    "type": "string"
+                },
+                "schema": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AlojamentoHistoryResponse": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "versions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AlojamentoVersionResponse"
+                    }
+                }
+            }
+        },
+        "models.AlojamentoResponse": {
+            "type": "object",
+            "properties": {
+                "codigo_postal": {
+                    "type": "string"
+                },
+                "concelho": {
+                    "type": "string"
+                },
+                "concelho_code": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "data_abertura_publico": {
+                    "type": "string"
+                },
+                "data_registo": {
+                    "type": "string"
+                },
+                "denominacao": {
+                    "type": "string"
+                },
+                "deregistered_at": {
+                    "type": "string"
+                },
+                "distance_m": {
+                    "description": "DistanceM is the distance from the search point, in radius searches and\nnearest neighbour queries",
+                    "type": "number"
+                },
+                "distrito": {
+                    "type": "string"
+                },
+                "distrito_code": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "endereco": {
+                    "type": "string"
+                },
+                "freguesia": {
+                    "type": "string"
+                },
+                "freguesia_code": {
+                    "type": "string"
+                },
+                "geocode_source": {
+                    "description": "GeocodeSource is \"original\" when the coordinates come from the source\ndata, or \"postal_centroid\" when they were replaced by the centroid of the\npostal code, in which case OriginalLatitude and OriginalLongitude hold\nthe source coordinates, if there were any",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "latitude": {
+                    "type": "number"
+                },
+                "latlong_distance_m": {
+                    "type": "number"
+                },
+                "latlong_mismatch": {
+                    "type": "boolean"
+                },
+                "localidade": {
+                    "type": "string"
+                },
+                "longitude": {
+                    "type": "number"
+                },
+                "modalidade": {
+                    "type": "string"
+                },
+                "nr_rnal": {
+                    "type": "integer"
+                },
+                "nr_utentes": {
+                    "type": "integer"
+                },
+                "original_latitude": {
+                    "type": "number"
+                },
+                "original_longitude": {
+                    "type": "number"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AlojamentoVersionResponse": {
+            "type": "object",
+            "properties": {
+                "codigo_postal": {
+                    "type": "string"
+                },
+                "concelho": {
+                    "type": "string"
+                },
+                "concelho_code": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "data_abertura_publico": {
+                    "type": "string"
+                },
+                "data_registo": {
+                    "type": "string"
+                },
+                "denominacao": {
+                    "type": "string"
+                },
+                "deregistered_at": {
+                    "type": "string"
+                },
+                "distance_m": {
+                    "description": "DistanceM is the distance from the search point, in radius searches and\nnearest neighbour queries",
+                    "type": "number"
+                },
+                "distrito": {
+                    "type": "string"
+                },
+                "distrito_code": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
                 "endereco": {
                     "type": "string"
                 },
                 "freguesia": {
                     "type": "string"
                 },
+                "freguesia_code": {
+                    "type": "string"
+                },
+                "geocode_source": {
+                    "description": "GeocodeSource is \"original\" when the coordinates come from the source\ndata, or \"postal_centroid\" when they were replaced by the centroid of the\npostal code, in which case OriginalLatitude and OriginalLongitude hold\nthe source coordinates, if there were any",
+                    "type": "string"
+                },
                 "id": {
                     "type": "integer"
                 },
                 "latitude": {
                     "type": "number"
                 },
+                "latlong_distance_m": {
+                    "type": "number"
+                },
+                "latlong_mismatch": {
+                    "type": "boolean"
+                },
                 "localidade": {
                     "type": "string"
                 },
@@ -332,12 +1185,99 @@ const docTemplate = `{
                 },
                 "nr_utentes": {
                     "type": "integer"
+                },
+                "original_latitude": {
+                    "type": "number"
+                },
+                "original_longitude": {
+                    "type": "number"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "valid_from": {
+                    "type": "string"
+                },
+                "valid_to": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ClusterPointResponse": {
+            "type": "object",
+            "properties": {
+                "denominacao": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "latitude": {
+                    "type": "number"
+                },
+                "longitude": {
+                    "type": "number"
+                },
+                "modalidade": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ClusterResponse": {
+            "type": "object",
+            "properties": {
+                "bounds": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "by_modalidade": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "count": {
+                    "type": "integer"
+                },
+                "latitude": {
+                    "type": "number"
+                },
+                "longitude": {
+                    "type": "number"
+                }
+            }
+        },
+        "models.ClustersResponse": {
+            "type": "object",
+            "properties": {
+                "clusters": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ClusterResponse"
+                    }
+                },
+                "points": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ClusterPointResponse"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "zoom": {
+                    "type": "integer"
                 }
             }
         },
         "models.DistrictStats": {
             "type": "object",
             "properties": {
+                "code": {
+                    "type": "string"
+                },
                 "count": {
                     "type": "integer"
                 },
@@ -360,9 +1300,116 @@ const docTemplate = `{
                 }
             }
         },
+        "models.GeoJSONGeometry": {
+            "type": "object",
+            "required": [
+                "coordinates",
+                "type"
+            ],
+            "properties": {
+                "coordinates": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ImportRunFileResponse": {
+            "type": "object",
+            "properties": {
+                "failed_count": {
+                    "type": "integer"
+                },
+                "feature_count": {
+                    "type": "integer"
+                },
+                "imported_count": {
+                    "type": "integer"
+                },
+                "rejected_count": {
+                    "type": "integer"
+                },
+                "skipped_count": {
+                    "type": "integer"
+                },
+                "source_file": {
+                    "type": "string"
+                },
+                "source_sha256": {
+                    "type": "string"
+                },
+                "updated_count": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.ImportRunResponse": {
+            "type": "object",
+            "properties": {
+                "deregistered_count": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "failed_count": {
+                    "type": "integer"
+                },
+                "feature_count": {
+                    "type": "integer"
+                },
+                "files": {
+                    "description": "Files is only included when fetching a single run",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ImportRunFileResponse"
+                    }
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "imported_count": {
+                    "type": "integer"
+                },
+                "mode": {
+                    "type": "string"
+                },
+                "rejected_count": {
+                    "type": "integer"
+                },
+                "skipped_count": {
+                    "type": "integer"
+                },
+                "source_file": {
+                    "type": "string"
+                },
+                "source_sha256": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_count": {
+                    "type": "integer"
+                }
+            }
+        },
         "models.MunicipalityStats": {
             "type": "object",
             "properties": {
+                "code": {
+                    "type": "string"
+                },
                 "concelho": {
                     "type": "string"
                 },
@@ -371,6 +1418,26 @@ const docTemplate = `{
                 }
             }
         },
+        "models.NearbyResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AlojamentoResponse"
+                    }
+                },
+                "indexed_at": {
+                    "type": "string"
+                },
+                "latitude": {
+                    "type": "number"
+                },
+                "longitude": {
+                    "type": "number"
+                }
+            }
+        },
         "models.PaginatedResponse-models_AlojamentoResponse": {
             "type": "object",
             "properties": {
@@ -385,6 +1452,20 @@ const docTemplate = `{
                 }
             }
         },
+        "models.PaginatedResponse-models_ImportRunResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ImportRunResponse"
+                    }
+                },
+                "pagination": {
+                    "$ref": "#/definitions/models.PaginationMeta"
+                }
+            }
+        },
         "models.PaginationMeta": {
             "type": "object",
             "properties": {
@@ -402,6 +1483,96 @@ const docTemplate = `{
                 }
             }
         },
+        "models.SearchRequest": {
+            "type": "object",
+            "required": [
+                "geometry"
+            ],
+            "properties": {
+                "as_of": {
+                    "type": "string"
+                },
+                "concelho": {
+                    "type": "string"
+                },
+                "distrito": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "freguesia": {
+                    "type": "string"
+                },
+                "geometry": {
+                    "$ref": "#/definitions/models.GeoJSONGeometry"
+                },
+                "include_deregistered": {
+                    "type": "boolean"
+                },
+                "lat": {
+                    "type": "number"
+                },
+                "limit": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 1
+                },
+                "lng": {
+                    "type": "number"
+                },
+                "max_capacity": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "max_lat": {
+                    "type": "number"
+                },
+                "max_lng": {
+                    "type": "number"
+                },
+                "min_capacity": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "min_lat": {
+                    "type": "number"
+                },
+                "min_lng": {
+                    "type": "number"
+                },
+                "modalidade": {
+                    "type": "string"
+                },
+                "order": {
+                    "type": "string",
+                    "enum": [
+                        "asc",
+                        "desc"
+                    ]
+                },
+                "page": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "radius_m": {
+                    "type": "number",
+                    "maximum": 100000
+                },
+                "sort": {
+                    "type": "string",
+                    "enum": [
+                        "id",
+                        "nr_rnal",
+                        "denominacao",
+                        "concelho",
+                        "distrito",
+                        "created_at",
+                        "distance"
+                    ]
+                }
+            }
+        },
         "models.StatsResponse": {
             "type": "object",
             "properties": {
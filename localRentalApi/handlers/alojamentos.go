@@ -2,17 +2,44 @@ package handlers
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"localRental/middleware"
 	"localRental/models"
 	"localRental/pkg/database"
+	"localRental/pkg/divisions"
+	"localRental/pkg/geo"
 	pkgValidator "localRental/pkg/validator"
 )
 
+// alojamentoSelectColumns lists the columns scanned by database.Alojamento
+const alojamentoSelectColumns = `id, object_id, nr_rnal, denominacao, data_registo, data_abertura_publico,
+		       modalidade, nr_utentes, email, endereco, codigo_postal, localidade,
+		       latitude, longitude, fiabilidade_geo, freguesia, concelho, distrito,
+		       nuts_iii, nuts_ii, ert, selo_clean_safe, created_at, updated_at, deregistered_at,
+		       latlong_mismatch, latlong_distance_m, distrito_code, concelho_code, freguesia_code,
+		       geocode_source, original_latitude, original_longitude`
+
+// historySelectColumns maps an alojamentos_history version onto the columns of
+// alojamentoSelectColumns. Versions only exist while a registration is active,
+// so deregistered_at is always NULL.
+const historySelectColumns = `alojamento_id AS id, object_id, nr_rnal, denominacao, data_registo, data_abertura_publico,
+		       modalidade, nr_utentes, email, endereco, codigo_postal, localidade,
+		       latitude, longitude, fiabilidade_geo, freguesia, concelho, distrito,
+		       nuts_iii, nuts_ii, ert, selo_clean_safe, created_at, valid_from AS updated_at,
+		       NULL::timestamp AS deregistered_at, latlong_mismatch, latlong_distance_m,
+		       distrito_code, concelho_code, freguesia_code,
+		       geocode_source, original_latitude, original_longitude`
+
+// activeCondition restricts queries to registrations that have not been deregistered
+const activeCondition = "deregistered_at IS NULL"
+
 // GetAlojamentos godoc
 // @Summary      List accommodations with pagination
 // @Description  Get a paginated list of Portuguese accommodations
@@ -23,6 +50,8 @@ import (
 // @Param        limit  query  int     false  "Items per page (default: 20, max: 100)"
 // @Param        sort   query  string  false  "Sort field (id, nr_rnal, denominacao, concelho, distrito, created_at)"
 // @Param        order  query  string  false  "Sort order (asc, desc)"
+// @Param        include_deregistered  query  bool  false  "Include deregistered accommodations (default: false)"
+// @Param        as_of  query  string  false  "Return accommodations as they were at the end of this date (YYYY-MM-DD)"
 // @Success      200  {object}  models.PaginatedResponse[models.AlojamentoResponse]
 // @Failure      400  {object}  models.ErrorResponse
 // @Failure      500  {object}  models.ErrorResponse
@@ -62,6 +91,9 @@ func GetAlojamentos(w http.ResponseWriter, r *http.Request) {
 		params.Order = order
 	}
 
+	params.IncludeDeregistered = parseBoolParam(r, "include_deregistered")
+	params.AsOf = r.URL.Query().Get("as_of")
+
 	// Validate params
 	if err := pkgValidator.Validate(params); err != nil {
 		details := pkgValidator.FormatValidationError(err)
@@ -74,9 +106,17 @@ func GetAlojamentos(w http.ResponseWriter, r *http.Request) {
 		params.Limit = 100
 	}
 
+	source := alojamentosSource(params.AsOf)
+
+	// Hide deregistered accommodations unless requested
+	whereClause := ""
+	if !params.IncludeDeregistered {
+		whereClause = " WHERE " + activeCondition
+	}
+
 	// Get total count
 	var total int
-	countQuery := "SELECT COUNT(*) FROM alojamentos"
+	countQuery := "SELECT COUNT(*) FROM " + source + whereClause
 	if err := db.QueryRow(countQuery).Scan(&total); err != nil {
 		RespondWithError(w, http.StatusInternalServerError, "Failed to count records")
 		return
@@ -87,14 +127,12 @@ func GetAlojamentos(w http.ResponseWriter, r *http.Request) {
 
 	// Build query
 	query := fmt.Sprintf(`
-		SELECT id, object_id, nr_rnal, denominacao, data_registo, data_abertura_publico,
-		       modalidade, nr_utentes, email, endereco, codigo_postal, localidade,
-		       latitude, longitude, fiabilidade_geo, freguesia, concelho, distrito,
-		       nuts_iii, nuts_ii, ert, selo_clean_safe, created_at
-		FROM alojamentos
+		SELECT `+alojamentoSelectColumns+`
+		FROM %s
+		%s
 		ORDER BY %s %s
 		LIMIT $1 OFFSET $2
-	`, params.Sort, params.Order)
+	`, source, whereClause, params.Sort, params.Order)
 
 	rows, err := db.Query(query, params.Limit, offset)
 	if err != nil {
@@ -141,6 +179,7 @@ func GetAlojamentos(w http.ResponseWriter, r *http.Request) {
 // @Accept       json
 // @Produce      json
 // @Param        id   path  int  true  "Accommodation ID"
+// @Param        include_deregistered  query  bool  false  "Return the accommodation even if it was deregistered (default: false)"
 // @Success      200  {object}  models.AlojamentoResponse
 // @Failure      400  {object}  models.ErrorResponse
 // @Failure      404  {object}  models.ErrorResponse
@@ -169,14 +208,15 @@ func GetAlojamentoByID(w http.ResponseWriter, r *http.Request) {
 
 	// Query by ID
 	query := `
-		SELECT id, object_id, nr_rnal, denominacao, data_registo, data_abertura_publico,
-		       modalidade, nr_utentes, email, endereco, codigo_postal, localidade,
-		       latitude, longitude, fiabilidade_geo, freguesia, concelho, distrito,
-		       nuts_iii, nuts_ii, ert, selo_clean_safe, created_at
+		SELECT ` + alojamentoSelectColumns + `
 		FROM alojamentos
 		WHERE id = $1
 	`
 
+	if !parseBoolParam(r, "include_deregistered") {
+		query += " AND " + activeCondition
+	}
+
 	var a database.Alojamento
 	if err := a.ScanRow(db.QueryRow(query, id)); err != nil {
 		if err == sql.ErrNoRows {
@@ -190,6 +230,91 @@ func GetAlojamentoByID(w http.ResponseWriter, r *http.Request) {
 	RespondWithJSON(w, http.StatusOK, convertToResponse(a))
 }
 
+// GetAlojamentoHistory godoc
+// @Summary      Get accommodation history
+// @Description  List every recorded version of an accommodation, oldest first
+// @Tags         alojamentos
+// @Accept       json
+// @Produce      json
+// @Param        id   path  int  true  "Accommodation ID"
+// @Success      200  {object}  models.AlojamentoHistoryResponse
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      404  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.ErrorResponse
+// @Router       /alojamentos/{id}/history [get]
+func GetAlojamentoHistory(w http.ResponseWriter, r *http.Request) {
+	db, ok := middleware.GetDB(r)
+	if !ok {
+		RespondWithError(w, http.StatusInternalServerError, "Database connection not available")
+		return
+	}
+
+	// Extract ID from path
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 3 {
+		RespondWithError(w, http.StatusBadRequest, "Missing ID parameter")
+		return
+	}
+
+	idStr := pathParts[len(pathParts)-2]
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid ID parameter")
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT `+historySelectColumns+`, valid_from, valid_to
+		FROM alojamentos_history
+		WHERE alojamento_id = $1
+		ORDER BY valid_from, history_id
+	`, id)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch history")
+		return
+	}
+	defer rows.Close()
+
+	response := models.AlojamentoHistoryResponse{ID: id, Versions: []models.AlojamentoVersionResponse{}}
+	for rows.Next() {
+		var v database.AlojamentoVersion
+		if err := v.Scan(rows); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, "Failed to scan history")
+			return
+		}
+
+		version := models.AlojamentoVersionResponse{
+			AlojamentoResponse: convertToResponse(v.Alojamento),
+			ValidFrom:          v.ValidFrom,
+		}
+		if v.ValidTo.Valid {
+			version.ValidTo = &v.ValidTo.Time
+		}
+		response.Versions = append(response.Versions, version)
+	}
+
+	// Check for errors from iteration
+	if err := rows.Err(); err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Error reading history")
+		return
+	}
+
+	// A registration deregistered before history was kept has no versions
+	if len(response.Versions) == 0 {
+		var exists bool
+		if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM alojamentos WHERE id = $1)", id).Scan(&exists); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, "Failed to fetch record")
+			return
+		}
+		if !exists {
+			RespondWithError(w, http.StatusNotFound, "Accommodation not found")
+			return
+		}
+	}
+
+	RespondWithJSON(w, http.StatusOK, response)
+}
+
 // SearchAlojamentos godoc
 // @Summary      Search accommodations with filters
 // @Description  Search accommodations with various filters and pagination
@@ -198,10 +323,11 @@ func GetAlojamentoByID(w http.ResponseWriter, r *http.Request) {
 // @Produce      json
 // @Param        page          query  int      false  "Page number (default: 1)"
 // @Param        limit         query  int      false  "Items per page (default: 20, max: 100)"
-// @Param        sort          query  string   false  "Sort field (id, nr_rnal, denominacao, concelho, distrito, created_at)"
+// @Param        sort          query  string   false  "Sort field (id, nr_rnal, denominacao, concelho, distrito, created_at, distance)"
 // @Param        order         query  string   false  "Sort order (asc, desc)"
-// @Param        concelho      query  string   false  "Filter by municipality"
-// @Param        distrito      query  string   false  "Filter by district"
+// @Param        freguesia     query  string   false  "Filter by parish, as a DICOFRE code or a name (case- and accent-insensitive)"
+// @Param        concelho      query  string   false  "Filter by municipality, as a DICOFRE code or a name (case- and accent-insensitive)"
+// @Param        distrito      query  string   false  "Filter by district, as a DICOFRE code or a name (case- and accent-insensitive)"
 // @Param        modalidade    query  string   false  "Filter by accommodation type"
 // @Param        email         query  string   false  "Filter by owner email"
 // @Param        min_capacity  query  int      false  "Minimum capacity"
@@ -210,6 +336,11 @@ func GetAlojamentoByID(w http.ResponseWriter, r *http.Request) {
 // @Param        max_lat       query  number   false  "Maximum latitude"
 // @Param        min_lng       query  number   false  "Minimum longitude"
 // @Param        max_lng       query  number   false  "Maximum longitude"
+// @Param        lat           query  number   false  "Latitude of the radius search centre (with lng and radius_m)"
+// @Param        lng           query  number   false  "Longitude of the radius search centre (with lat and radius_m)"
+// @Param        radius_m      query  number   false  "Radius search distance in metres (max: 100000); results carry distance_m"
+// @Param        include_deregistered  query  bool  false  "Include deregistered accommodations (default: false)"
+// @Param        as_of         query  string   false  "Search accommodations as they were at the end of this date (YYYY-MM-DD)"
 // @Success      200  {object}  models.PaginatedResponse[models.AlojamentoResponse]
 // @Failure      400  {object}  models.ErrorResponse
 // @Failure      500  {object}  models.ErrorResponse
@@ -229,73 +360,79 @@ func SearchAlojamentos(w http.ResponseWriter, r *http.Request) {
 		Order: "asc",
 	}
 
-	q := r.URL.Query()
-
-	if pageStr := q.Get("page"); pageStr != "" {
-		if page, err := strconv.Atoi(pageStr); err == nil {
-			params.Page = page
-		}
-	}
+	parseSearchParams(r, &params)
 
-	if limitStr := q.Get("limit"); limitStr != "" {
-		if limit, err := strconv.Atoi(limitStr); err == nil {
-			params.Limit = limit
-		}
+	// Validate params
+	if err := pkgValidator.Validate(params); err != nil {
+		details := pkgValidator.FormatValidationError(err)
+		RespondWithValidationError(w, "Invalid query parameters", details)
+		return
 	}
 
-	if sort := q.Get("sort"); sort != "" {
-		params.Sort = sort
+	// Cap limit at 100
+	if params.Limit > 100 {
+		params.Limit = 100
 	}
 
-	if order := q.Get("order"); order != "" {
-		params.Order = order
-	}
+	respondWithSearch(w, db, params, nil)
+}
 
-	params.Concelho = q.Get("concelho")
-	params.Distrito = q.Get("distrito")
-	params.Modalidade = q.Get("modalidade")
-	params.Email = q.Get("email")
+// maxSearchBodyBytes bounds POST search bodies, leaving room for a geometry of
+// geo.MaxPolygonVertices positions
+const maxSearchBodyBytes = 1 << 20
 
-	if minCapStr := q.Get("min_capacity"); minCapStr != "" {
-		if minCap, err := strconv.Atoi(minCapStr); err == nil {
-			params.MinCapacity = &minCap
-		}
+// SearchAlojamentosByShape godoc
+// @Summary      Search accommodations within a polygon
+// @Description  Search accommodations inside a GeoJSON Polygon or MultiPolygon (holes supported), combined with the filters of the GET search, which are given as body fields
+// @Tags         alojamentos
+// @Accept       json
+// @Produce      json
+// @Param        request  body  models.SearchRequest  true  "Search filters and geometry"
+// @Success      200  {object}  models.PaginatedResponse[models.AlojamentoResponse]
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.ErrorResponse
+// @Router       /alojamentos/search [post]
+func SearchAlojamentosByShape(w http.ResponseWriter, r *http.Request) {
+	db, ok := middleware.GetDB(r)
+	if !ok {
+		RespondWithError(w, http.StatusInternalServerError, "Database connection not available")
+		return
 	}
 
-	if maxCapStr := q.Get("max_capacity"); maxCapStr != "" {
-		if maxCap, err := strconv.Atoi(maxCapStr); err == nil {
-			params.MaxCapacity = &maxCap
-		}
+	var req models.SearchRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSearchBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
 	}
 
-	if minLatStr := q.Get("min_lat"); minLatStr != "" {
-		if minLat, err := strconv.ParseFloat(minLatStr, 64); err == nil {
-			params.MinLat = &minLat
-		}
+	// Fields left out of the body take the defaults of the GET search
+	params := req.SearchParams
+	if params.Page == 0 {
+		params.Page = 1
 	}
-
-	if maxLatStr := q.Get("max_lat"); maxLatStr != "" {
-		if maxLat, err := strconv.ParseFloat(maxLatStr, 64); err == nil {
-			params.MaxLat = &maxLat
-		}
+	if params.Limit == 0 {
+		params.Limit = 20
 	}
-
-	if minLngStr := q.Get("min_lng"); minLngStr != "" {
-		if minLng, err := strconv.ParseFloat(minLngStr, 64); err == nil {
-			params.MinLng = &minLng
-		}
+	if params.Sort == "" {
+		params.Sort = "id"
 	}
-
-	if maxLngStr := q.Get("max_lng"); maxLngStr != "" {
-		if maxLng, err := strconv.ParseFloat(maxLngStr, 64); err == nil {
-			params.MaxLng = &maxLng
-		}
+	if params.Order == "" {
+		params.Order = "asc"
 	}
+	req.SearchParams = params
 
-	// Validate params
-	if err := pkgValidator.Validate(params); err != nil {
+	// Validate request
+	if err := pkgValidator.Validate(req); err != nil {
 		details := pkgValidator.FormatValidationError(err)
-		RespondWithValidationError(w, "Invalid query parameters", details)
+		RespondWithValidationError(w, "Invalid request body", details)
+		return
+	}
+
+	shape, err := geo.ParseGeoJSONPolygon(req.Geometry.Type, req.Geometry.Coordinates)
+	if err != nil {
+		RespondWithValidationError(w, "Invalid request body", map[string]string{"Geometry": err.Error()})
 		return
 	}
 
@@ -304,11 +441,20 @@ func SearchAlojamentos(w http.ResponseWriter, r *http.Request) {
 		params.Limit = 100
 	}
 
+	respondWithSearch(w, db, params, shape)
+}
+
+// Helper function to run a search and write the page of results, shared by the
+// GET and POST search endpoints. shape, when set, restricts the results to a
+// polygon filter.
+func respondWithSearch(w http.ResponseWriter, db *sql.DB, params models.SearchParams, shape geo.MultiPolygon) {
 	// Build WHERE clause
-	whereClause, whereArgs := buildWhereClause(params)
+	whereClause, whereArgs := buildWhereClause(params, shape)
+
+	source := alojamentosSource(params.AsOf)
 
 	// Get total count with filters
-	countQuery := "SELECT COUNT(*) FROM alojamentos" + whereClause
+	countQuery := "SELECT COUNT(*) FROM " + source + whereClause
 	var total int
 	if err := db.QueryRow(countQuery, whereArgs...).Scan(&total); err != nil {
 		RespondWithError(w, http.StatusInternalServerError, "Failed to count records")
@@ -318,20 +464,31 @@ func SearchAlojamentos(w http.ResponseWriter, r *http.Request) {
 	// Calculate offset
 	offset := (params.Page - 1) * params.Limit
 
+	// Radius searches also select the distance to the centre, which the
+	// distance sort orders by
+	columns := alojamentoSelectColumns
+	orderBy := params.Sort + " " + params.Order
+	queryArgs := whereArgs
+	withDistance := params.Lat != nil
+	if withDistance {
+		columns += ", " + distanceExpression(len(queryArgs)+1, len(queryArgs)+2) + " AS distance_m"
+		queryArgs = append(queryArgs, *params.Lat, *params.Lng)
+		if params.Sort == "distance" {
+			orderBy = "distance_m " + params.Order + ", id"
+		}
+	}
+
 	// Build full query
 	query := fmt.Sprintf(`
-		SELECT id, object_id, nr_rnal, denominacao, data_registo, data_abertura_publico,
-		       modalidade, nr_utentes, email, endereco, codigo_postal, localidade,
-		       latitude, longitude, fiabilidade_geo, freguesia, concelho, distrito,
-		       nuts_iii, nuts_ii, ert, selo_clean_safe, created_at
-		FROM alojamentos
+		SELECT `+columns+`
+		FROM %s
 		%s
-		ORDER BY %s %s
+		ORDER BY %s
 		LIMIT $%d OFFSET $%d
-	`, whereClause, params.Sort, params.Order, len(whereArgs)+1, len(whereArgs)+2)
+	`, source, whereClause, orderBy, len(queryArgs)+1, len(queryArgs)+2)
 
 	// Append limit and offset to args
-	queryArgs := append(whereArgs, params.Limit, offset)
+	queryArgs = append(queryArgs, params.Limit, offset)
 
 	rows, err := db.Query(query, queryArgs...)
 	if err != nil {
@@ -343,6 +500,19 @@ func SearchAlojamentos(w http.ResponseWriter, r *http.Request) {
 	// Scan results
 	var alojamentos []models.AlojamentoResponse
 	for rows.Next() {
+		if withDistance {
+			var d database.AlojamentoDistance
+			if err := d.Scan(rows); err != nil {
+				RespondWithError(w, http.StatusInternalServerError, "Failed to scan record")
+				return
+			}
+			response := convertToResponse(d.Alojamento)
+			distance := math.Round(d.DistanceM*10) / 10
+			response.DistanceM = &distance
+			alojamentos = append(alojamentos, response)
+			continue
+		}
+
 		var a database.Alojamento
 		if err := a.Scan(rows); err != nil {
 			RespondWithError(w, http.StatusInternalServerError, "Failed to scan record")
@@ -377,7 +547,10 @@ func SearchAlojamentos(w http.ResponseWriter, r *http.Request) {
 // @Tags         alojamentos
 // @Accept       json
 // @Produce      json
+// @Param        include_deregistered  query  bool  false  "Include deregistered accommodations (default: false)"
+// @Param        as_of  query  string  false  "Compute statistics as of the end of this date (YYYY-MM-DD)"
 // @Success      200  {object}  models.StatsResponse
+// @Failure      400  {object}  models.ErrorResponse
 // @Failure      500  {object}  models.ErrorResponse
 // @Router       /alojamentos/stats [get]
 func GetAlojamentosStats(w http.ResponseWriter, r *http.Request) {
@@ -387,26 +560,47 @@ func GetAlojamentosStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	params := models.StatsQueryParams{
+		IncludeDeregistered: parseBoolParam(r, "include_deregistered"),
+		AsOf:                r.URL.Query().Get("as_of"),
+	}
+
+	// Validate params
+	if err := pkgValidator.Validate(params); err != nil {
+		details := pkgValidator.FormatValidationError(err)
+		RespondWithValidationError(w, "Invalid query parameters", details)
+		return
+	}
+
 	var stats models.StatsResponse
 
+	source := alojamentosSource(params.AsOf)
+
+	// Hide deregistered accommodations unless requested
+	scope := activeCondition
+	if params.IncludeDeregistered {
+		scope = "TRUE"
+	}
+
 	// Total count
-	if err := db.QueryRow("SELECT COUNT(*) FROM alojamentos").Scan(&stats.TotalAccommodations); err != nil {
+	if err := db.QueryRow("SELECT COUNT(*) FROM " + source + " WHERE " + scope).Scan(&stats.TotalAccommodations); err != nil {
 		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch total count")
 		return
 	}
 
 	// Average capacity
-	if err := db.QueryRow("SELECT COALESCE(AVG(nr_utentes), 0) FROM alojamentos WHERE nr_utentes IS NOT NULL").Scan(&stats.AverageCapacity); err != nil {
+	if err := db.QueryRow("SELECT COALESCE(AVG(nr_utentes), 0) FROM " + source + " WHERE nr_utentes IS NOT NULL AND " + scope).Scan(&stats.AverageCapacity); err != nil {
 		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch average capacity")
 		return
 	}
 
 	// By distrito (all)
 	districtRows, err := db.Query(`
-		SELECT distrito, COUNT(*) as count
-		FROM alojamentos
-		WHERE distrito != ''
-		GROUP BY distrito
+		SELECT COALESCE(d.code, ''), COALESCE(d.name, mode() WITHIN GROUP (ORDER BY alojamentos.distrito)) AS distrito, COUNT(*) as count
+		FROM ` + source + `
+		LEFT JOIN distritos d ON d.code = alojamentos.distrito_code
+		WHERE alojamentos.distrito != '' AND ` + scope + `
+		GROUP BY d.code, d.name, CASE WHEN d.code IS NULL THEN alojamentos.distrito_name_key END
 		ORDER BY count DESC
 	`)
 	if err != nil {
@@ -417,7 +611,7 @@ func GetAlojamentosStats(w http.ResponseWriter, r *http.Request) {
 
 	for districtRows.Next() {
 		var ds models.DistrictStats
-		if err := districtRows.Scan(&ds.Distrito, &ds.Count); err != nil {
+		if err := districtRows.Scan(&ds.Code, &ds.Distrito, &ds.Count); err != nil {
 			RespondWithError(w, http.StatusInternalServerError, "Failed to scan district stats")
 			return
 		}
@@ -426,10 +620,11 @@ func GetAlojamentosStats(w http.ResponseWriter, r *http.Request) {
 
 	// By concelho (all)
 	concelhoRows, err := db.Query(`
-		SELECT concelho, COUNT(*) as count
-		FROM alojamentos
-		WHERE concelho != ''
-		GROUP BY concelho
+		SELECT COALESCE(c.code, ''), COALESCE(c.name, mode() WITHIN GROUP (ORDER BY alojamentos.concelho)) AS concelho, COUNT(*) as count
+		FROM ` + source + `
+		LEFT JOIN concelhos c ON c.code = alojamentos.concelho_code
+		WHERE alojamentos.concelho != '' AND ` + scope + `
+		GROUP BY c.code, c.name, CASE WHEN c.code IS NULL THEN alojamentos.concelho_name_key END
 		ORDER BY count DESC
 	`)
 	if err != nil {
@@ -440,7 +635,7 @@ func GetAlojamentosStats(w http.ResponseWriter, r *http.Request) {
 
 	for concelhoRows.Next() {
 		var ms models.MunicipalityStats
-		if err := concelhoRows.Scan(&ms.Concelho, &ms.Count); err != nil {
+		if err := concelhoRows.Scan(&ms.Code, &ms.Concelho, &ms.Count); err != nil {
 			RespondWithError(w, http.StatusInternalServerError, "Failed to scan municipality stats")
 			return
 		}
@@ -450,8 +645,8 @@ func GetAlojamentosStats(w http.ResponseWriter, r *http.Request) {
 	// By modalidade
 	modalidadeRows, err := db.Query(`
 		SELECT modalidade, COUNT(*) as count
-		FROM alojamentos
-		WHERE modalidade != ''
+		FROM ` + source + `
+		WHERE modalidade != '' AND ` + scope + `
 		GROUP BY modalidade
 		ORDER BY count DESC
 	`)
@@ -474,21 +669,34 @@ func GetAlojamentosStats(w http.ResponseWriter, r *http.Request) {
 }
 
 // Helper function to build WHERE clause from search params
-func buildWhereClause(params models.SearchParams) (string, []interface{}) {
+func buildWhereClause(params models.SearchParams, shape geo.MultiPolygon) (string, []interface{}) {
 	var conditions []string
 	var args []interface{}
 	argIndex := 1
 
+	if !params.IncludeDeregistered {
+		conditions = append(conditions, activeCondition)
+	}
+
+	if params.Freguesia != "" {
+		condition, values := divisionCondition("freguesia", "freguesias", divisions.FreguesiaCodeLength, params.Freguesia, argIndex)
+		conditions = append(conditions, condition)
+		args = append(args, values...)
+		argIndex += len(values)
+	}
+
 	if params.Concelho != "" {
-		conditions = append(conditions, fmt.Sprintf("concelho = $%d", argIndex))
-		args = append(args, params.Concelho)
-		argIndex++
+		condition, values := divisionCondition("concelho", "concelhos", divisions.ConcelhoCodeLength, params.Concelho, argIndex)
+		conditions = append(conditions, condition)
+		args = append(args, values...)
+		argIndex += len(values)
 	}
 
 	if params.Distrito != "" {
-		conditions = append(conditions, fmt.Sprintf("distrito = $%d", argIndex))
-		args = append(args, params.Distrito)
-		argIndex++
+		condition, values := divisionCondition("distrito", "distritos", divisions.DistritoCodeLength, params.Distrito, argIndex)
+		conditions = append(conditions, condition)
+		args = append(args, values...)
+		argIndex += len(values)
 	}
 
 	if params.Modalidade != "" {
@@ -539,6 +747,24 @@ func buildWhereClause(params models.SearchParams) (string, []interface{}) {
 		argIndex++
 	}
 
+	// The bounding box of the circle lets idx_location narrow the candidates
+	// before the exact distance is computed
+	if params.Lat != nil && params.Lng != nil && params.RadiusM != nil {
+		minLat, maxLat, minLng, maxLng := geo.BoundingBox(*params.Lat, *params.Lng, *params.RadiusM)
+		conditions = append(conditions, fmt.Sprintf(
+			"latitude BETWEEN $%d AND $%d AND longitude BETWEEN $%d AND $%d AND %s <= $%d",
+			argIndex, argIndex+1, argIndex+2, argIndex+3, distanceExpression(argIndex+4, argIndex+5), argIndex+6))
+		args = append(args, minLat, maxLat, minLng, maxLng, *params.Lat, *params.Lng, *params.RadiusM)
+		argIndex += 7
+	}
+
+	if shape != nil {
+		condition, values := polygonCondition(shape, argIndex)
+		conditions = append(conditions, condition)
+		args = append(args, values...)
+		argIndex += len(values)
+	}
+
 	if len(conditions) == 0 {
 		return "", args
 	}
@@ -546,11 +772,185 @@ func buildWhereClause(params models.SearchParams) (string, []interface{}) {
 	return " WHERE " + strings.Join(conditions, " AND "), args
 }
 
+// Helper function to build the great-circle (haversine) distance in metres
+// between each record and the point given by two query arguments, computed as
+// geo.DistanceMeters does
+func distanceExpression(latArg, lngArg int) string {
+	return fmt.Sprintf(`(2 * %.1[1]f * asin(least(1, sqrt(
+		power(sin(radians(latitude - $%[2]d::float8) / 2), 2) +
+		cos(radians($%[2]d::float8)) * cos(radians(latitude)) *
+		power(sin(radians(longitude - $%[3]d::float8) / 2), 2)))))`, geo.EarthRadiusMeters, latArg, lngArg)
+}
+
+// Helper function to build the condition for a polygon filter. A record has to
+// lie in the outer ring of one of the polygons and outside that polygon's
+// holes. Each polygon's bounding box narrows the candidates on idx_location, so
+// a shape covering both Lisbon and Madeira does not scan the ocean in between.
+// Containment is tested with PostgreSQL's geometric polygon type, in
+// longitude/latitude.
+func polygonCondition(shape geo.MultiPolygon, argIndex int) (string, []interface{}) {
+	var args []interface{}
+	polygons := make([]string, len(shape))
+	for i, polygon := range shape {
+		minLat, maxLat, minLng, maxLng := polygon.Bounds()
+		parts := []string{fmt.Sprintf("latitude BETWEEN $%d AND $%d AND longitude BETWEEN $%d AND $%d",
+			argIndex, argIndex+1, argIndex+2, argIndex+3)}
+		args = append(args, minLat, maxLat, minLng, maxLng)
+		argIndex += 4
+
+		for j, ring := range polygon {
+			contains := fmt.Sprintf("$%d::polygon @> point(longitude, latitude)", argIndex)
+			if j > 0 {
+				contains = "NOT " + contains
+			}
+			parts = append(parts, contains)
+			args = append(args, ring.PostgresPolygon())
+			argIndex++
+		}
+		polygons[i] = "(" + strings.Join(parts, " AND ") + ")"
+	}
+
+	return "(" + strings.Join(polygons, " OR ") + ")", args
+}
+
+// Helper function to build the condition for a distrito, concelho or freguesia
+// filter. A value shaped like a DICOFRE code matches the code column; anything
+// else is a name, matched through the reference table on its normalized key.
+// Records whose division was not resolved are matched on the key of the name
+// as written, stored by the importer.
+func divisionCondition(column, table string, codeLength int, value string, argIndex int) (string, []interface{}) {
+	if divisions.IsCode(value, codeLength) {
+		return fmt.Sprintf("%s_code = $%d", column, argIndex), []interface{}{value}
+	}
+
+	condition := fmt.Sprintf("(%s_code IN (SELECT code FROM %s WHERE name_key = $%d) OR (%s_code IS NULL AND %s_name_key = $%d))",
+		column, table, argIndex, column, column, argIndex)
+	return condition, []interface{}{divisions.Normalize(value)}
+}
+
+// Helper function to choose the relation to query: the live table, or for an
+// as_of date the versions valid at the end of that day. The subquery keeps the
+// alojamentos name, and the name keys the filters and stats use, so the rest of
+// the query does not change.
+func alojamentosSource(asOf string) string {
+	if asOf == "" {
+		return "alojamentos"
+	}
+
+	// asOf has been validated as YYYY-MM-DD, so the literal below is safe
+	day, _ := time.Parse("2006-01-02", asOf)
+	end := day.AddDate(0, 0, 1).Format("2006-01-02")
+
+	return `(
+			SELECT ` + historySelectColumns + `,
+			       distrito_name_key, concelho_name_key, freguesia_name_key
+			FROM alojamentos_history
+			WHERE valid_from < DATE '` + end + `' AND (valid_to IS NULL OR valid_to >= DATE '` + end + `')
+		) AS alojamentos`
+}
+
+// Helper function to read the search filters, pagination and sort from the
+// query string into params. Values that do not parse are ignored, leaving the
+// defaults set by the caller.
+func parseSearchParams(r *http.Request, params *models.SearchParams) {
+	q := r.URL.Query()
+
+	if pageStr := q.Get("page"); pageStr != "" {
+		if page, err := strconv.Atoi(pageStr); err == nil {
+			params.Page = page
+		}
+	}
+
+	if limitStr := q.Get("limit"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil {
+			params.Limit = limit
+		}
+	}
+
+	if sort := q.Get("sort"); sort != "" {
+		params.Sort = sort
+	}
+
+	if order := q.Get("order"); order != "" {
+		params.Order = order
+	}
+
+	params.Freguesia = q.Get("freguesia")
+	params.Concelho = q.Get("concelho")
+	params.Distrito = q.Get("distrito")
+	params.Modalidade = q.Get("modalidade")
+	params.Email = q.Get("email")
+	params.IncludeDeregistered = parseBoolParam(r, "include_deregistered")
+	params.AsOf = q.Get("as_of")
+
+	if minCapStr := q.Get("min_capacity"); minCapStr != "" {
+		if minCap, err := strconv.Atoi(minCapStr); err == nil {
+			params.MinCapacity = &minCap
+		}
+	}
+
+	if maxCapStr := q.Get("max_capacity"); maxCapStr != "" {
+		if maxCap, err := strconv.Atoi(maxCapStr); err == nil {
+			params.MaxCapacity = &maxCap
+		}
+	}
+
+	if minLatStr := q.Get("min_lat"); minLatStr != "" {
+		if minLat, err := strconv.ParseFloat(minLatStr, 64); err == nil {
+			params.MinLat = &minLat
+		}
+	}
+
+	if maxLatStr := q.Get("max_lat"); maxLatStr != "" {
+		if maxLat, err := strconv.ParseFloat(maxLatStr, 64); err == nil {
+			params.MaxLat = &maxLat
+		}
+	}
+
+	if minLngStr := q.Get("min_lng"); minLngStr != "" {
+		if minLng, err := strconv.ParseFloat(minLngStr, 64); err == nil {
+			params.MinLng = &minLng
+		}
+	}
+
+	if maxLngStr := q.Get("max_lng"); maxLngStr != "" {
+		if maxLng, err := strconv.ParseFloat(maxLngStr, 64); err == nil {
+			params.MaxLng = &maxLng
+		}
+	}
+
+	if latStr := q.Get("lat"); latStr != "" {
+		if lat, err := strconv.ParseFloat(latStr, 64); err == nil {
+			params.Lat = &lat
+		}
+	}
+
+	if lngStr := q.Get("lng"); lngStr != "" {
+		if lng, err := strconv.ParseFloat(lngStr, 64); err == nil {
+			params.Lng = &lng
+		}
+	}
+
+	if radiusStr := q.Get("radius_m"); radiusStr != "" {
+		if radius, err := strconv.ParseFloat(radiusStr, 64); err == nil {
+			params.RadiusM = &radius
+		}
+	}
+}
+
+// Helper function to parse a boolean query parameter, defaulting to false
+func parseBoolParam(r *http.Request, name string) bool {
+	value, err := strconv.ParseBool(r.URL.Query().Get(name))
+	return err == nil && value
+}
+
 // Helper function to convert database model to API response
 func convertToResponse(a database.Alojamento) models.AlojamentoResponse {
 	response := models.AlojamentoResponse{
-		ID:        a.ID,
-		CreatedAt: a.CreatedAt,
+		ID:              a.ID,
+		CreatedAt:       a.CreatedAt,
+		LatLongMismatch: a.LatLongMismatch,
+		GeocodeSource:   a.GeocodeSource,
 	}
 
 	if a.NrRNAL.Valid {
@@ -603,6 +1003,14 @@ func convertToResponse(a database.Alojamento) models.AlojamentoResponse {
 		response.Longitude = &a.Longitude.Float64
 	}
 
+	if a.OriginalLatitude.Valid {
+		response.OriginalLatitude = &a.OriginalLatitude.Float64
+	}
+
+	if a.OriginalLongitude.Valid {
+		response.OriginalLongitude = &a.OriginalLongitude.Float64
+	}
+
 	if a.Freguesia.Valid {
 		response.Freguesia = a.Freguesia.String
 	}
@@ -615,5 +1023,29 @@ func convertToResponse(a database.Alojamento) models.AlojamentoResponse {
 		response.Distrito = a.Distrito.String
 	}
 
+	if a.FreguesiaCode.Valid {
+		response.FreguesiaCode = a.FreguesiaCode.String
+	}
+
+	if a.ConcelhoCode.Valid {
+		response.ConcelhoCode = a.ConcelhoCode.String
+	}
+
+	if a.DistritoCode.Valid {
+		response.DistritoCode = a.DistritoCode.String
+	}
+
+	if a.UpdatedAt.Valid {
+		response.UpdatedAt = &a.UpdatedAt.Time
+	}
+
+	if a.DeregisteredAt.Valid {
+		response.DeregisteredAt = &a.DeregisteredAt.Time
+	}
+
+	if a.LatLongDistanceM.Valid {
+		response.LatLongDistanceM = &a.LatLongDistanceM.Float64
+	}
+
 	return response
 }
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"localRental/middleware"
+	"localRental/models"
+	"localRental/pkg/spatial"
+	pkgValidator "localRental/pkg/validator"
+)
+
+// maxClusterCells bounds the grid cells a clusters request may span, about
+// sixteen times a 1920x1080 screen, so deep zooms over wide boxes are refused
+const maxClusterCells = 16384
+
+// GetAlojamentosClusters godoc
+// @Summary      Get map clusters
+// @Description  Group the accommodations matching the search filters into clusters on a grid of 64 pixel cells at the given zoom, with their count, centroid and count per modalidade. Cells holding a single accommodation, and every accommodation beyond zoom 16, are returned as points. Results are cached per filters and zoom until the next import.
+// @Tags         alojamentos
+// @Accept       json
+// @Produce      json
+// @Param        bbox          query  string  true   "Visible box as min_lng,min_lat,max_lng,max_lat"
+// @Param        zoom          query  int     true   "Map zoom level (0-22)"
+// @Param        freguesia     query  string  false  "Filter by parish (DICOFRE code or name)"
+// @Param        concelho      query  string  false  "Filter by municipality (DICOFRE code or name)"
+// @Param        distrito      query  string  false  "Filter by district (DICOFRE code or name)"
+// @Param        modalidade    query  string  false  "Filter by accommodation type"
+// @Param        email         query  string  false  "Filter by email"
+// @Param        min_capacity  query  int     false  "Minimum capacity"
+// @Param        max_capacity  query  int     false  "Maximum capacity"
+// @Param        lat           query  number  false  "Latitude of the radius filter centre (with lng and radius_m)"
+// @Param        lng           query  number  false  "Longitude of the radius filter centre (with lat and radius_m)"
+// @Param        radius_m      query  number  false  "Radius in metres (up to 100000)"
+// @Param        include_deregistered  query  bool    false  "Include deregistered accommodations (default: false)"
+// @Param        as_of         query  string  false  "Cluster the records as of the end of this date (YYYY-MM-DD)"
+// @Success      200  {object}  models.ClustersResponse
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.ErrorResponse
+// @Router       /alojamentos/clusters [get]
+func GetAlojamentosClusters(w http.ResponseWriter, r *http.Request) {
+	db, ok := middleware.GetDB(r)
+	if !ok {
+		RespondWithError(w, http.StatusInternalServerError, "Database connection not available")
+		return
+	}
+
+	store, ok := middleware.GetSpatialStore(r)
+	if !ok {
+		RespondWithError(w, http.StatusInternalServerError, "Spatial index not available")
+		return
+	}
+
+	// Parse and validate query parameters
+	var params models.ClusterParams
+	parseSearchParams(r, &params.SearchParams)
+
+	q := r.URL.Query()
+	params.BBox = q.Get("bbox")
+
+	if zoomStr := q.Get("zoom"); zoomStr != "" {
+		if zoom, err := strconv.Atoi(zoomStr); err == nil {
+			params.Zoom = &zoom
+		}
+	}
+
+	if err := pkgValidator.Validate(params); err != nil {
+		details := pkgValidator.FormatValidationError(err)
+		RespondWithValidationError(w, "Invalid query parameters", details)
+		return
+	}
+
+	minLng, minLat, maxLng, maxLat, err := parseBBox(params.BBox)
+	if err != nil {
+		RespondWithValidationError(w, "Invalid query parameters", map[string]string{"BBox": err.Error()})
+		return
+	}
+
+	zoom := *params.Zoom
+	west, north := spatial.GridCell(maxLat, minLng, zoom)
+	east, south := spatial.GridCell(minLat, maxLng, zoom)
+	if (east-west+1)*(south-north+1) > maxClusterCells {
+		RespondWithValidationError(w, "Invalid query parameters", map[string]string{
+			"BBox": fmt.Sprintf("box spans more than %d grid cells at zoom %d; zoom out or narrow the box", maxClusterCells, zoom),
+		})
+		return
+	}
+
+	clusters, err := loadClusters(db, store, params.SearchParams, zoom)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch records")
+		return
+	}
+
+	response := models.ClustersResponse{
+		Zoom:     zoom,
+		Clusters: []models.ClusterResponse{},
+		Points:   []models.ClusterPointResponse{},
+	}
+
+	for _, c := range clusters {
+		if c.Lat < minLat || c.Lat > maxLat || c.Lng < minLng || c.Lng > maxLng {
+			continue
+		}
+
+		response.Total += c.Count
+		if c.Point != nil {
+			response.Points = append(response.Points, models.ClusterPointResponse{
+				ID:          c.Point.ID,
+				Denominacao: c.Point.Denominacao,
+				Modalidade:  c.Point.Modalidade,
+				Latitude:    c.Point.Lat,
+				Longitude:   c.Point.Lng,
+			})
+			continue
+		}
+
+		response.Clusters = append(response.Clusters, models.ClusterResponse{
+			Count:        c.Count,
+			Latitude:     c.Lat,
+			Longitude:    c.Lng,
+			Bounds:       [4]float64{c.MinLng, c.MinLat, c.MaxLng, c.MaxLat},
+			ByModalidade: c.ByModalidade,
+		})
+	}
+
+	RespondWithJSON(w, http.StatusOK, response)
+}
+
+// loadClusters returns the clusters of the records matching the filters at
+// zoom, over the whole map. Both the matching points and their clusters are
+// cached, keyed by the filters, so panning reuses the clusters of a zoom level
+// and zooming reuses the points.
+func loadClusters(db *sql.DB, store *spatial.Store, filters models.SearchParams, zoom int) ([]spatial.Cluster, error) {
+	filterKey, err := filterCacheKey(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every zoom past MaxClusterZoom gives one cluster per point
+	zoom = min(zoom, spatial.MaxClusterZoom+1)
+
+	cache := store.Clusters()
+	index := store.Index()
+	clustersKey := fmt.Sprintf("clusters:%d:%s", zoom, filterKey)
+	if cached, ok := cache.Get(clustersKey, index); ok {
+		return cached.([]spatial.Cluster), nil
+	}
+
+	pointsKey := "points:" + filterKey
+	var points []spatial.ClusterPoint
+	if cached, ok := cache.Get(pointsKey, index); ok {
+		points = cached.([]spatial.ClusterPoint)
+	} else {
+		points, err = loadClusterPoints(db, filters)
+		if err != nil {
+			return nil, err
+		}
+		cache.Put(pointsKey, index, points, len(points))
+	}
+
+	clusters := spatial.GridClusters(points, zoom)
+	cache.Put(clustersKey, index, clusters, len(clusters))
+	return clusters, nil
+}
+
+// filterCacheKey returns a key identifying the records matched by the
+// filters. Pagination and sort do not change the matching records, so they are
+// left out.
+func filterCacheKey(filters models.SearchParams) (string, error) {
+	filters.Page, filters.Limit, filters.Sort, filters.Order = 0, 0, "", ""
+	key, err := json.Marshal(filters)
+	return string(key), err
+}
+
+// loadClusterPoints reads the positions of the records matching the filters
+func loadClusterPoints(db *sql.DB, filters models.SearchParams) ([]spatial.ClusterPoint, error) {
+	whereClause, whereArgs := buildWhereClause(filters, nil)
+	hasPosition := "latitude IS NOT NULL AND longitude IS NOT NULL"
+	if whereClause == "" {
+		whereClause = " WHERE " + hasPosition
+	} else {
+		whereClause += " AND " + hasPosition
+	}
+
+	rows, err := db.Query(`
+		SELECT id, latitude, longitude, COALESCE(modalidade, ''), COALESCE(denominacao, '')
+		FROM `+alojamentosSource(filters.AsOf)+whereClause+`
+		ORDER BY id`, whereArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []spatial.ClusterPoint
+	for rows.Next() {
+		var p spatial.ClusterPoint
+		if err := rows.Scan(&p.ID, &p.Lat, &p.Lng, &p.Modalidade, &p.Denominacao); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// Helper function to parse a min_lng,min_lat,max_lng,max_lat box
+func parseBBox(value string) (minLng, minLat, maxLng, maxLat float64, err error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return 0, 0, 0, 0, errors.New("bbox must be min_lng,min_lat,max_lng,max_lat")
+	}
+
+	var v [4]float64
+	for i, part := range parts {
+		v[i], err = strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(v[i]) {
+			return 0, 0, 0, 0, errors.New("bbox must be min_lng,min_lat,max_lng,max_lat")
+		}
+	}
+
+	minLng, minLat, maxLng, maxLat = v[0], v[1], v[2], v[3]
+	if minLng < -180 || maxLng > 180 || minLat < -90 || maxLat > 90 {
+		return 0, 0, 0, 0, errors.New("bbox must lie within longitudes -180 to 180 and latitudes -90 to 90")
+	}
+	if minLng > maxLng || minLat > maxLat {
+		return 0, 0, 0, 0, errors.New("bbox minimums must not exceed its maximums")
+	}
+	return minLng, minLat, maxLng, maxLat, nil
+}
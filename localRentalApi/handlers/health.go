@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"localRental/middleware"
+	"localRental/pkg/database"
 )
 
 // HealthResponse represents the health check response
@@ -18,6 +19,7 @@ type ReadinessResponse struct {
 	Status    string    `json:"status"`
 	Timestamp time.Time `json:"timestamp"`
 	Database  string    `json:"database"`
+	Schema    string    `json:"schema"`
 }
 
 // HealthCheck is a basic health check endpoint
@@ -37,9 +39,9 @@ func HealthCheck(w http.ResponseWriter, r *http.Request) {
 }
 
 // ReadinessCheck checks if the service is ready to serve traffic
-// Includes database connectivity check
+// Includes database connectivity and schema version checks
 // @Summary Readiness check
-// @Description Returns the readiness status of the service including database connectivity
+// @Description Returns the readiness status of the service including database connectivity and schema version
 // @Tags health
 // @Produce json
 // @Success 200 {object} ReadinessResponse
@@ -50,6 +52,7 @@ func ReadinessCheck(w http.ResponseWriter, r *http.Request) {
 		Status:    "ready",
 		Timestamp: time.Now(),
 		Database:  "connected",
+		Schema:    "up to date",
 	}
 
 	// Check database connection
@@ -57,6 +60,7 @@ func ReadinessCheck(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		response.Status = "not ready"
 		response.Database = "not configured"
+		response.Schema = "unknown"
 		RespondWithJSON(w, http.StatusServiceUnavailable, response)
 		return
 	}
@@ -65,6 +69,15 @@ func ReadinessCheck(w http.ResponseWriter, r *http.Request) {
 	if err := db.Ping(); err != nil {
 		response.Status = "not ready"
 		response.Database = "disconnected"
+		response.Schema = "unknown"
+		RespondWithJSON(w, http.StatusServiceUnavailable, response)
+		return
+	}
+
+	// Check that every migration has been applied
+	if err := database.CheckSchema(db); err != nil {
+		response.Status = "not ready"
+		response.Schema = err.Error()
 		RespondWithJSON(w, http.StatusServiceUnavailable, response)
 		return
 	}
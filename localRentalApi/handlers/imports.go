@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"localRental/middleware"
+	"localRental/models"
+	"localRental/pkg/database"
+	pkgValidator "localRental/pkg/validator"
+)
+
+// importRunSelectColumns lists the columns scanned by database.ImportRun
+const importRunSelectColumns = `id, source_file, source_sha256, mode, feature_count,
+		       imported_count, updated_count, skipped_count, failed_count, rejected_count,
+		       deregistered_count, status, error, started_at, finished_at`
+
+// GetImportRuns godoc
+// @Summary      List import runs
+// @Description  Get a paginated list of data import runs, most recent first
+// @Tags         imports
+// @Accept       json
+// @Produce      json
+// @Param        page    query  int     false  "Page number (default: 1)"
+// @Param        limit   query  int     false  "Items per page (default: 20, max: 100)"
+// @Param        status  query  string  false  "Filter by status (running, succeeded, failed)"
+// @Success      200  {object}  models.PaginatedResponse[models.ImportRunResponse]
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.ErrorResponse
+// @Router       /imports [get]
+func GetImportRuns(w http.ResponseWriter, r *http.Request) {
+	db, ok := middleware.GetDB(r)
+	if !ok {
+		RespondWithError(w, http.StatusInternalServerError, "Database connection not available")
+		return
+	}
+
+	// Parse and validate query parameters
+	params := models.ImportRunsQueryParams{
+		Page:  1,
+		Limit: 20,
+	}
+
+	q := r.URL.Query()
+
+	if pageStr := q.Get("page"); pageStr != "" {
+		if page, err := strconv.Atoi(pageStr); err == nil {
+			params.Page = page
+		}
+	}
+
+	if limitStr := q.Get("limit"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil {
+			params.Limit = limit
+		}
+	}
+
+	params.Status = q.Get("status")
+
+	// Validate params
+	if err := pkgValidator.Validate(params); err != nil {
+		details := pkgValidator.FormatValidationError(err)
+		RespondWithValidationError(w, "Invalid query parameters", details)
+		return
+	}
+
+	// Build WHERE clause
+	whereClause := ""
+	var whereArgs []interface{}
+	if params.Status != "" {
+		whereClause = " WHERE status = $1"
+		whereArgs = append(whereArgs, params.Status)
+	}
+
+	// Get total count
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM import_runs"+whereClause, whereArgs...).Scan(&total); err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to count import runs")
+		return
+	}
+
+	// Calculate offset
+	offset := (params.Page - 1) * params.Limit
+
+	query := `
+		SELECT ` + importRunSelectColumns + `
+		FROM import_runs` + whereClause + `
+		ORDER BY started_at DESC, id DESC
+		LIMIT $` + strconv.Itoa(len(whereArgs)+1) + ` OFFSET $` + strconv.Itoa(len(whereArgs)+2)
+
+	rows, err := db.Query(query, append(whereArgs, params.Limit, offset)...)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch import runs")
+		return
+	}
+	defer rows.Close()
+
+	// Scan results
+	var runs []models.ImportRunResponse
+	for rows.Next() {
+		var run database.ImportRun
+		if err := run.Scan(rows); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, "Failed to scan import run")
+			return
+		}
+		runs = append(runs, convertImportRunToResponse(run))
+	}
+
+	// Check for errors from iteration
+	if err := rows.Err(); err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Error reading import runs")
+		return
+	}
+
+	response := models.PaginatedResponse[models.ImportRunResponse]{
+		Data: runs,
+		Pagination: models.PaginationMeta{
+			Total:   total,
+			Page:    params.Page,
+			Limit:   params.Limit,
+			HasMore: offset+params.Limit < total,
+		},
+	}
+
+	RespondWithJSON(w, http.StatusOK, response)
+}
+
+// GetImportRunByID godoc
+// @Summary      Get import run by ID
+// @Description  Get a single data import run by its ID, with the counts of each input file
+// @Tags         imports
+// @Accept       json
+// @Produce      json
+// @Param        id   path  int  true  "Import run ID"
+// @Success      200  {object}  models.ImportRunResponse
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      404  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.ErrorResponse
+// @Router       /imports/{id} [get]
+func GetImportRunByID(w http.ResponseWriter, r *http.Request) {
+	db, ok := middleware.GetDB(r)
+	if !ok {
+		RespondWithError(w, http.StatusInternalServerError, "Database connection not available")
+		return
+	}
+
+	// Extract ID from path
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 2 {
+		RespondWithError(w, http.StatusBadRequest, "Missing ID parameter")
+		return
+	}
+
+	id, err := strconv.Atoi(pathParts[len(pathParts)-1])
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid ID parameter")
+		return
+	}
+
+	query := `
+		SELECT ` + importRunSelectColumns + `
+		FROM import_runs
+		WHERE id = $1
+	`
+
+	var run database.ImportRun
+	if err := run.ScanRow(db.QueryRow(query, id)); err != nil {
+		if err == sql.ErrNoRows {
+			RespondWithError(w, http.StatusNotFound, "Import run not found")
+			return
+		}
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch import run")
+		return
+	}
+
+	response := convertImportRunToResponse(run)
+
+	// Per-file breakdown
+	rows, err := db.Query(`
+		SELECT run_id, position, source_file, source_sha256, feature_count,
+		       imported_count, updated_count, skipped_count, failed_count, rejected_count
+		FROM import_run_files
+		WHERE run_id = $1
+		ORDER BY position
+	`, id)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch import run files")
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var file database.ImportRunFile
+		if err := file.Scan(rows); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, "Failed to scan import run file")
+			return
+		}
+
+		fileResponse := models.ImportRunFileResponse{
+			SourceFile:    file.SourceFile,
+			FeatureCount:  file.FeatureCount,
+			ImportedCount: file.ImportedCount,
+			UpdatedCount:  file.UpdatedCount,
+			SkippedCount:  file.SkippedCount,
+			FailedCount:   file.FailedCount,
+			RejectedCount: file.RejectedCount,
+		}
+		if file.SourceSHA256.Valid {
+			fileResponse.SourceSHA256 = file.SourceSHA256.String
+		}
+		response.Files = append(response.Files, fileResponse)
+	}
+
+	// Check for errors from iteration
+	if err := rows.Err(); err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Error reading import run files")
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, response)
+}
+
+// Helper function to convert an import run to its API response
+func convertImportRunToResponse(run database.ImportRun) models.ImportRunResponse {
+	response := models.ImportRunResponse{
+		ID:                run.ID,
+		SourceFile:        run.SourceFile,
+		Mode:              run.Mode,
+		FeatureCount:      run.FeatureCount,
+		ImportedCount:     run.ImportedCount,
+		UpdatedCount:      run.UpdatedCount,
+		SkippedCount:      run.SkippedCount,
+		FailedCount:       run.FailedCount,
+		RejectedCount:     run.RejectedCount,
+		DeregisteredCount: run.DeregisteredCount,
+		Status:            run.Status,
+		StartedAt:         run.StartedAt,
+	}
+
+	if run.SourceSHA256.Valid {
+		response.SourceSHA256 = run.SourceSHA256.String
+	}
+
+	if run.Error.Valid {
+		response.Error = run.Error.String
+	}
+
+	if run.FinishedAt.Valid {
+		response.FinishedAt = &run.FinishedAt.Time
+	}
+
+	return response
+}
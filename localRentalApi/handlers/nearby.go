@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"database/sql"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"localRental/middleware"
+	"localRental/models"
+	"localRental/pkg/database"
+	"localRental/pkg/spatial"
+	pkgValidator "localRental/pkg/validator"
+
+	"github.com/lib/pq"
+)
+
+// defaultNearbyK is the number of neighbours returned when k is not given
+const defaultNearbyK = 10
+
+// GetAlojamentoNearby godoc
+// @Summary      Get nearby accommodations
+// @Description  List the active accommodations nearest to an accommodation, nearest first, from the in-memory spatial index
+// @Tags         alojamentos
+// @Accept       json
+// @Produce      json
+// @Param        id  path   int  true   "Accommodation ID"
+// @Param        k   query  int  false  "Number of neighbours (default: 10, max: 100)"
+// @Success      200  {object}  models.NearbyResponse
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      404  {object}  models.ErrorResponse
+// @Failure      422  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.ErrorResponse
+// @Router       /alojamentos/{id}/nearby [get]
+func GetAlojamentoNearby(w http.ResponseWriter, r *http.Request) {
+	db, ok := middleware.GetDB(r)
+	if !ok {
+		RespondWithError(w, http.StatusInternalServerError, "Database connection not available")
+		return
+	}
+
+	index, ok := middleware.GetSpatialIndex(r)
+	if !ok {
+		RespondWithError(w, http.StatusInternalServerError, "Spatial index not available")
+		return
+	}
+
+	// Extract ID from path
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 3 {
+		RespondWithError(w, http.StatusBadRequest, "Missing ID parameter")
+		return
+	}
+
+	idStr := pathParts[len(pathParts)-2]
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid ID parameter")
+		return
+	}
+
+	params := models.NearbyParams{K: defaultNearbyK}
+	if kStr := r.URL.Query().Get("k"); kStr != "" {
+		if k, err := strconv.Atoi(kStr); err == nil {
+			params.K = k
+		}
+	}
+
+	if err := pkgValidator.Validate(params); err != nil {
+		details := pkgValidator.FormatValidationError(err)
+		RespondWithValidationError(w, "Invalid query parameters", details)
+		return
+	}
+
+	// Active accommodations are found in the index; deregistered ones, or
+	// ones imported since the last rebuild, are looked up so their neighbours
+	// can still be offered as alternatives
+	lat, lng, found := index.Position(id)
+	if !found {
+		var latitude, longitude sql.NullFloat64
+		err := db.QueryRow("SELECT latitude, longitude FROM alojamentos WHERE id = $1", id).Scan(&latitude, &longitude)
+		if err == sql.ErrNoRows {
+			RespondWithError(w, http.StatusNotFound, "Accommodation not found")
+			return
+		}
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, "Failed to fetch record")
+			return
+		}
+		if !latitude.Valid || !longitude.Valid {
+			RespondWithError(w, http.StatusUnprocessableEntity, "Accommodation has no coordinates")
+			return
+		}
+		lat, lng = latitude.Float64, longitude.Float64
+	}
+
+	respondWithNeighbors(w, db, index, lat, lng, index.Nearest(lat, lng, params.K, id))
+}
+
+// GetAlojamentosNearest godoc
+// @Summary      Get the accommodations nearest a point
+// @Description  List the active accommodations nearest to a point, nearest first, from the in-memory spatial index
+// @Tags         alojamentos
+// @Accept       json
+// @Produce      json
+// @Param        lat  query  number  true   "Latitude"
+// @Param        lng  query  number  true   "Longitude"
+// @Param        k    query  int     false  "Number of accommodations (default: 10, max: 100)"
+// @Success      200  {object}  models.NearbyResponse
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.ErrorResponse
+// @Router       /alojamentos/nearest [get]
+func GetAlojamentosNearest(w http.ResponseWriter, r *http.Request) {
+	db, ok := middleware.GetDB(r)
+	if !ok {
+		RespondWithError(w, http.StatusInternalServerError, "Database connection not available")
+		return
+	}
+
+	index, ok := middleware.GetSpatialIndex(r)
+	if !ok {
+		RespondWithError(w, http.StatusInternalServerError, "Spatial index not available")
+		return
+	}
+
+	params := models.NearestParams{K: defaultNearbyK}
+	q := r.URL.Query()
+
+	if latStr := q.Get("lat"); latStr != "" {
+		if lat, err := strconv.ParseFloat(latStr, 64); err == nil {
+			params.Lat = &lat
+		}
+	}
+
+	if lngStr := q.Get("lng"); lngStr != "" {
+		if lng, err := strconv.ParseFloat(lngStr, 64); err == nil {
+			params.Lng = &lng
+		}
+	}
+
+	if kStr := q.Get("k"); kStr != "" {
+		if k, err := strconv.Atoi(kStr); err == nil {
+			params.K = k
+		}
+	}
+
+	if err := pkgValidator.Validate(params); err != nil {
+		details := pkgValidator.FormatValidationError(err)
+		RespondWithValidationError(w, "Invalid query parameters", details)
+		return
+	}
+
+	lat, lng := *params.Lat, *params.Lng
+	respondWithNeighbors(w, db, index, lat, lng, index.Nearest(lat, lng, params.K, 0))
+}
+
+// respondWithNeighbors fetches the records of the neighbours found in the
+// index and writes them nearest first. Records deregistered since the index
+// was built are left out.
+func respondWithNeighbors(w http.ResponseWriter, db *sql.DB, index *spatial.Index, lat, lng float64, neighbors []spatial.Neighbor) {
+	response := models.NearbyResponse{
+		Latitude:  lat,
+		Longitude: lng,
+		Data:      []models.AlojamentoResponse{},
+		IndexedAt: index.BuiltAt(),
+	}
+
+	if len(neighbors) == 0 {
+		RespondWithJSON(w, http.StatusOK, response)
+		return
+	}
+
+	ids := make([]int64, len(neighbors))
+	for i, n := range neighbors {
+		ids[i] = int64(n.ID)
+	}
+
+	rows, err := db.Query(`
+		SELECT `+alojamentoSelectColumns+`
+		FROM alojamentos
+		WHERE id = ANY($1) AND `+activeCondition, pq.Array(ids))
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch records")
+		return
+	}
+	defer rows.Close()
+
+	records := make(map[int]database.Alojamento, len(neighbors))
+	for rows.Next() {
+		var a database.Alojamento
+		if err := a.Scan(rows); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, "Failed to scan record")
+			return
+		}
+		records[a.ID] = a
+	}
+
+	if err := rows.Err(); err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Error reading records")
+		return
+	}
+
+	for _, n := range neighbors {
+		a, ok := records[n.ID]
+		if !ok {
+			continue
+		}
+		item := convertToResponse(a)
+		distance := math.Round(n.DistanceM*10) / 10
+		item.DistanceM = &distance
+		response.Data = append(response.Data, item)
+	}
+
+	RespondWithJSON(w, http.StatusOK, response)
+}
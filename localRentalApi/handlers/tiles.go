@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"localRental/middleware"
+	"localRental/models"
+	"localRental/pkg/mvt"
+	pkgValidator "localRental/pkg/validator"
+)
+
+// tileLayerName is the name of the vector tile layer holding accommodations
+const tileLayerName = "alojamentos"
+
+// tileBuffer is the margin, in tile coordinate units, around each tile whose
+// points are also included, so symbols on tile edges are not cut off
+const tileBuffer = 64
+
+// mvtContentType is the media type of Mapbox Vector Tiles
+const mvtContentType = "application/vnd.mapbox-vector-tile"
+
+// tileThinMaxZoom is the deepest zoom whose tiles keep one point per pixel of
+// a 256 pixel tile. Below it, points sharing a pixel would be drawn on top of
+// each other, and the whole country fits in a handful of tiles.
+const tileThinMaxZoom = 12
+
+// tilePixel is the size of such a pixel in tile coordinate units
+const tilePixel = mvt.DefaultExtent / 256
+
+// maxTileFeatures bounds the points encoded in a tile at any zoom
+const maxTileFeatures = 50000
+
+// GetTile godoc
+// @Summary      Get a vector tile of accommodations
+// @Description  Mapbox Vector Tile of the accommodations matching the search filters, as points in an "alojamentos" layer with id, modalidade, nr_utentes and concelho properties. Up to zoom 12 a tile keeps one point, the lowest id, per pixel of a 256 pixel tile, and no tile holds more than 50000 points. Tiles are cached until the next import.
+// @Tags         tiles
+// @Produce      application/vnd.mapbox-vector-tile
+// @Param        z             path   int     true   "Zoom level (0-22)"
+// @Param        x             path   int     true   "Tile column"
+// @Param        y             path   string  true   "Tile row, followed by .mvt"
+// @Param        freguesia     query  string  false  "Filter by parish (DICOFRE code or name)"
+// @Param        concelho      query  string  false  "Filter by municipality (DICOFRE code or name)"
+// @Param        distrito      query  string  false  "Filter by district (DICOFRE code or name)"
+// @Param        modalidade    query  string  false  "Filter by accommodation type"
+// @Param        email         query  string  false  "Filter by email"
+// @Param        min_capacity  query  int     false  "Minimum capacity"
+// @Param        max_capacity  query  int     false  "Maximum capacity"
+// @Param        lat           query  number  false  "Latitude of the radius filter centre (with lng and radius_m)"
+// @Param        lng           query  number  false  "Longitude of the radius filter centre (with lat and radius_m)"
+// @Param        radius_m      query  number  false  "Radius in metres (up to 100000)"
+// @Param        include_deregistered  query  bool    false  "Include deregistered accommodations (default: false)"
+// @Param        as_of         query  string  false  "Show the records as of the end of this date (YYYY-MM-DD)"
+// @Success      200  {file}    binary
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      404  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.ErrorResponse
+// @Router       /tiles/{z}/{x}/{y}.mvt [get]
+func GetTile(w http.ResponseWriter, r *http.Request) {
+	db, ok := middleware.GetDB(r)
+	if !ok {
+		RespondWithError(w, http.StatusInternalServerError, "Database connection not available")
+		return
+	}
+
+	store, ok := middleware.GetSpatialStore(r)
+	if !ok {
+		RespondWithError(w, http.StatusInternalServerError, "Spatial index not available")
+		return
+	}
+
+	// Extract z/x/y from path
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 4 || !strings.HasSuffix(pathParts[len(pathParts)-1], ".mvt") {
+		RespondWithError(w, http.StatusNotFound, "Tile not found")
+		return
+	}
+
+	z, errZ := strconv.Atoi(pathParts[len(pathParts)-3])
+	x, errX := strconv.Atoi(pathParts[len(pathParts)-2])
+	y, errY := strconv.Atoi(strings.TrimSuffix(pathParts[len(pathParts)-1], ".mvt"))
+	if errZ != nil || errX != nil || errY != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid tile coordinates")
+		return
+	}
+	if !mvt.ValidTile(z, x, y) {
+		RespondWithError(w, http.StatusNotFound, "Tile not found")
+		return
+	}
+
+	// Parse and validate the search filters
+	var params models.SearchParams
+	parseSearchParams(r, &params)
+
+	if err := pkgValidator.Validate(params); err != nil {
+		details := pkgValidator.FormatValidationError(err)
+		RespondWithValidationError(w, "Invalid query parameters", details)
+		return
+	}
+
+	filterKey, err := filterCacheKey(params)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to encode tile")
+		return
+	}
+
+	cache := store.Tiles()
+	index := store.Index()
+	key := fmt.Sprintf("%d/%d/%d:%s", z, x, y, filterKey)
+
+	tile, ok := cache.Get(key, index)
+	if !ok {
+		encoded, err := encodeTile(db, params, z, x, y)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, "Failed to encode tile")
+			return
+		}
+		cache.Put(key, index, encoded, len(encoded))
+		tile = encoded
+	}
+
+	w.Header().Set("Content-Type", mvtContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(tile.([]byte))
+}
+
+// encodeTile builds tile z/x/y of the records matching the filters, thinned to
+// one point per pixel up to tileThinMaxZoom and to maxTileFeatures points
+func encodeTile(db *sql.DB, filters models.SearchParams, z, x, y int) ([]byte, error) {
+	// The tile box, with its buffer, narrows the records on idx_location
+	minLat, maxLat, minLng, maxLng := mvt.TileBounds(z, x, y, mvt.DefaultExtent, tileBuffer)
+	whereClause, whereArgs := buildWhereClause(filters, nil)
+	argIndex := len(whereArgs) + 1
+	inTile := fmt.Sprintf("latitude BETWEEN $%d AND $%d AND longitude BETWEEN $%d AND $%d",
+		argIndex, argIndex+1, argIndex+2, argIndex+3)
+	whereArgs = append(whereArgs, minLat, maxLat, minLng, maxLng)
+	if whereClause == "" {
+		whereClause = " WHERE " + inTile
+	} else {
+		whereClause += " AND " + inTile
+	}
+
+	rows, err := db.Query(`
+		SELECT id, latitude, longitude, modalidade, nr_utentes, concelho
+		FROM `+alojamentosSource(filters.AsOf)+whereClause+`
+		ORDER BY id`, whereArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	layer := mvt.NewLayer(tileLayerName, mvt.DefaultExtent)
+	thin := z <= tileThinMaxZoom
+	drawn := make(map[[2]int64]bool)
+	for rows.Next() && layer.Len() < maxTileFeatures {
+		var (
+			id                   int
+			lat, lng             float64
+			modalidade, concelho sql.NullString
+			nrUtentes            sql.NullInt64
+		)
+		if err := rows.Scan(&id, &lat, &lng, &modalidade, &nrUtentes, &concelho); err != nil {
+			return nil, err
+		}
+
+		// Rows come in id order, so the lowest id of a pixel is kept
+		px, py := mvt.TileCoordinates(lat, lng, z, x, y, mvt.DefaultExtent)
+		if thin {
+			pixel := [2]int64{(px + tileBuffer) / tilePixel, (py + tileBuffer) / tilePixel}
+			if drawn[pixel] {
+				continue
+			}
+			drawn[pixel] = true
+		}
+
+		// Missing values are left out, as vector tiles have no null
+		properties := []mvt.Property{{Key: "id", Value: id}}
+		if modalidade.Valid {
+			properties = append(properties, mvt.Property{Key: "modalidade", Value: modalidade.String})
+		}
+		if nrUtentes.Valid {
+			properties = append(properties, mvt.Property{Key: "nr_utentes", Value: nrUtentes.Int64})
+		}
+		if concelho.Valid {
+			properties = append(properties, mvt.Property{Key: "concelho", Value: concelho.String})
+		}
+
+		if err := layer.AddPoint(uint64(id), px, py, properties); err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return mvt.Encode(layer), nil
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"localRental/pkg/spatial"
+)
+
+// spatialIndexContextKey is the key for storing the spatial index store in request context
+const spatialIndexContextKey contextKey = "spatialIndex"
+
+// SpatialIndexMiddleware adds the spatial index store to request context
+func SpatialIndexMiddleware(store *spatial.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), spatialIndexContextKey, store)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetSpatialStore retrieves the spatial index store from request context
+func GetSpatialStore(r *http.Request) (*spatial.Store, bool) {
+	store, ok := r.Context().Value(spatialIndexContextKey).(*spatial.Store)
+	return store, ok
+}
+
+// GetSpatialIndex retrieves the current spatial index from request context
+func GetSpatialIndex(r *http.Request) (*spatial.Index, bool) {
+	store, ok := GetSpatialStore(r)
+	if !ok {
+		return nil, false
+	}
+	return store.Index(), true
+}
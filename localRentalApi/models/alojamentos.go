@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -20,28 +21,78 @@ type PaginatedResponse[T any] struct {
 
 // AlojamentosQueryParams represents query parameters for listing accommodations
 type AlojamentosQueryParams struct {
-	Page  int    `json:"page" validate:"omitempty,gte=1"`
-	Limit int    `json:"limit" validate:"omitempty,gte=1,lte=100"`
-	Sort  string `json:"sort" validate:"omitempty,oneof=id nr_rnal denominacao concelho distrito created_at"`
-	Order string `json:"order" validate:"omitempty,oneof=asc desc"`
+	Page                int    `json:"page" validate:"omitempty,gte=1"`
+	Limit               int    `json:"limit" validate:"omitempty,gte=1,lte=100"`
+	Sort                string `json:"sort" validate:"omitempty,oneof=id nr_rnal denominacao concelho distrito created_at"`
+	Order               string `json:"order" validate:"omitempty,oneof=asc desc"`
+	IncludeDeregistered bool   `json:"include_deregistered"`
+	AsOf                string `json:"as_of" validate:"omitempty,datetime=2006-01-02"`
 }
 
-// SearchParams represents search filter parameters
+// SearchParams represents search filter parameters. Lat, Lng and RadiusM
+// select the records within RadiusM metres of a point; they are required
+// together, and by the distance sort.
 type SearchParams struct {
-	Page           int      `json:"page" validate:"omitempty,gte=1"`
-	Limit          int      `json:"limit" validate:"omitempty,gte=1,lte=100"`
-	Sort           string   `json:"sort" validate:"omitempty,oneof=id nr_rnal denominacao concelho distrito created_at"`
-	Order          string   `json:"order" validate:"omitempty,oneof=asc desc"`
-	Concelho       string   `json:"concelho" validate:"omitempty"`
-	Distrito       string   `json:"distrito" validate:"omitempty"`
-	Modalidade     string   `json:"modalidade" validate:"omitempty"`
-	Email          string   `json:"email" validate:"omitempty"`
-	MinCapacity    *int     `json:"min_capacity" validate:"omitempty,gte=0"`
-	MaxCapacity    *int     `json:"max_capacity" validate:"omitempty,gte=0"`
-	MinLat         *float64 `json:"min_lat" validate:"omitempty,latitude"`
-	MaxLat         *float64 `json:"max_lat" validate:"omitempty,latitude"`
-	MinLng         *float64 `json:"min_lng" validate:"omitempty,longitude"`
-	MaxLng         *float64 `json:"max_lng" validate:"omitempty,longitude"`
+	Page                int      `json:"page" validate:"omitempty,gte=1"`
+	Limit               int      `json:"limit" validate:"omitempty,gte=1,lte=100"`
+	Sort                string   `json:"sort" validate:"omitempty,oneof=id nr_rnal denominacao concelho distrito created_at distance"`
+	Order               string   `json:"order" validate:"omitempty,oneof=asc desc"`
+	Freguesia           string   `json:"freguesia" validate:"omitempty"`
+	Concelho            string   `json:"concelho" validate:"omitempty"`
+	Distrito            string   `json:"distrito" validate:"omitempty"`
+	Modalidade          string   `json:"modalidade" validate:"omitempty"`
+	Email               string   `json:"email" validate:"omitempty"`
+	MinCapacity         *int     `json:"min_capacity" validate:"omitempty,gte=0"`
+	MaxCapacity         *int     `json:"max_capacity" validate:"omitempty,gte=0"`
+	MinLat              *float64 `json:"min_lat" validate:"omitempty,latitude"`
+	MaxLat              *float64 `json:"max_lat" validate:"omitempty,latitude"`
+	MinLng              *float64 `json:"min_lng" validate:"omitempty,longitude"`
+	MaxLng              *float64 `json:"max_lng" validate:"omitempty,longitude"`
+	Lat                 *float64 `json:"lat" validate:"required_if=Sort distance,required_with=Lng RadiusM,omitempty,latitude"`
+	Lng                 *float64 `json:"lng" validate:"required_if=Sort distance,required_with=Lat RadiusM,omitempty,longitude"`
+	RadiusM             *float64 `json:"radius_m" validate:"required_if=Sort distance,required_with=Lat Lng,omitempty,gt=0,lte=100000"`
+	IncludeDeregistered bool     `json:"include_deregistered"`
+	AsOf                string   `json:"as_of" validate:"omitempty,datetime=2006-01-02"`
+}
+
+// SearchRequest is the body of a POST search: the search filters plus a GeoJSON
+// Polygon or MultiPolygon geometry the results must lie in
+type SearchRequest struct {
+	SearchParams
+	Geometry *GeoJSONGeometry `json:"geometry" validate:"required"`
+}
+
+// GeoJSONGeometry is a GeoJSON geometry object, its coordinates parsed
+// according to its type
+type GeoJSONGeometry struct {
+	Type        string          `json:"type" validate:"required"`
+	Coordinates json.RawMessage `json:"coordinates" swaggertype:"array,number" validate:"required"`
+}
+
+// NearbyParams represents query parameters for the neighbours of an accommodation
+type NearbyParams struct {
+	K int `json:"k" validate:"omitempty,gte=1,lte=100"`
+}
+
+// NearestParams represents query parameters for the accommodations nearest a point
+type NearestParams struct {
+	Lat *float64 `json:"lat" validate:"required,latitude"`
+	Lng *float64 `json:"lng" validate:"required,longitude"`
+	K   int      `json:"k" validate:"omitempty,gte=1,lte=100"`
+}
+
+// ClusterParams represents query parameters for map clusters: the search
+// filters, the visible box as min_lng,min_lat,max_lng,max_lat and the map zoom
+type ClusterParams struct {
+	SearchParams
+	BBox string `json:"bbox" validate:"required"`
+	Zoom *int   `json:"zoom" validate:"required,gte=0,lte=22"`
+}
+
+// StatsQueryParams represents query parameters for accommodation statistics
+type StatsQueryParams struct {
+	IncludeDeregistered bool   `json:"include_deregistered"`
+	AsOf                string `json:"as_of" validate:"omitempty,datetime=2006-01-02"`
 }
 
 // AlojamentoResponse represents an accommodation in API responses
@@ -62,26 +113,97 @@ type AlojamentoResponse struct {
 	Freguesia           string     `json:"freguesia,omitempty"`
 	Concelho            string     `json:"concelho,omitempty"`
 	Distrito            string     `json:"distrito,omitempty"`
+	FreguesiaCode       string     `json:"freguesia_code,omitempty"`
+	ConcelhoCode        string     `json:"concelho_code,omitempty"`
+	DistritoCode        string     `json:"distrito_code,omitempty"`
 	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           *time.Time `json:"updated_at,omitempty"`
+	DeregisteredAt      *time.Time `json:"deregistered_at,omitempty"`
+	LatLongMismatch     bool       `json:"latlong_mismatch"`
+	LatLongDistanceM    *float64   `json:"latlong_distance_m,omitempty"`
+	// GeocodeSource is "original" when the coordinates come from the source
+	// data, or "postal_centroid" when they were replaced by the centroid of the
+	// postal code, in which case OriginalLatitude and OriginalLongitude hold
+	// the source coordinates, if there were any
+	GeocodeSource     string   `json:"geocode_source"`
+	OriginalLatitude  *float64 `json:"original_latitude,omitempty"`
+	OriginalLongitude *float64 `json:"original_longitude,omitempty"`
+	// DistanceM is the distance from the search point, in radius searches and
+	// nearest neighbour queries
+	DistanceM *float64 `json:"distance_m,omitempty"`
+}
+
+// AlojamentoVersionResponse represents one historical version of an accommodation
+type AlojamentoVersionResponse struct {
+	AlojamentoResponse
+	ValidFrom time.Time  `json:"valid_from"`
+	ValidTo   *time.Time `json:"valid_to,omitempty"`
+}
+
+// AlojamentoHistoryResponse lists the versions of an accommodation, oldest first
+type AlojamentoHistoryResponse struct {
+	ID       int                         `json:"id"`
+	Versions []AlojamentoVersionResponse `json:"versions"`
+}
+
+// NearbyResponse lists the accommodations nearest a point, nearest first, each
+// with its distance_m. IndexedAt is when the spatial index answering the
+// query was built.
+type NearbyResponse struct {
+	Latitude  float64              `json:"latitude"`
+	Longitude float64              `json:"longitude"`
+	Data      []AlojamentoResponse `json:"data"`
+	IndexedAt time.Time            `json:"indexed_at"`
+}
+
+// ClustersResponse holds the clusters and single points of a map view. Total
+// counts the accommodations they hold together.
+type ClustersResponse struct {
+	Zoom     int                    `json:"zoom"`
+	Total    int                    `json:"total"`
+	Clusters []ClusterResponse      `json:"clusters"`
+	Points   []ClusterPointResponse `json:"points"`
+}
+
+// ClusterResponse is a group of nearby accommodations, placed at their
+// centroid. Bounds is the box enclosing them, as min_lng, min_lat, max_lng,
+// max_lat, for zooming in on the cluster.
+type ClusterResponse struct {
+	Count        int            `json:"count"`
+	Latitude     float64        `json:"latitude"`
+	Longitude    float64        `json:"longitude"`
+	Bounds       [4]float64     `json:"bounds"`
+	ByModalidade map[string]int `json:"by_modalidade"`
+}
+
+// ClusterPointResponse is an accommodation shown on its own on the map
+type ClusterPointResponse struct {
+	ID          int     `json:"id"`
+	Denominacao string  `json:"denominacao,omitempty"`
+	Modalidade  string  `json:"modalidade,omitempty"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
 }
 
 // StatsResponse represents aggregated statistics
 type StatsResponse struct {
-	TotalAccommodations int                   `json:"total_accommodations"`
-	AverageCapacity     float64               `json:"average_capacity"`
-	ByDistrito          []DistrictStats       `json:"by_distrito"`
-	ByConcelho          []MunicipalityStats   `json:"by_concelho"`
-	ByModalidade        []TypeStats           `json:"by_modalidade"`
+	TotalAccommodations int                 `json:"total_accommodations"`
+	AverageCapacity     float64             `json:"average_capacity"`
+	ByDistrito          []DistrictStats     `json:"by_distrito"`
+	ByConcelho          []MunicipalityStats `json:"by_concelho"`
+	ByModalidade        []TypeStats         `json:"by_modalidade"`
 }
 
 // DistrictStats represents statistics by district
 type DistrictStats struct {
+	Code     string `json:"code,omitempty"`
 	Distrito string `json:"distrito"`
 	Count    int    `json:"count"`
 }
 
 // MunicipalityStats represents statistics by municipality
 type MunicipalityStats struct {
+	Code     string `json:"code,omitempty"`
 	Concelho string `json:"concelho"`
 	Count    int    `json:"count"`
 }
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+)
+
+// ImportRunsQueryParams represents query parameters for listing import runs
+type ImportRunsQueryParams struct {
+	Page   int    `json:"page" validate:"omitempty,gte=1"`
+	Limit  int    `json:"limit" validate:"omitempty,gte=1,lte=100"`
+	Status string `json:"status" validate:"omitempty,oneof=running succeeded failed"`
+}
+
+// ImportRunResponse represents a run of the data importer in API responses
+type ImportRunResponse struct {
+	ID                int        `json:"id"`
+	SourceFile        string     `json:"source_file"`
+	SourceSHA256      string     `json:"source_sha256,omitempty"`
+	Mode              string     `json:"mode"`
+	FeatureCount      int        `json:"feature_count"`
+	ImportedCount     int        `json:"imported_count"`
+	UpdatedCount      int        `json:"updated_count"`
+	SkippedCount      int        `json:"skipped_count"`
+	FailedCount       int        `json:"failed_count"`
+	RejectedCount     int        `json:"rejected_count"`
+	DeregisteredCount int        `json:"deregistered_count"`
+	Status            string     `json:"status"`
+	Error             string     `json:"error,omitempty"`
+	StartedAt         time.Time  `json:"started_at"`
+	FinishedAt        *time.Time `json:"finished_at,omitempty"`
+	// Files is only included when fetching a single run
+	Files []ImportRunFileResponse `json:"files,omitempty"`
+}
+
+// ImportRunFileResponse represents one input file of an import run
+type ImportRunFileResponse struct {
+	SourceFile    string `json:"source_file"`
+	SourceSHA256  string `json:"source_sha256,omitempty"`
+	FeatureCount  int    `json:"feature_count"`
+	ImportedCount int    `json:"imported_count"`
+	UpdatedCount  int    `json:"updated_count"`
+	SkippedCount  int    `json:"skipped_count"`
+	FailedCount   int    `json:"failed_count"`
+	RejectedCount int    `json:"rejected_count"`
+}
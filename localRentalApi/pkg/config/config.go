@@ -15,6 +15,7 @@ type Config struct {
 	Database DatabaseConfig
 	Auth     AuthConfig
 	RateLimit RateLimitConfig
+	SpatialIndex SpatialIndexConfig
 	Env      string
 }
 
@@ -44,6 +45,11 @@ type RateLimitConfig struct {
 	Burst             int
 }
 
+// SpatialIndexConfig holds the in-memory spatial index configuration
+type SpatialIndexConfig struct {
+	RefreshInterval time.Duration
+}
+
 // Load loads configuration from environment variables
 // It will attempt to load .env file if it exists (useful for local development)
 func Load() (*Config, error) {
@@ -69,6 +75,9 @@ func Load() (*Config, error) {
 			RequestsPerSecond: getEnvAsFloat("RATE_LIMIT_REQUESTS_PER_SECOND", 10.0),
 			Burst:             getEnvAsInt("RATE_LIMIT_BURST", 20),
 		},
+		SpatialIndex: SpatialIndexConfig{
+			RefreshInterval: getEnvAsDuration("SPATIAL_INDEX_REFRESH_INTERVAL", time.Minute),
+		},
 		Env: getEnv("ENV", "development"),
 	}
 
@@ -79,6 +88,9 @@ func Load() (*Config, error) {
 	if config.Auth.Username == "" || config.Auth.Password == "" {
 		return nil, fmt.Errorf("AUTH_USERNAME and AUTH_PASSWORD must be set")
 	}
+	if config.SpatialIndex.RefreshInterval <= 0 {
+		return nil, fmt.Errorf("SPATIAL_INDEX_REFRESH_INTERVAL must be positive")
+	}
 
 	return config, nil
 }
@@ -0,0 +1,309 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles holds the numbered schema migrations, named
+// NNNN_description.up.sql and NNNN_description.down.sql
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the advisory lock key that serialises concurrent migrators
+const migrationLockID = 7342619
+
+// ErrSchemaBehind is returned by CheckSchema when migrations are pending
+var ErrSchemaBehind = errors.New("database schema is behind")
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Migrations returns the embedded migrations ordered by version
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migration %s: expected .up.sql or .down.sql suffix", name)
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		prefix, description, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: expected NNNN_description prefix", name)
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version %q", name, prefix)
+		}
+
+		body, err := migrationFiles.ReadFile(path.Join("migrations", name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: description}
+			byVersion[version] = m
+		} else if m.Name != description {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, description)
+		}
+
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s must have both up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// LatestVersion returns the highest embedded migration version
+func LatestVersion() (int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// ensureMigrationsTable creates the schema_migrations bookkeeping table
+func ensureMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// appliedMigrations returns the applied versions and when they were applied
+func appliedMigrations(db *sql.DB) (map[int]time.Time, error) {
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// SchemaVersion returns the highest applied migration version, or 0 when none
+// have been applied
+func SchemaVersion(db *sql.DB) (int, error) {
+	var exists bool
+	if err := db.QueryRow("SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists); err != nil {
+		return 0, fmt.Errorf("failed to check schema_migrations: %w", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	var version int
+	if err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// CheckSchema returns an error wrapping ErrSchemaBehind when the database has
+// not had every embedded migration applied
+func CheckSchema(db *sql.DB) error {
+	latest, err := LatestVersion()
+	if err != nil {
+		return err
+	}
+
+	current, err := SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	if current < latest {
+		return fmt.Errorf("%w: at version %d, expected %d (run: migrate up)", ErrSchemaBehind, current, latest)
+	}
+	return nil
+}
+
+// MigrateUp applies every pending migration in order, each in its own
+// transaction, and returns the migrations it applied
+func MigrateUp(db *sql.DB) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureMigrationsTable(db); err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, m := range migrations {
+		done, err := runMigration(db, m, true)
+		if err != nil {
+			return applied, err
+		}
+		if done {
+			log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+			applied = append(applied, m)
+		}
+	}
+
+	return applied, nil
+}
+
+// MigrateDown reverts the most recently applied migrations, at most steps of
+// them, and returns the migrations it reverted
+func MigrateDown(db *sql.DB, steps int) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureMigrationsTable(db); err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+		done, err := runMigration(db, migrations[i], false)
+		if err != nil {
+			return reverted, err
+		}
+		if done {
+			log.Printf("Reverted migration %04d_%s", migrations[i].Version, migrations[i].Name)
+			reverted = append(reverted, migrations[i])
+		}
+	}
+
+	return reverted, nil
+}
+
+// runMigration applies (up) or reverts (down) a single migration under an
+// advisory lock. It reports false when there was nothing to do, which lets
+// concurrent migrators skip work another process already finished.
+func runMigration(db *sql.DB, m Migration, up bool) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", migrationLockID); err != nil {
+		return false, fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+
+	var applied bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", m.Version).Scan(&applied); err != nil {
+		return false, fmt.Errorf("failed to check migration %d: %w", m.Version, err)
+	}
+	if applied == up {
+		return false, nil
+	}
+
+	body, record, args := m.Down, "DELETE FROM schema_migrations WHERE version = $1", []any{m.Version}
+	if up {
+		body, record, args = m.Up, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", []any{m.Version, m.Name}
+	}
+
+	if _, err := tx.Exec(body); err != nil {
+		return false, fmt.Errorf("migration %04d_%s failed: %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.Exec(record, args...); err != nil {
+		return false, fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit migration %d: %w", m.Version, err)
+	}
+	return true, nil
+}
+
+// MigrationStatuses lists every embedded migration with its applied time, if any
+func MigrationStatuses(db *sql.DB) ([]MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureMigrationsTable(db); err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if at, ok := applied[m.Version]; ok {
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS alojamentos (
+    id SERIAL PRIMARY KEY,
+    object_id INTEGER,
+    nr_rnal INTEGER UNIQUE,
+    denominacao TEXT,
+    data_registo TIMESTAMP,
+    data_abertura_publico TIMESTAMP,
+    modalidade TEXT,
+    nr_utentes INTEGER,
+    email TEXT,
+    endereco TEXT,
+    codigo_postal TEXT,
+    localidade TEXT,
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    fiabilidade_geo TEXT,
+    freguesia TEXT,
+    concelho TEXT,
+    distrito TEXT,
+    nuts_iii TEXT,
+    nuts_ii TEXT,
+    ert TEXT,
+    selo_clean_safe TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_nr_rnal ON alojamentos(nr_rnal);
+CREATE INDEX IF NOT EXISTS idx_concelho ON alojamentos(concelho);
+CREATE INDEX IF NOT EXISTS idx_distrito ON alojamentos(distrito);
+CREATE INDEX IF NOT EXISTS idx_modalidade ON alojamentos(modalidade);
+CREATE INDEX IF NOT EXISTS idx_location ON alojamentos(latitude, longitude);
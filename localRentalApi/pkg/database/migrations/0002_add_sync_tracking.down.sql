@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_deregistered_at;
+
+ALTER TABLE alojamentos DROP COLUMN IF EXISTS deregistered_at;
+ALTER TABLE alojamentos DROP COLUMN IF EXISTS last_seen_at;
+ALTER TABLE alojamentos DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,5 @@
+ALTER TABLE alojamentos ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+ALTER TABLE alojamentos ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP;
+ALTER TABLE alojamentos ADD COLUMN IF NOT EXISTS deregistered_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_deregistered_at ON alojamentos(deregistered_at);
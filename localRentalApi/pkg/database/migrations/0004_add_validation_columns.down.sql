@@ -0,0 +1,4 @@
+ALTER TABLE alojamentos DROP COLUMN IF EXISTS latlong_distance_m;
+ALTER TABLE alojamentos DROP COLUMN IF EXISTS latlong_mismatch;
+
+ALTER TABLE import_runs DROP COLUMN IF EXISTS rejected_count;
@@ -0,0 +1,4 @@
+ALTER TABLE import_runs ADD COLUMN IF NOT EXISTS rejected_count INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE alojamentos ADD COLUMN IF NOT EXISTS latlong_mismatch BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE alojamentos ADD COLUMN IF NOT EXISTS latlong_distance_m DOUBLE PRECISION;
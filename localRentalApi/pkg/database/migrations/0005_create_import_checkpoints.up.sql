@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS import_checkpoints (
+    source_sha256 TEXT PRIMARY KEY,
+    source_file TEXT NOT NULL,
+    mode TEXT NOT NULL,
+    last_index INTEGER NOT NULL,
+    sync_time TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,54 @@
+-- One row per version of a registration (slowly changing dimension, type 2).
+-- A version is valid from valid_from until valid_to; the current version of an
+-- active registration has valid_to NULL. Deregistered registrations have no
+-- open version.
+CREATE TABLE IF NOT EXISTS alojamentos_history (
+    history_id BIGSERIAL PRIMARY KEY,
+    alojamento_id INTEGER NOT NULL,
+    object_id INTEGER,
+    nr_rnal INTEGER,
+    denominacao TEXT,
+    data_registo TIMESTAMP,
+    data_abertura_publico TIMESTAMP,
+    modalidade TEXT,
+    nr_utentes INTEGER,
+    email TEXT,
+    endereco TEXT,
+    codigo_postal TEXT,
+    localidade TEXT,
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    fiabilidade_geo TEXT,
+    freguesia TEXT,
+    concelho TEXT,
+    distrito TEXT,
+    nuts_iii TEXT,
+    nuts_ii TEXT,
+    ert TEXT,
+    selo_clean_safe TEXT,
+    latlong_mismatch BOOLEAN NOT NULL DEFAULT FALSE,
+    latlong_distance_m DOUBLE PRECISION,
+    created_at TIMESTAMP,
+    valid_from TIMESTAMP NOT NULL,
+    valid_to TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_history_alojamento ON alojamentos_history(alojamento_id, valid_from);
+CREATE INDEX IF NOT EXISTS idx_history_validity ON alojamentos_history(valid_from, valid_to);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_history_open ON alojamentos_history(alojamento_id) WHERE valid_to IS NULL;
+
+-- Seed the current version of every active registration
+INSERT INTO alojamentos_history (
+    alojamento_id, object_id, nr_rnal, denominacao, data_registo, data_abertura_publico,
+    modalidade, nr_utentes, email, endereco, codigo_postal, localidade, latitude, longitude,
+    fiabilidade_geo, freguesia, concelho, distrito, nuts_iii, nuts_ii, ert, selo_clean_safe,
+    latlong_mismatch, latlong_distance_m, created_at, valid_from
+)
+SELECT id, object_id, nr_rnal, denominacao, data_registo, data_abertura_publico,
+       modalidade, nr_utentes, email, endereco, codigo_postal, localidade, latitude, longitude,
+       fiabilidade_geo, freguesia, concelho, distrito, nuts_iii, nuts_ii, ert, selo_clean_safe,
+       latlong_mismatch, latlong_distance_m, created_at,
+       COALESCE(updated_at, created_at, CURRENT_TIMESTAMP)
+FROM alojamentos a
+WHERE deregistered_at IS NULL
+  AND NOT EXISTS (SELECT 1 FROM alojamentos_history h WHERE h.alojamento_id = a.id AND h.valid_to IS NULL);
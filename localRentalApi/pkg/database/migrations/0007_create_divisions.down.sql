@@ -0,0 +1,27 @@
+DROP INDEX IF EXISTS idx_freguesia_name_key;
+DROP INDEX IF EXISTS idx_concelho_name_key;
+DROP INDEX IF EXISTS idx_distrito_name_key;
+
+ALTER TABLE alojamentos_history DROP COLUMN IF EXISTS freguesia_name_key;
+ALTER TABLE alojamentos_history DROP COLUMN IF EXISTS concelho_name_key;
+ALTER TABLE alojamentos_history DROP COLUMN IF EXISTS distrito_name_key;
+
+ALTER TABLE alojamentos DROP COLUMN IF EXISTS freguesia_name_key;
+ALTER TABLE alojamentos DROP COLUMN IF EXISTS concelho_name_key;
+ALTER TABLE alojamentos DROP COLUMN IF EXISTS distrito_name_key;
+
+ALTER TABLE alojamentos_history DROP COLUMN IF EXISTS freguesia_code;
+ALTER TABLE alojamentos_history DROP COLUMN IF EXISTS concelho_code;
+ALTER TABLE alojamentos_history DROP COLUMN IF EXISTS distrito_code;
+
+DROP INDEX IF EXISTS idx_freguesia_code;
+DROP INDEX IF EXISTS idx_concelho_code;
+DROP INDEX IF EXISTS idx_distrito_code;
+
+ALTER TABLE alojamentos DROP COLUMN IF EXISTS freguesia_code;
+ALTER TABLE alojamentos DROP COLUMN IF EXISTS concelho_code;
+ALTER TABLE alojamentos DROP COLUMN IF EXISTS distrito_code;
+
+DROP TABLE IF EXISTS freguesias;
+DROP TABLE IF EXISTS concelhos;
+DROP TABLE IF EXISTS distritos;
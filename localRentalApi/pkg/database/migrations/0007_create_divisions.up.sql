@@ -0,0 +1,103 @@
+-- Administrative divisions identified by DICOFRE code. name_key holds the
+-- normalized name (see pkg/divisions.Normalize) that names are matched on.
+CREATE TABLE IF NOT EXISTS distritos (
+    code VARCHAR(2) PRIMARY KEY,
+    name TEXT NOT NULL,
+    name_key TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS concelhos (
+    code VARCHAR(4) PRIMARY KEY,
+    distrito_code VARCHAR(2) NOT NULL REFERENCES distritos(code),
+    name TEXT NOT NULL,
+    name_key TEXT NOT NULL,
+    UNIQUE (distrito_code, name_key)
+);
+
+CREATE TABLE IF NOT EXISTS freguesias (
+    code VARCHAR(6) PRIMARY KEY,
+    concelho_code VARCHAR(4) NOT NULL REFERENCES concelhos(code),
+    name TEXT NOT NULL,
+    name_key TEXT NOT NULL,
+    UNIQUE (concelho_code, name_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_concelhos_name_key ON concelhos(name_key);
+CREATE INDEX IF NOT EXISTS idx_freguesias_name_key ON freguesias(name_key);
+
+-- Distritos and, in the autonomous regions, islands. Concelhos and freguesias
+-- are loaded from the official list with `importer divisions`.
+INSERT INTO distritos (code, name, name_key) VALUES
+    ('01', 'Aveiro', 'aveiro'),
+    ('02', 'Beja', 'beja'),
+    ('03', 'Braga', 'braga'),
+    ('04', 'Bragança', 'braganca'),
+    ('05', 'Castelo Branco', 'castelo branco'),
+    ('06', 'Coimbra', 'coimbra'),
+    ('07', 'Évora', 'evora'),
+    ('08', 'Faro', 'faro'),
+    ('09', 'Guarda', 'guarda'),
+    ('10', 'Leiria', 'leiria'),
+    ('11', 'Lisboa', 'lisboa'),
+    ('12', 'Portalegre', 'portalegre'),
+    ('13', 'Porto', 'porto'),
+    ('14', 'Santarém', 'santarem'),
+    ('15', 'Setúbal', 'setubal'),
+    ('16', 'Viana do Castelo', 'viana do castelo'),
+    ('17', 'Vila Real', 'vila real'),
+    ('18', 'Viseu', 'viseu'),
+    ('31', 'Ilha da Madeira', 'ilha da madeira'),
+    ('32', 'Ilha de Porto Santo', 'ilha de porto santo'),
+    ('41', 'Ilha de Santa Maria', 'ilha de santa maria'),
+    ('42', 'Ilha de São Miguel', 'ilha de sao miguel'),
+    ('43', 'Ilha Terceira', 'ilha terceira'),
+    ('44', 'Ilha Graciosa', 'ilha graciosa'),
+    ('45', 'Ilha de São Jorge', 'ilha de sao jorge'),
+    ('46', 'Ilha do Pico', 'ilha do pico'),
+    ('47', 'Ilha do Faial', 'ilha do faial'),
+    ('48', 'Ilha das Flores', 'ilha das flores'),
+    ('49', 'Ilha do Corvo', 'ilha do corvo')
+ON CONFLICT (code) DO NOTHING;
+
+ALTER TABLE alojamentos ADD COLUMN IF NOT EXISTS distrito_code VARCHAR(2);
+ALTER TABLE alojamentos ADD COLUMN IF NOT EXISTS concelho_code VARCHAR(4);
+ALTER TABLE alojamentos ADD COLUMN IF NOT EXISTS freguesia_code VARCHAR(6);
+
+CREATE INDEX IF NOT EXISTS idx_distrito_code ON alojamentos(distrito_code);
+CREATE INDEX IF NOT EXISTS idx_concelho_code ON alojamentos(concelho_code);
+CREATE INDEX IF NOT EXISTS idx_freguesia_code ON alojamentos(freguesia_code);
+
+ALTER TABLE alojamentos_history ADD COLUMN IF NOT EXISTS distrito_code VARCHAR(2);
+ALTER TABLE alojamentos_history ADD COLUMN IF NOT EXISTS concelho_code VARCHAR(4);
+ALTER TABLE alojamentos_history ADD COLUMN IF NOT EXISTS freguesia_code VARCHAR(6);
+
+-- Division names as written in the source, keyed like the reference tables,
+-- so records whose division was not resolved to a code are still matched and
+-- grouped regardless of accents and case
+ALTER TABLE alojamentos ADD COLUMN IF NOT EXISTS distrito_name_key TEXT;
+ALTER TABLE alojamentos ADD COLUMN IF NOT EXISTS concelho_name_key TEXT;
+ALTER TABLE alojamentos ADD COLUMN IF NOT EXISTS freguesia_name_key TEXT;
+
+ALTER TABLE alojamentos_history ADD COLUMN IF NOT EXISTS distrito_name_key TEXT;
+ALTER TABLE alojamentos_history ADD COLUMN IF NOT EXISTS concelho_name_key TEXT;
+ALTER TABLE alojamentos_history ADD COLUMN IF NOT EXISTS freguesia_name_key TEXT;
+
+-- The importer writes the keys with pkg/divisions.Normalize from now on;
+-- existing rows are keyed here with the same rules, spelled out so they do not
+-- depend on the database locale: accented and ASCII capital letters mapped to
+-- their lowercase base letter, and runs of hyphens, ASCII whitespace and
+-- no-break spaces collapsed into one space. TestNormalizeMatchesMigration
+-- keeps the two in step.
+UPDATE alojamentos SET
+    distrito_name_key = btrim(regexp_replace(translate(distrito, 'áàâãäéèêëíìîïóòôõöúùûüçñÁÀÂÃÄÉÈÊËÍÌÎÏÓÒÔÕÖÚÙÛÜÇÑABCDEFGHIJKLMNOPQRSTUVWXYZ', 'aaaaaeeeeiiiiooooouuuucnaaaaaeeeeiiiiooooouuuucnabcdefghijklmnopqrstuvwxyz'), '[ \t\n\v\f\r\u00a0-]+', ' ', 'g')),
+    concelho_name_key = btrim(regexp_replace(translate(concelho, 'áàâãäéèêëíìîïóòôõöúùûüçñÁÀÂÃÄÉÈÊËÍÌÎÏÓÒÔÕÖÚÙÛÜÇÑABCDEFGHIJKLMNOPQRSTUVWXYZ', 'aaaaaeeeeiiiiooooouuuucnaaaaaeeeeiiiiooooouuuucnabcdefghijklmnopqrstuvwxyz'), '[ \t\n\v\f\r\u00a0-]+', ' ', 'g')),
+    freguesia_name_key = btrim(regexp_replace(translate(freguesia, 'áàâãäéèêëíìîïóòôõöúùûüçñÁÀÂÃÄÉÈÊËÍÌÎÏÓÒÔÕÖÚÙÛÜÇÑABCDEFGHIJKLMNOPQRSTUVWXYZ', 'aaaaaeeeeiiiiooooouuuucnaaaaaeeeeiiiiooooouuuucnabcdefghijklmnopqrstuvwxyz'), '[ \t\n\v\f\r\u00a0-]+', ' ', 'g'));
+
+UPDATE alojamentos_history SET
+    distrito_name_key = btrim(regexp_replace(translate(distrito, 'áàâãäéèêëíìîïóòôõöúùûüçñÁÀÂÃÄÉÈÊËÍÌÎÏÓÒÔÕÖÚÙÛÜÇÑABCDEFGHIJKLMNOPQRSTUVWXYZ', 'aaaaaeeeeiiiiooooouuuucnaaaaaeeeeiiiiooooouuuucnabcdefghijklmnopqrstuvwxyz'), '[ \t\n\v\f\r\u00a0-]+', ' ', 'g')),
+    concelho_name_key = btrim(regexp_replace(translate(concelho, 'áàâãäéèêëíìîïóòôõöúùûüçñÁÀÂÃÄÉÈÊËÍÌÎÏÓÒÔÕÖÚÙÛÜÇÑABCDEFGHIJKLMNOPQRSTUVWXYZ', 'aaaaaeeeeiiiiooooouuuucnaaaaaeeeeiiiiooooouuuucnabcdefghijklmnopqrstuvwxyz'), '[ \t\n\v\f\r\u00a0-]+', ' ', 'g')),
+    freguesia_name_key = btrim(regexp_replace(translate(freguesia, 'áàâãäéèêëíìîïóòôõöúùûüçñÁÀÂÃÄÉÈÊËÍÌÎÏÓÒÔÕÖÚÙÛÜÇÑABCDEFGHIJKLMNOPQRSTUVWXYZ', 'aaaaaeeeeiiiiooooouuuucnaaaaaeeeeiiiiooooouuuucnabcdefghijklmnopqrstuvwxyz'), '[ \t\n\v\f\r\u00a0-]+', ' ', 'g'));
+
+CREATE INDEX IF NOT EXISTS idx_distrito_name_key ON alojamentos(distrito_name_key) WHERE distrito_code IS NULL;
+CREATE INDEX IF NOT EXISTS idx_concelho_name_key ON alojamentos(concelho_name_key) WHERE concelho_code IS NULL;
+CREATE INDEX IF NOT EXISTS idx_freguesia_name_key ON alojamentos(freguesia_name_key) WHERE freguesia_code IS NULL;
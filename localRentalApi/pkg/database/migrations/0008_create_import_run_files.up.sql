@@ -0,0 +1,14 @@
+-- Per-file breakdown of import runs whose input spans several files
+CREATE TABLE IF NOT EXISTS import_run_files (
+    run_id INTEGER NOT NULL REFERENCES import_runs(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    source_file TEXT NOT NULL,
+    source_sha256 TEXT,
+    feature_count INTEGER NOT NULL DEFAULT 0,
+    imported_count INTEGER NOT NULL DEFAULT 0,
+    updated_count INTEGER NOT NULL DEFAULT 0,
+    skipped_count INTEGER NOT NULL DEFAULT 0,
+    failed_count INTEGER NOT NULL DEFAULT 0,
+    rejected_count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (run_id, position)
+);
@@ -0,0 +1,10 @@
+-- Reports written by cmd/dataquality, kept to follow data quality over time
+CREATE TABLE IF NOT EXISTS data_quality_reports (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    include_deregistered BOOLEAN NOT NULL DEFAULT FALSE,
+    record_count INTEGER NOT NULL,
+    report JSONB NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_quality_reports_created_at ON data_quality_reports(created_at);
@@ -0,0 +1,7 @@
+ALTER TABLE alojamentos_history DROP COLUMN IF EXISTS original_longitude;
+ALTER TABLE alojamentos_history DROP COLUMN IF EXISTS original_latitude;
+ALTER TABLE alojamentos_history DROP COLUMN IF EXISTS geocode_source;
+
+ALTER TABLE alojamentos DROP COLUMN IF EXISTS original_longitude;
+ALTER TABLE alojamentos DROP COLUMN IF EXISTS original_latitude;
+ALTER TABLE alojamentos DROP COLUMN IF EXISTS geocode_source;
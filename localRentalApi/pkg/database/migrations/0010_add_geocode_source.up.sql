@@ -0,0 +1,9 @@
+-- Records placed on their postal code centroid keep the coordinates read from
+-- the source in original_latitude/original_longitude
+ALTER TABLE alojamentos ADD COLUMN IF NOT EXISTS geocode_source TEXT NOT NULL DEFAULT 'original';
+ALTER TABLE alojamentos ADD COLUMN IF NOT EXISTS original_latitude DOUBLE PRECISION;
+ALTER TABLE alojamentos ADD COLUMN IF NOT EXISTS original_longitude DOUBLE PRECISION;
+
+ALTER TABLE alojamentos_history ADD COLUMN IF NOT EXISTS geocode_source TEXT NOT NULL DEFAULT 'original';
+ALTER TABLE alojamentos_history ADD COLUMN IF NOT EXISTS original_latitude DOUBLE PRECISION;
+ALTER TABLE alojamentos_history ADD COLUMN IF NOT EXISTS original_longitude DOUBLE PRECISION;
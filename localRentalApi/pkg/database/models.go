@@ -7,63 +7,53 @@ import (
 
 // Alojamento represents an accommodation record from the database
 type Alojamento struct {
-	ID                   int            `json:"id"`
-	ObjectID             sql.NullInt64  `json:"object_id,omitempty"`
-	NrRNAL               sql.NullInt64  `json:"nr_rnal"`
-	Denominacao          sql.NullString `json:"denominacao"`
-	DataRegisto          sql.NullTime   `json:"data_registo,omitempty"`
-	DataAberturaPublico  sql.NullTime   `json:"data_abertura_publico,omitempty"`
-	Modalidade           sql.NullString `json:"modalidade"`
-	NrUtentes            sql.NullInt64  `json:"nr_utentes"`
-	Email                sql.NullString `json:"email,omitempty"`
-	Endereco             sql.NullString `json:"endereco"`
-	CodigoPostal         sql.NullString `json:"codigo_postal"`
-	Localidade           sql.NullString `json:"localidade"`
-	Latitude             sql.NullFloat64 `json:"latitude"`
-	Longitude            sql.NullFloat64 `json:"longitude"`
-	FiabilidadeGeo       sql.NullString `json:"fiabilidade_geo,omitempty"`
-	Freguesia            sql.NullString `json:"freguesia"`
-	Concelho             sql.NullString `json:"concelho"`
-	Distrito             sql.NullString `json:"distrito"`
-	NutsIII              sql.NullString `json:"nuts_iii,omitempty"`
-	NutsII               sql.NullString `json:"nuts_ii,omitempty"`
-	Ert                  sql.NullString `json:"ert,omitempty"`
-	SeloCleanSafe        sql.NullString `json:"selo_clean_safe,omitempty"`
-	CreatedAt            time.Time      `json:"created_at"`
+	ID                  int             `json:"id"`
+	ObjectID            sql.NullInt64   `json:"object_id,omitempty"`
+	NrRNAL              sql.NullInt64   `json:"nr_rnal"`
+	Denominacao         sql.NullString  `json:"denominacao"`
+	DataRegisto         sql.NullTime    `json:"data_registo,omitempty"`
+	DataAberturaPublico sql.NullTime    `json:"data_abertura_publico,omitempty"`
+	Modalidade          sql.NullString  `json:"modalidade"`
+	NrUtentes           sql.NullInt64   `json:"nr_utentes"`
+	Email               sql.NullString  `json:"email,omitempty"`
+	Endereco            sql.NullString  `json:"endereco"`
+	CodigoPostal        sql.NullString  `json:"codigo_postal"`
+	Localidade          sql.NullString  `json:"localidade"`
+	Latitude            sql.NullFloat64 `json:"latitude"`
+	Longitude           sql.NullFloat64 `json:"longitude"`
+	FiabilidadeGeo      sql.NullString  `json:"fiabilidade_geo,omitempty"`
+	Freguesia           sql.NullString  `json:"freguesia"`
+	Concelho            sql.NullString  `json:"concelho"`
+	Distrito            sql.NullString  `json:"distrito"`
+	NutsIII             sql.NullString  `json:"nuts_iii,omitempty"`
+	NutsII              sql.NullString  `json:"nuts_ii,omitempty"`
+	Ert                 sql.NullString  `json:"ert,omitempty"`
+	SeloCleanSafe       sql.NullString  `json:"selo_clean_safe,omitempty"`
+	CreatedAt           time.Time       `json:"created_at"`
+	UpdatedAt           sql.NullTime    `json:"updated_at,omitempty"`
+	DeregisteredAt      sql.NullTime    `json:"deregistered_at,omitempty"`
+	LatLongMismatch     bool            `json:"latlong_mismatch"`
+	LatLongDistanceM    sql.NullFloat64 `json:"latlong_distance_m,omitempty"`
+	DistritoCode        sql.NullString  `json:"distrito_code,omitempty"`
+	ConcelhoCode        sql.NullString  `json:"concelho_code,omitempty"`
+	FreguesiaCode       sql.NullString  `json:"freguesia_code,omitempty"`
+	GeocodeSource       string          `json:"geocode_source"`
+	OriginalLatitude    sql.NullFloat64 `json:"original_latitude,omitempty"`
+	OriginalLongitude   sql.NullFloat64 `json:"original_longitude,omitempty"`
 }
 
 // Scan scans a database row into an Alojamento struct
 func (a *Alojamento) Scan(rows *sql.Rows) error {
-	return rows.Scan(
-		&a.ID,
-		&a.ObjectID,
-		&a.NrRNAL,
-		&a.Denominacao,
-		&a.DataRegisto,
-		&a.DataAberturaPublico,
-		&a.Modalidade,
-		&a.NrUtentes,
-		&a.Email,
-		&a.Endereco,
-		&a.CodigoPostal,
-		&a.Localidade,
-		&a.Latitude,
-		&a.Longitude,
-		&a.FiabilidadeGeo,
-		&a.Freguesia,
-		&a.Concelho,
-		&a.Distrito,
-		&a.NutsIII,
-		&a.NutsII,
-		&a.Ert,
-		&a.SeloCleanSafe,
-		&a.CreatedAt,
-	)
+	return rows.Scan(a.fields()...)
 }
 
 // ScanRow scans a single database row into an Alojamento struct
 func (a *Alojamento) ScanRow(row *sql.Row) error {
-	return row.Scan(
+	return row.Scan(a.fields()...)
+}
+
+func (a *Alojamento) fields() []any {
+	return []any{
 		&a.ID,
 		&a.ObjectID,
 		&a.NrRNAL,
@@ -87,7 +77,40 @@ func (a *Alojamento) ScanRow(row *sql.Row) error {
 		&a.Ert,
 		&a.SeloCleanSafe,
 		&a.CreatedAt,
-	)
+		&a.UpdatedAt,
+		&a.DeregisteredAt,
+		&a.LatLongMismatch,
+		&a.LatLongDistanceM,
+		&a.DistritoCode,
+		&a.ConcelhoCode,
+		&a.FreguesiaCode,
+		&a.GeocodeSource,
+		&a.OriginalLatitude,
+		&a.OriginalLongitude,
+	}
+}
+
+// AlojamentoVersion is one version of a registration from alojamentos_history
+type AlojamentoVersion struct {
+	Alojamento
+	ValidFrom time.Time    `json:"valid_from"`
+	ValidTo   sql.NullTime `json:"valid_to,omitempty"`
+}
+
+// Scan scans a database row into an AlojamentoVersion struct
+func (v *AlojamentoVersion) Scan(rows *sql.Rows) error {
+	return rows.Scan(append(v.Alojamento.fields(), &v.ValidFrom, &v.ValidTo)...)
+}
+
+// AlojamentoDistance is an accommodation with its distance from a search point
+type AlojamentoDistance struct {
+	Alojamento
+	DistanceM float64 `json:"distance_m"`
+}
+
+// Scan scans a database row into an AlojamentoDistance struct
+func (d *AlojamentoDistance) Scan(rows *sql.Rows) error {
+	return rows.Scan(append(d.Alojamento.fields(), &d.DistanceM)...)
 }
 
 // StatsByConcelho represents accommodation statistics by municipality
@@ -107,3 +130,72 @@ type StatsByModalidade struct {
 	Modalidade string `json:"modalidade"`
 	Count      int    `json:"count"`
 }
+
+// ImportRun represents a row of the import_runs ledger written by the importer
+type ImportRun struct {
+	ID                int            `json:"id"`
+	SourceFile        string         `json:"source_file"`
+	SourceSHA256      sql.NullString `json:"source_sha256,omitempty"`
+	Mode              string         `json:"mode"`
+	FeatureCount      int            `json:"feature_count"`
+	ImportedCount     int            `json:"imported_count"`
+	UpdatedCount      int            `json:"updated_count"`
+	SkippedCount      int            `json:"skipped_count"`
+	FailedCount       int            `json:"failed_count"`
+	RejectedCount     int            `json:"rejected_count"`
+	DeregisteredCount int            `json:"deregistered_count"`
+	Status            string         `json:"status"`
+	Error             sql.NullString `json:"error,omitempty"`
+	StartedAt         time.Time      `json:"started_at"`
+	FinishedAt        sql.NullTime   `json:"finished_at,omitempty"`
+}
+
+// Scan scans a database row into an ImportRun struct
+func (r *ImportRun) Scan(rows *sql.Rows) error {
+	return rows.Scan(r.fields()...)
+}
+
+// ScanRow scans a single database row into an ImportRun struct
+func (r *ImportRun) ScanRow(row *sql.Row) error {
+	return row.Scan(r.fields()...)
+}
+
+func (r *ImportRun) fields() []any {
+	return []any{
+		&r.ID,
+		&r.SourceFile,
+		&r.SourceSHA256,
+		&r.Mode,
+		&r.FeatureCount,
+		&r.ImportedCount,
+		&r.UpdatedCount,
+		&r.SkippedCount,
+		&r.FailedCount,
+		&r.RejectedCount,
+		&r.DeregisteredCount,
+		&r.Status,
+		&r.Error,
+		&r.StartedAt,
+		&r.FinishedAt,
+	}
+}
+
+// ImportRunFile represents a row of import_run_files, one input file of a run
+type ImportRunFile struct {
+	RunID         int            `json:"run_id"`
+	Position      int            `json:"position"`
+	SourceFile    string         `json:"source_file"`
+	SourceSHA256  sql.NullString `json:"source_sha256,omitempty"`
+	FeatureCount  int            `json:"feature_count"`
+	ImportedCount int            `json:"imported_count"`
+	UpdatedCount  int            `json:"updated_count"`
+	SkippedCount  int            `json:"skipped_count"`
+	FailedCount   int            `json:"failed_count"`
+	RejectedCount int            `json:"rejected_count"`
+}
+
+// Scan scans a database row into an ImportRunFile struct
+func (f *ImportRunFile) Scan(rows *sql.Rows) error {
+	return rows.Scan(&f.RunID, &f.Position, &f.SourceFile, &f.SourceSHA256, &f.FeatureCount,
+		&f.ImportedCount, &f.UpdatedCount, &f.SkippedCount, &f.FailedCount, &f.RejectedCount)
+}
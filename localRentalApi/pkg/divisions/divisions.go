@@ -0,0 +1,90 @@
+package divisions
+
+import (
+	"strings"
+)
+
+// DICOFRE codes identify administrative divisions: two digits for the distrito
+// (or island), four for the concelho and six for the freguesia, each code
+// starting with the code of the division that contains it
+const (
+	DistritoCodeLength  = 2
+	ConcelhoCodeLength  = 4
+	FreguesiaCodeLength = 6
+)
+
+// accents maps the accented letters found in Portuguese names, in either case,
+// to their lowercase base letter
+var accents = map[rune]rune{
+	'á': 'a', 'à': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a',
+	'é': 'e', 'è': 'e', 'ê': 'e', 'ë': 'e',
+	'í': 'i', 'ì': 'i', 'î': 'i', 'ï': 'i',
+	'ó': 'o', 'ò': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o',
+	'ú': 'u', 'ù': 'u', 'û': 'u', 'ü': 'u',
+	'ç': 'c', 'ñ': 'n',
+	'Á': 'a', 'À': 'a', 'Â': 'a', 'Ã': 'a', 'Ä': 'a',
+	'É': 'e', 'È': 'e', 'Ê': 'e', 'Ë': 'e',
+	'Í': 'i', 'Ì': 'i', 'Î': 'i', 'Ï': 'i',
+	'Ó': 'o', 'Ò': 'o', 'Ô': 'o', 'Õ': 'o', 'Ö': 'o',
+	'Ú': 'u', 'Ù': 'u', 'Û': 'u', 'Ü': 'u',
+	'Ç': 'c', 'Ñ': 'n',
+}
+
+// Normalize returns the key names are matched on: lowercase, without accents,
+// with hyphens read as spaces and runs of whitespace collapsed, so that
+// "Olhão", " OLHAO " and "olhao" share the key "olhao".
+//
+// Migration 0007 computes the same key in SQL for rows stored before the
+// importer wrote it, with translate and regexp_replace, so the rules avoid
+// anything that depends on the database locale: only the letters in accents
+// and ASCII capitals are lowercased, and only hyphens, ASCII whitespace and
+// no-break spaces separate words. A change here needs a migration updating
+// the stored keys.
+func Normalize(name string) string {
+	var b strings.Builder
+	b.Grow(len(name))
+
+	space := false
+	for _, r := range name {
+		if base, ok := accents[r]; ok {
+			r = base
+		} else if 'A' <= r && r <= 'Z' {
+			r += 'a' - 'A'
+		}
+		if isSeparator(r) {
+			space = b.Len() > 0
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// isSeparator reports whether r separates the words of a name. PostgreSQL's
+// [[:space:]] class follows the database locale, so the migration lists the
+// same characters instead.
+func isSeparator(r rune) bool {
+	switch r {
+	case '-', ' ', '\t', '\n', '\v', '\f', '\r', '\u00a0':
+		return true
+	}
+	return false
+}
+
+// IsCode reports whether value is a DICOFRE code of the given length
+func IsCode(value string, length int) bool {
+	if len(value) != length {
+		return false
+	}
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
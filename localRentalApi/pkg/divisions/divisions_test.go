@@ -0,0 +1,96 @@
+package divisions
+
+import (
+	"regexp"
+	"testing"
+
+	"localRental/pkg/database"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Olhão", "olhao"},
+		{" OLHÃO ", "olhao"},
+		{"olhao", "olhao"},
+		{"SÃO BRÁS DE ALPORTEL", "sao bras de alportel"},
+		{"Vila Nova de Gaia", "vila nova de gaia"},
+		{"Vila-Nova  de\tGaia", "vila nova de gaia"},
+		{"Montemor-o-Novo", "montemor o novo"},
+		{"Ponte - de - Lima", "ponte de lima"},
+		{"Vila\u00a0Real", "vila real"},
+		{"\r\n\vÉvora\f", "evora"},
+		{"Ílhavo", "ilhavo"},
+		{"Oeiras, São Julião da Barra", "oeiras, sao juliao da barra"},
+		{"", ""},
+		{" - ", ""},
+		// Outside the rules the migration mirrors: kept as written
+		{"Vila\u2003Real", "vila\u2003real"},
+		{"Ærø", "Ærø"},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.name); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestNormalizeMatchesMigration checks that the SQL in migration 0007, which
+// keys the rows stored before the importer did, applies the same rules as
+// Normalize
+func TestNormalizeMatchesMigration(t *testing.T) {
+	migrations, err := database.Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var up string
+	for _, m := range migrations {
+		if m.Version == 7 {
+			up = m.Up
+		}
+	}
+
+	keyExpr := regexp.MustCompile(`(\w+)_name_key = btrim\(regexp_replace\(translate\((\w+), '([^']*)', '([^']*)'\), '([^']*)', ' ', 'g'\)\)`)
+	matches := keyExpr.FindAllStringSubmatch(up, -1)
+	if len(matches) != 6 {
+		t.Fatalf("found %d name key expressions in migration 0007, want 6", len(matches))
+	}
+
+	for _, m := range matches {
+		column, from, to, pattern := m[2], []rune(m[3]), []rune(m[4]), m[5]
+		if m[1] != column {
+			t.Errorf("%s_name_key is computed from %s", m[1], column)
+		}
+		if len(from) != len(to) {
+			t.Fatalf("translate maps %d letters to %d", len(from), len(to))
+		}
+
+		// translate covers exactly the letters Normalize lowercases
+		mapped := make(map[rune]bool)
+		for i, r := range from {
+			mapped[r] = true
+			if got := Normalize(string(r)); got != string(to[i]) {
+				t.Errorf("translate maps %q to %q, Normalize to %q", r, to[i], got)
+			}
+		}
+		for r := rune(0); r < 0x3000; r++ {
+			if !mapped[r] && !isSeparator(r) && Normalize(string(r)) != string(r) {
+				t.Errorf("Normalize changes %q, which translate leaves alone", r)
+			}
+		}
+
+		// The regular expression collapses exactly the runs Normalize does
+		if want := `[ \t\n\v\f\r\u00a0-]+`; pattern != want {
+			t.Errorf("separator pattern %s, want %s", pattern, want)
+		}
+		for r := rune(0); r < 0x3000; r++ {
+			sep := Normalize("a"+string(r)+"b") == "a b"
+			if sep != isSeparator(r) {
+				t.Errorf("Normalize treats %q as a separator: %v", r, sep)
+			}
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ellipsoid is a reference ellipsoid given by its semi-major axis in metres
+// and its flattening
+type ellipsoid struct {
+	a, f float64
+}
+
+var (
+	wgs84Ellipsoid = ellipsoid{a: 6378137, f: 1 / 298.257223563}
+	grs80Ellipsoid = ellipsoid{a: 6378137, f: 1 / 298.257222101}
+	// International 1924, also known as Hayford 1909
+	hayfordEllipsoid = ellipsoid{a: 6378388, f: 1.0 / 297}
+)
+
+// e2 returns the square of the first eccentricity
+func (e ellipsoid) e2() float64 {
+	return e.f * (2 - e.f)
+}
+
+// transverseMercator holds the parameters of a transverse Mercator projection.
+// Angles are in degrees, with longitudes relative to Greenwich.
+type transverseMercator struct {
+	ellipsoid     ellipsoid
+	lat0, lon0    float64
+	k0            float64
+	falseEasting  float64
+	falseNorthing float64
+}
+
+// CRS is a coordinate reference system that input coordinates can be converted
+// from. Geographic systems on a datum within a metre of WGS84 (WGS84 itself,
+// ETRS89) need no conversion.
+type CRS struct {
+	Name string
+	// projection is nil for geographic longitude/latitude
+	projection *transverseMercator
+	// toWGS84 is the geocentric translation in metres from the datum of the
+	// projection to WGS84
+	toWGS84 [3]float64
+}
+
+// WGS84 is the default GeoJSON coordinate reference system, longitude and
+// latitude in degrees
+var WGS84 = CRS{Name: "WGS84"}
+
+// Portuguese mainland systems, identified by EPSG code. The Lisbon datum
+// systems are the legacy Hayford-Gauss grids; their central meridian is given
+// here relative to Greenwich.
+var knownCRS = map[int]CRS{
+	4326: WGS84,
+	4258: {Name: "ETRS89"},
+	3763: {
+		Name: "ETRS89 / Portugal TM06",
+		projection: &transverseMercator{
+			ellipsoid: grs80Ellipsoid,
+			lat0:      39.66825833333333,
+			lon0:      -8.133108333333334,
+			k0:        1,
+		},
+	},
+	20790: {
+		Name: "Lisbon (Lisbon) / Portuguese National Grid (Hayford-Gauss, military)",
+		projection: &transverseMercator{
+			ellipsoid:     hayfordEllipsoid,
+			lat0:          39.666666666666664,
+			lon0:          -8.131906111111112,
+			k0:            1,
+			falseEasting:  200000,
+			falseNorthing: 300000,
+		},
+		toWGS84: [3]float64{-304.046, -60.576, 103.64},
+	},
+	20791: {
+		Name: "Lisbon (Lisbon) / Portuguese Grid (Hayford-Gauss)",
+		projection: &transverseMercator{
+			ellipsoid: hayfordEllipsoid,
+			lat0:      39.666666666666664,
+			lon0:      -8.131906111111112,
+			k0:        1,
+		},
+		toWGS84: [3]float64{-304.046, -60.576, 103.64},
+	},
+	5018: {
+		Name: "Lisbon / Portuguese Grid New (Hayford-Gauss)",
+		projection: &transverseMercator{
+			ellipsoid: hayfordEllipsoid,
+			lat0:      39.666666666666664,
+			lon0:      -8.131906111111112,
+			k0:        1,
+		},
+		toWGS84: [3]float64{-304.046, -60.576, 103.64},
+	},
+	27492: {
+		Name: "Datum 73 / Portuguese Grid (Hayford-Gauss)",
+		projection: &transverseMercator{
+			ellipsoid: hayfordEllipsoid,
+			lat0:      39.666666666666664,
+			lon0:      -8.131906111111112,
+			k0:        1,
+		},
+		toWGS84: [3]float64{-223.237, 110.193, 36.649},
+	},
+	27493: {
+		Name: "Datum 73 / Modified Portuguese Grid (Hayford-Gauss)",
+		projection: &transverseMercator{
+			ellipsoid:     hayfordEllipsoid,
+			lat0:          39.666666666666664,
+			lon0:          -8.131906111111112,
+			k0:            1,
+			falseEasting:  180.598,
+			falseNorthing: -86.99,
+		},
+		toWGS84: [3]float64{-223.237, 110.193, 36.649},
+	},
+}
+
+// ParseCRS resolves a CRS name as found in a GeoJSON crs member or given on
+// the command line: "EPSG:3763", "urn:ogc:def:crs:EPSG::3763",
+// "http://www.opengis.net/def/crs/EPSG/0/3763", a bare EPSG code, or one of the
+// CRS84 names. Unsupported systems are an error, so coordinates are never
+// written in a system they were not converted from.
+func ParseCRS(name string) (CRS, error) {
+	upper := strings.ToUpper(strings.TrimSpace(name))
+	if upper == "" || strings.HasSuffix(upper, "CRS84") || upper == "WGS84" {
+		return WGS84, nil
+	}
+
+	// The EPSG code is the last component of every supported form
+	code := upper
+	if i := strings.LastIndexAny(upper, ":/"); i >= 0 {
+		if !strings.Contains(upper, "EPSG") {
+			return CRS{}, fmt.Errorf("unsupported CRS %q", name)
+		}
+		code = upper[i+1:]
+	}
+
+	epsg, err := strconv.Atoi(code)
+	if err != nil {
+		return CRS{}, fmt.Errorf("unsupported CRS %q", name)
+	}
+
+	crs, ok := knownCRS[epsg]
+	if !ok {
+		return CRS{}, fmt.Errorf("unsupported CRS EPSG:%d", epsg)
+	}
+	return crs, nil
+}
+
+// IsWGS84 reports whether coordinates in c can be used without conversion
+func (c CRS) IsWGS84() bool {
+	return c.projection == nil
+}
+
+// ToWGS84 converts a position given as x/y in c (easting/northing in metres,
+// or longitude/latitude in degrees) to WGS84 longitude and latitude
+func (c CRS) ToWGS84(x, y float64) (lng, lat float64) {
+	if c.projection == nil {
+		return x, y
+	}
+
+	lat, lng = c.projection.inverse(x, y)
+	if c.toWGS84 != [3]float64{} {
+		lat, lng = shiftDatum(lat, lng, c.projection.ellipsoid, c.toWGS84)
+	}
+	return lng, lat
+}
+
+// meridianArc returns the distance in metres along the meridian from the
+// equator to latitude phi, in radians
+func (e ellipsoid) meridianArc(phi float64) float64 {
+	e2 := e.e2()
+	e4 := e2 * e2
+	e6 := e4 * e2
+
+	return e.a * ((1-e2/4-3*e4/64-5*e6/256)*phi -
+		(3*e2/8+3*e4/32+45*e6/1024)*math.Sin(2*phi) +
+		(15*e4/256+45*e6/1024)*math.Sin(4*phi) -
+		(35*e6/3072)*math.Sin(6*phi))
+}
+
+// inverse converts easting/northing to latitude/longitude in degrees on the
+// projection's ellipsoid, following Snyder, "Map Projections: A Working
+// Manual", equations 8-18 to 8-25
+func (p *transverseMercator) inverse(easting, northing float64) (lat, lng float64) {
+	e2 := p.ellipsoid.e2()
+	ep2 := e2 / (1 - e2)
+	a := p.ellipsoid.a
+
+	m := p.ellipsoid.meridianArc(p.lat0*math.Pi/180) + (northing-p.falseNorthing)/p.k0
+	mu := m / (a * (1 - e2/4 - 3*e2*e2/64 - 5*e2*e2*e2/256))
+
+	e1 := (1 - math.Sqrt(1-e2)) / (1 + math.Sqrt(1-e2))
+	phi1 := mu +
+		(3*e1/2-27*math.Pow(e1, 3)/32)*math.Sin(2*mu) +
+		(21*e1*e1/16-55*math.Pow(e1, 4)/32)*math.Sin(4*mu) +
+		(151*math.Pow(e1, 3)/96)*math.Sin(6*mu) +
+		(1097*math.Pow(e1, 4)/512)*math.Sin(8*mu)
+
+	sin1, cos1, tan1 := math.Sin(phi1), math.Cos(phi1), math.Tan(phi1)
+	c1 := ep2 * cos1 * cos1
+	t1 := tan1 * tan1
+	n1 := a / math.Sqrt(1-e2*sin1*sin1)
+	r1 := a * (1 - e2) / math.Pow(1-e2*sin1*sin1, 1.5)
+	d := (easting - p.falseEasting) / (n1 * p.k0)
+
+	phi := phi1 - (n1*tan1/r1)*(d*d/2-
+		(5+3*t1+10*c1-4*c1*c1-9*ep2)*math.Pow(d, 4)/24+
+		(61+90*t1+298*c1+45*t1*t1-252*ep2-3*c1*c1)*math.Pow(d, 6)/720)
+	lambda := (d - (1+2*t1+c1)*math.Pow(d, 3)/6 +
+		(5-2*c1+28*t1-3*c1*c1+8*ep2+24*t1*t1)*math.Pow(d, 5)/120) / cos1
+
+	return phi * 180 / math.Pi, p.lon0 + lambda*180/math.Pi
+}
+
+// shiftDatum moves a position from the datum of ellipsoid e to WGS84 through
+// a geocentric translation, at zero ellipsoidal height
+func shiftDatum(lat, lng float64, e ellipsoid, shift [3]float64) (float64, float64) {
+	phi := lat * math.Pi / 180
+	lambda := lng * math.Pi / 180
+
+	// Geodetic to geocentric on the source ellipsoid
+	e2 := e.e2()
+	n := e.a / math.Sqrt(1-e2*math.Sin(phi)*math.Sin(phi))
+	x := n*math.Cos(phi)*math.Cos(lambda) + shift[0]
+	y := n*math.Cos(phi)*math.Sin(lambda) + shift[1]
+	z := n*(1-e2)*math.Sin(phi) + shift[2]
+
+	// Geocentric to geodetic on WGS84, iterating on the latitude
+	w := wgs84Ellipsoid
+	e2 = w.e2()
+	p := math.Hypot(x, y)
+	phi = math.Atan2(z, p*(1-e2))
+	for range 5 {
+		n = w.a / math.Sqrt(1-e2*math.Sin(phi)*math.Sin(phi))
+		h := p/math.Cos(phi) - n
+		phi = math.Atan2(z, p*(1-e2*n/(n+h)))
+	}
+
+	return phi * 180 / math.Pi, math.Atan2(y, x) * 180 / math.Pi
+}
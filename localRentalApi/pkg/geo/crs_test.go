@@ -0,0 +1,79 @@
+package geo
+
+import "testing"
+
+// The reference positions below follow from the EPSG definitions. The
+// origins are the projection parameters themselves: PT-TM06 has its natural
+// origin at 39°40'05.73"N 8°07'59.19"W, and the Hayford-Gauss grids theirs at
+// 39°40'N 1° east of the Lisbon meridian, moved to WGS84 with the published
+// geocentric translations (EPSG:1984 for Lisbon, EPSG:1987 for Datum 73). The
+// city positions were projected forward from WGS84 with the same parameters,
+// through an independent sixth-order Krüger series, so they exercise the
+// inverse series away from the central meridian.
+func TestCRSToWGS84(t *testing.T) {
+	tests := []struct {
+		name     string
+		epsg     string
+		x, y     float64
+		lat, lng float64
+	}{
+		{"PT-TM06 origin", "EPSG:3763", 0, 0, 39.66825833333333, -8.133108333333334},
+		{"PT-TM06 Lisboa", "EPSG:3763", -87277.686, -106162.365, 38.7077, -9.1365},
+		{"PT-TM06 Porto", "EPSG:3763", -40110.562, 164157.714, 41.1456, -8.6109},
+		{"PT-TM06 Faro", "EPSG:3763", 17878.652, -294012.455, 37.0194, -7.9322},
+		{"PT-TM06 Bragança", "EPSG:3763", 114383.264, 238320.818, 41.8061, -6.7567},
+
+		{"Lisbon military grid origin", "EPSG:20790", 200000, 300000, 39.66825816501321, -8.133106196290665},
+		{"Lisbon military grid Lisboa", "EPSG:20790", 112720.004, 193837.889, 38.7077, -9.1365},
+		{"Lisbon military grid Porto", "EPSG:20790", 159891.113, 464159.758, 41.1456, -8.6109},
+		{"Lisbon military grid Faro", "EPSG:20790", 217874.774, 5984.984, 37.0194, -7.9322},
+		{"Lisbon military grid Bragança", "EPSG:20790", 314387.432, 538321.387, 41.8061, -6.7567},
+
+		{"Datum 73 modified grid origin", "EPSG:27493", 180.598, -86.99, 39.66747268060057, -8.131002836987637},
+		{"Datum 73 modified grid Lisboa", "EPSG:27493", -87279.905, -106161.896, 38.7077, -9.1365},
+		{"Datum 73 modified grid Porto", "EPSG:27493", -40108.761, 164159.959, 41.1456, -8.6109},
+		{"Datum 73 modified grid Faro", "EPSG:27493", 17874.900, -294014.897, 37.0194, -7.9322},
+		{"Datum 73 modified grid Bragança", "EPSG:27493", 114387.492, 238321.571, 41.8061, -6.7567},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crs, err := ParseCRS(tt.epsg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lng, lat := crs.ToWGS84(tt.x, tt.y)
+			if d := DistanceMeters(lat, lng, tt.lat, tt.lng); d > 1 {
+				t.Errorf("ToWGS84(%v, %v) = %.8f, %.8f, %.2f m from %.8f, %.8f",
+					tt.x, tt.y, lat, lng, d, tt.lat, tt.lng)
+			}
+		})
+	}
+}
+
+func TestParseCRS(t *testing.T) {
+	for _, name := range []string{"EPSG:3763", "urn:ogc:def:crs:EPSG::3763", "http://www.opengis.net/def/crs/EPSG/0/3763", "3763"} {
+		crs, err := ParseCRS(name)
+		if err != nil {
+			t.Errorf("ParseCRS(%q): %v", name, err)
+			continue
+		}
+		if crs.Name != "ETRS89 / Portugal TM06" {
+			t.Errorf("ParseCRS(%q) = %s", name, crs.Name)
+		}
+	}
+
+	for _, name := range []string{"", "urn:ogc:def:crs:OGC:1.3:CRS84", "EPSG:4326"} {
+		crs, err := ParseCRS(name)
+		if err != nil || !crs.IsWGS84() {
+			t.Errorf("ParseCRS(%q) = %v, %v, want a geographic CRS", name, crs.Name, err)
+		}
+	}
+
+	for _, name := range []string{"EPSG:32629", "urn:ogc:def:crs:OGC::foo", "Portugal"} {
+		if _, err := ParseCRS(name); err == nil {
+			t.Errorf("ParseCRS(%q) succeeded, want an unsupported CRS error", name)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package geo
+
+import "math"
+
+// EarthRadiusMeters is the mean Earth radius used for great-circle distances
+const EarthRadiusMeters = 6371008.8
+
+// DistanceMeters returns the great-circle (haversine) distance in metres
+// between two WGS84 points given in degrees
+func DistanceMeters(lat1, lng1, lat2, lng2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dPhi := (lat2 - lat1) * math.Pi / 180
+	dLambda := (lng2 - lng1) * math.Pi / 180
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) +
+		math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+
+	return 2 * EarthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// BoundingBox returns a latitude/longitude box enclosing every point within
+// radius metres of (lat, lng), for prefiltering distance queries on an index.
+// Longitudes are clamped to [-180, 180] rather than wrapped at the antimeridian.
+func BoundingBox(lat, lng, radius float64) (minLat, maxLat, minLng, maxLng float64) {
+	dLat := radius / EarthRadiusMeters * 180 / math.Pi
+	minLat = math.Max(lat-dLat, -90)
+	maxLat = math.Min(lat+dLat, 90)
+
+	// The circle is widest at the box edge furthest from the equator
+	widest := math.Max(math.Abs(minLat), math.Abs(maxLat))
+	if widest >= 90 {
+		return minLat, maxLat, -180, 180
+	}
+	dLng := dLat / math.Cos(widest*math.Pi/180)
+	return minLat, maxLat, math.Max(lng-dLng, -180), math.Min(lng+dLng, 180)
+}
+
+// MaxWebMercatorLat is the latitude where the Web Mercator square ends
+const MaxWebMercatorLat = 85.05112878
+
+// WebMercator projects a WGS84 position to Web Mercator coordinates in
+// [0, 1], x growing eastwards from the antimeridian and y southwards from the
+// top of the square. Latitudes beyond MaxWebMercatorLat are clamped.
+func WebMercator(lat, lng float64) (x, y float64) {
+	lat = math.Max(-MaxWebMercatorLat, math.Min(MaxWebMercatorLat, lat))
+	phi := lat * math.Pi / 180
+	x = (lng + 180) / 360
+	y = (1 - math.Log(math.Tan(phi)+1/math.Cos(phi))/math.Pi) / 2
+	return math.Max(0, math.Min(1, x)), math.Max(0, math.Min(1, y))
+}
+
+// WebMercatorInverse converts Web Mercator coordinates in [0, 1] back to a
+// WGS84 latitude and longitude
+func WebMercatorInverse(x, y float64) (lat, lng float64) {
+	lng = x*360 - 180
+	lat = math.Atan(math.Sinh(math.Pi*(1-2*y))) * 180 / math.Pi
+	return lat, lng
+}
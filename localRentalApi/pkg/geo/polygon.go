@@ -0,0 +1,145 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MaxPolygonVertices bounds the size of the shapes accepted as search filters
+const MaxPolygonVertices = 10000
+
+// Ring is a closed list of [longitude, latitude] positions, its first and last
+// positions being equal
+type Ring [][2]float64
+
+// Polygon is an outer ring followed by the rings of its holes
+type Polygon []Ring
+
+// MultiPolygon is a set of polygons; a point lies in it when it lies in any of
+// them. A GeoJSON Polygon is read as a MultiPolygon of one.
+type MultiPolygon []Polygon
+
+// ParseGeoJSONPolygon reads the coordinates of a GeoJSON Polygon or
+// MultiPolygon geometry and checks they describe valid WGS84 rings
+func ParseGeoJSONPolygon(geometryType string, coordinates json.RawMessage) (MultiPolygon, error) {
+	var raw [][][][]float64
+	switch geometryType {
+	case "Polygon":
+		var polygon [][][]float64
+		if err := json.Unmarshal(coordinates, &polygon); err != nil {
+			return nil, errors.New("polygon coordinates must be an array of rings of [longitude, latitude] positions")
+		}
+		raw = [][][][]float64{polygon}
+	case "MultiPolygon":
+		if err := json.Unmarshal(coordinates, &raw); err != nil {
+			return nil, errors.New("multipolygon coordinates must be an array of polygons of rings of [longitude, latitude] positions")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported geometry type %q (expected Polygon or MultiPolygon)", geometryType)
+	}
+
+	if len(raw) == 0 {
+		return nil, errors.New("geometry has no polygons")
+	}
+
+	vertices := 0
+	shape := make(MultiPolygon, len(raw))
+	for i, polygon := range raw {
+		if len(polygon) == 0 {
+			return nil, fmt.Errorf("polygon %d has no rings", i)
+		}
+
+		shape[i] = make(Polygon, len(polygon))
+		for j, positions := range polygon {
+			ring, err := parseRing(positions)
+			if err != nil {
+				return nil, fmt.Errorf("polygon %d, ring %d: %w", i, j, err)
+			}
+			vertices += len(ring)
+			shape[i][j] = ring
+		}
+	}
+
+	if vertices > MaxPolygonVertices {
+		return nil, fmt.Errorf("geometry has %d positions, more than the %d allowed", vertices, MaxPolygonVertices)
+	}
+	return shape, nil
+}
+
+// parseRing checks a ring is closed, has at least four positions and only
+// holds WGS84 positions
+func parseRing(positions [][]float64) (Ring, error) {
+	if len(positions) < 4 {
+		return nil, errors.New("a ring needs at least four positions")
+	}
+
+	ring := make(Ring, len(positions))
+	for k, p := range positions {
+		if len(p) < 2 {
+			return nil, fmt.Errorf("position %d needs a longitude and a latitude", k)
+		}
+		lng, lat := p[0], p[1]
+		if math.IsNaN(lng) || lng < -180 || lng > 180 || math.IsNaN(lat) || lat < -90 || lat > 90 {
+			return nil, fmt.Errorf("position %d is not a valid longitude/latitude", k)
+		}
+		ring[k] = [2]float64{lng, lat}
+	}
+
+	if ring[0] != ring[len(ring)-1] {
+		return nil, errors.New("a ring must end at its first position")
+	}
+
+	// Edges are taken as straight lines in longitude/latitude, which only holds
+	// for rings that do not wrap around the antimeridian
+	minLng, maxLng := ring.lngRange()
+	if maxLng-minLng > 180 {
+		return nil, errors.New("rings spanning more than 180 degrees of longitude are not supported")
+	}
+	return ring, nil
+}
+
+func (r Ring) lngRange() (minLng, maxLng float64) {
+	minLng, maxLng = math.Inf(1), math.Inf(-1)
+	for _, p := range r {
+		minLng = math.Min(minLng, p[0])
+		maxLng = math.Max(maxLng, p[0])
+	}
+	return minLng, maxLng
+}
+
+// Bounds returns the latitude/longitude box enclosing the outer ring
+func (p Polygon) Bounds() (minLat, maxLat, minLng, maxLng float64) {
+	minLat, minLng = math.Inf(1), math.Inf(1)
+	maxLat, maxLng = math.Inf(-1), math.Inf(-1)
+	for _, pos := range p[0] {
+		minLng = math.Min(minLng, pos[0])
+		maxLng = math.Max(maxLng, pos[0])
+		minLat = math.Min(minLat, pos[1])
+		maxLat = math.Max(maxLat, pos[1])
+	}
+	return minLat, maxLat, minLng, maxLng
+}
+
+// PostgresPolygon formats the ring as a PostgreSQL polygon literal, with x as
+// longitude and y as latitude, e.g. "((-9.2,38.7),(-9.1,38.7),(-9.1,38.8))"
+func (r Ring) PostgresPolygon() string {
+	var b strings.Builder
+	b.WriteByte('(')
+	// PostgreSQL closes polygons implicitly, so the repeated last position is left out
+	for i, p := range r[:len(r)-1] {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('(')
+		b.WriteString(strconv.FormatFloat(p[0], 'f', -1, 64))
+		b.WriteByte(',')
+		b.WriteString(strconv.FormatFloat(p[1], 'f', -1, 64))
+		b.WriteByte(')')
+	}
+	b.WriteByte(')')
+	return b.String()
+}
@@ -0,0 +1,272 @@
+// Package mvt encodes point layers as Mapbox Vector Tiles (specification
+// version 2.1), writing the protobuf wire format directly.
+package mvt
+
+import (
+	"fmt"
+	"math"
+
+	"localRental/pkg/geo"
+)
+
+// DefaultExtent is the number of tile coordinate units across a tile
+const DefaultExtent = 4096
+
+// MaxZoom is the deepest zoom level tiles are served for
+const MaxZoom = 22
+
+// Field numbers and types of the vector tile protobuf schema
+const (
+	tileLayers = 3
+
+	layerName     = 1
+	layerFeatures = 2
+	layerKeys     = 3
+	layerValues   = 4
+	layerExtent   = 5
+	layerVersion  = 15
+
+	featureID       = 1
+	featureTags     = 2
+	featureType     = 3
+	featureGeometry = 4
+
+	valueString = 1
+	valueDouble = 3
+	valueInt    = 4
+	valueBool   = 7
+
+	geomTypePoint = 1
+	cmdMoveTo     = 1
+)
+
+// Protobuf wire types
+const (
+	wireVarint = 0
+	wire64Bit  = 1
+	wireBytes  = 2
+)
+
+// Property is a feature attribute. Values may be strings, integers, float64
+// or bool.
+type Property struct {
+	Key   string
+	Value any
+}
+
+// value is a property value as stored in a layer's value table, comparable so
+// equal values share one entry
+type value struct {
+	kind int
+	s    string
+	i    int64
+	f    float64
+	b    bool
+}
+
+// Layer is a layer of point features, built up with AddPoint
+type Layer struct {
+	name     string
+	extent   uint32
+	features [][]byte
+	keys     []string
+	keyIndex map[string]uint32
+	values   []value
+	valIndex map[value]uint32
+}
+
+// NewLayer returns an empty layer with the given name and extent
+func NewLayer(name string, extent uint32) *Layer {
+	return &Layer{
+		name:     name,
+		extent:   extent,
+		keyIndex: make(map[string]uint32),
+		valIndex: make(map[value]uint32),
+	}
+}
+
+// Len returns the number of features in the layer
+func (l *Layer) Len() int {
+	return len(l.features)
+}
+
+// AddPoint adds a point feature at tile coordinates (x, y), y growing
+// downwards, with the given id and properties
+func (l *Layer) AddPoint(id uint64, x, y int64, properties []Property) error {
+	var tags []byte
+	for _, p := range properties {
+		v, err := toValue(p.Value)
+		if err != nil {
+			return fmt.Errorf("property %s: %w", p.Key, err)
+		}
+		tags = appendVarint(tags, uint64(l.keyID(p.Key)))
+		tags = appendVarint(tags, uint64(l.valueID(v)))
+	}
+
+	// A single MoveTo from the origin of the tile
+	var geometry []byte
+	geometry = appendVarint(geometry, uint64(cmdMoveTo|1<<3))
+	geometry = appendVarint(geometry, zigzag(x))
+	geometry = appendVarint(geometry, zigzag(y))
+
+	var f []byte
+	f = appendTag(f, featureID, wireVarint)
+	f = appendVarint(f, id)
+	if len(tags) > 0 {
+		f = appendBytes(f, featureTags, tags)
+	}
+	f = appendTag(f, featureType, wireVarint)
+	f = appendVarint(f, geomTypePoint)
+	f = appendBytes(f, featureGeometry, geometry)
+
+	l.features = append(l.features, f)
+	return nil
+}
+
+func (l *Layer) keyID(key string) uint32 {
+	id, ok := l.keyIndex[key]
+	if !ok {
+		id = uint32(len(l.keys))
+		l.keys = append(l.keys, key)
+		l.keyIndex[key] = id
+	}
+	return id
+}
+
+func (l *Layer) valueID(v value) uint32 {
+	id, ok := l.valIndex[v]
+	if !ok {
+		id = uint32(len(l.values))
+		l.values = append(l.values, v)
+		l.valIndex[v] = id
+	}
+	return id
+}
+
+func (l *Layer) encode() []byte {
+	var b []byte
+	b = appendTag(b, layerVersion, wireVarint)
+	b = appendVarint(b, 2)
+	b = appendBytes(b, layerName, []byte(l.name))
+	for _, f := range l.features {
+		b = appendBytes(b, layerFeatures, f)
+	}
+	for _, k := range l.keys {
+		b = appendBytes(b, layerKeys, []byte(k))
+	}
+	for _, v := range l.values {
+		b = appendBytes(b, layerValues, v.encode())
+	}
+	b = appendTag(b, layerExtent, wireVarint)
+	b = appendVarint(b, uint64(l.extent))
+	return b
+}
+
+// Encode returns the tile holding the given layers. Empty layers are left
+// out, so a tile without features encodes to no bytes.
+func Encode(layers ...*Layer) []byte {
+	var b []byte
+	for _, l := range layers {
+		if l.Len() == 0 {
+			continue
+		}
+		b = appendBytes(b, tileLayers, l.encode())
+	}
+	return b
+}
+
+func toValue(v any) (value, error) {
+	switch v := v.(type) {
+	case string:
+		return value{kind: valueString, s: v}, nil
+	case int:
+		return value{kind: valueInt, i: int64(v)}, nil
+	case int64:
+		return value{kind: valueInt, i: v}, nil
+	case float64:
+		return value{kind: valueDouble, f: v}, nil
+	case bool:
+		return value{kind: valueBool, b: v}, nil
+	default:
+		return value{}, fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+func (v value) encode() []byte {
+	var b []byte
+	switch v.kind {
+	case valueString:
+		b = appendBytes(b, valueString, []byte(v.s))
+	case valueDouble:
+		b = appendTag(b, valueDouble, wire64Bit)
+		bits := math.Float64bits(v.f)
+		for i := 0; i < 8; i++ {
+			b = append(b, byte(bits>>(8*i)))
+		}
+	case valueInt:
+		// int_value is an int64 field, so negatives take ten bytes
+		b = appendTag(b, valueInt, wireVarint)
+		b = appendVarint(b, uint64(v.i))
+	case valueBool:
+		b = appendTag(b, valueBool, wireVarint)
+		if v.b {
+			b = appendVarint(b, 1)
+		} else {
+			b = appendVarint(b, 0)
+		}
+	}
+	return b
+}
+
+// TileBounds returns the latitude/longitude box of tile z/x/y grown by buffer
+// tile coordinate units on each side, out of extent across the tile
+func TileBounds(z, x, y int, extent, buffer uint32) (minLat, maxLat, minLng, maxLng float64) {
+	n := float64(uint64(1) << z)
+	pad := float64(buffer) / float64(extent)
+	maxLat, minLng = geo.WebMercatorInverse((float64(x)-pad)/n, (float64(y)-pad)/n)
+	minLat, maxLng = geo.WebMercatorInverse((float64(x)+1+pad)/n, (float64(y)+1+pad)/n)
+	return minLat, maxLat, minLng, maxLng
+}
+
+// TileCoordinates transforms a WGS84 position to the coordinates of tile
+// z/x/y, from (0, 0) at its north-west corner to (extent, extent) at its
+// south-east corner
+func TileCoordinates(lat, lng float64, z, x, y int, extent uint32) (int64, int64) {
+	n := float64(uint64(1) << z)
+	mx, my := geo.WebMercator(lat, lng)
+	px := math.Round((mx*n - float64(x)) * float64(extent))
+	py := math.Round((my*n - float64(y)) * float64(extent))
+	return int64(px), int64(py)
+}
+
+// ValidTile reports whether z/x/y addresses a tile of the Web Mercator pyramid
+func ValidTile(z, x, y int) bool {
+	if z < 0 || z > MaxZoom {
+		return false
+	}
+	n := 1 << z
+	return x >= 0 && x < n && y >= 0 && y < n
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendTag(b []byte, field, wireType int) []byte {
+	return appendVarint(b, uint64(field<<3|wireType))
+}
+
+func appendBytes(b []byte, field int, data []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = appendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// zigzag maps signed integers to unsigned ones so small magnitudes stay small
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
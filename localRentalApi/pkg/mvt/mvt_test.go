@@ -0,0 +1,309 @@
+package mvt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// field is a decoded protobuf field: varint and fixed64 values in n, length
+// delimited ones in b
+type field struct {
+	num  int
+	wire int
+	n    uint64
+	b    []byte
+}
+
+// decodeFields splits a protobuf message into its fields
+func decodeFields(t *testing.T, msg []byte) []field {
+	t.Helper()
+
+	var fields []field
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			t.Fatalf("bad field key in % x", msg)
+		}
+		msg = msg[n:]
+
+		f := field{num: int(key >> 3), wire: int(key & 7)}
+		switch f.wire {
+		case wireVarint:
+			f.n, n = binary.Uvarint(msg)
+			if n <= 0 {
+				t.Fatalf("bad varint in field %d", f.num)
+			}
+			msg = msg[n:]
+		case wire64Bit:
+			f.n = binary.LittleEndian.Uint64(msg)
+			msg = msg[8:]
+		case wireBytes:
+			size, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < size {
+				t.Fatalf("bad length in field %d", f.num)
+			}
+			f.b = msg[n : n+int(size)]
+			msg = msg[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %d in field %d", f.wire, f.num)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// decodePacked reads a packed repeated varint field
+func decodePacked(t *testing.T, b []byte) []uint64 {
+	t.Helper()
+
+	var values []uint64
+	for len(b) > 0 {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatalf("bad packed varint in % x", b)
+		}
+		values = append(values, v)
+		b = b[n:]
+	}
+	return values
+}
+
+// decodedFeature is a point feature read back from a tile
+type decodedFeature struct {
+	id         uint64
+	x, y       int64
+	properties map[string]any
+}
+
+// decodedLayer is a layer read back from a tile
+type decodedLayer struct {
+	version  uint64
+	name     string
+	extent   uint64
+	features []decodedFeature
+}
+
+// decodeTile reads back the point layers of a tile, following the vector
+// tile specification rather than the encoder
+func decodeTile(t *testing.T, tile []byte) []decodedLayer {
+	t.Helper()
+
+	var layers []decodedLayer
+	for _, lf := range decodeFields(t, tile) {
+		if lf.num != tileLayers || lf.wire != wireBytes {
+			t.Fatalf("unexpected tile field %d", lf.num)
+		}
+
+		var layer decodedLayer
+		var keys []string
+		var values []any
+		var features [][]byte
+		for _, f := range decodeFields(t, lf.b) {
+			switch f.num {
+			case layerVersion:
+				layer.version = f.n
+			case layerName:
+				layer.name = string(f.b)
+			case layerExtent:
+				layer.extent = f.n
+			case layerKeys:
+				keys = append(keys, string(f.b))
+			case layerValues:
+				v := decodeFields(t, f.b)
+				if len(v) != 1 {
+					t.Fatalf("value with %d fields", len(v))
+				}
+				switch v[0].num {
+				case valueString:
+					values = append(values, string(v[0].b))
+				case valueDouble:
+					values = append(values, math.Float64frombits(v[0].n))
+				case valueInt:
+					values = append(values, int64(v[0].n))
+				case valueBool:
+					values = append(values, v[0].n != 0)
+				default:
+					t.Fatalf("unexpected value type %d", v[0].num)
+				}
+			case layerFeatures:
+				features = append(features, f.b)
+			default:
+				t.Fatalf("unexpected layer field %d", f.num)
+			}
+		}
+
+		// Features refer to the key and value tables, read in full above
+		for _, b := range features {
+			feature := decodedFeature{properties: map[string]any{}}
+			var geomType uint64
+			for _, f := range decodeFields(t, b) {
+				switch f.num {
+				case featureID:
+					feature.id = f.n
+				case featureType:
+					geomType = f.n
+				case featureTags:
+					tags := decodePacked(t, f.b)
+					if len(tags)%2 != 0 {
+						t.Fatalf("odd number of tags %v", tags)
+					}
+					for i := 0; i < len(tags); i += 2 {
+						feature.properties[keys[tags[i]]] = values[tags[i+1]]
+					}
+				case featureGeometry:
+					geometry := decodePacked(t, f.b)
+					if len(geometry) != 3 || geometry[0] != cmdMoveTo|1<<3 {
+						t.Fatalf("geometry %v is not a single MoveTo", geometry)
+					}
+					feature.x = unzigzag(geometry[1])
+					feature.y = unzigzag(geometry[2])
+				}
+			}
+			if geomType != geomTypePoint {
+				t.Fatalf("feature %d has geometry type %d, want point", feature.id, geomType)
+			}
+			layer.features = append(layer.features, feature)
+		}
+		layers = append(layers, layer)
+	}
+	return layers
+}
+
+func unzigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	layer := NewLayer("alojamentos", DefaultExtent)
+	points := []decodedFeature{
+		{id: 1, x: 0, y: 0, properties: map[string]any{"id": int64(1), "modalidade": "Apartamento", "nr_utentes": int64(4)}},
+		{id: 2, x: 4095, y: 17, properties: map[string]any{"id": int64(2), "modalidade": "Moradia", "score": 4.5}},
+		// Buffered points lie outside the tile, at negative coordinates
+		{id: 300, x: -64, y: 4160, properties: map[string]any{"id": int64(300), "modalidade": "Apartamento", "offset": int64(-3), "clean_safe": true}},
+	}
+	for _, p := range points {
+		var properties []Property
+		for _, key := range []string{"id", "modalidade", "nr_utentes", "score", "offset", "clean_safe"} {
+			if v, ok := p.properties[key]; ok {
+				properties = append(properties, Property{Key: key, Value: v})
+			}
+		}
+		if err := layer.AddPoint(p.id, p.x, p.y, properties); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	layers := decodeTile(t, Encode(layer))
+	if len(layers) != 1 {
+		t.Fatalf("got %d layers, want 1", len(layers))
+	}
+	got := layers[0]
+	if got.version != 2 || got.name != "alojamentos" || got.extent != DefaultExtent {
+		t.Errorf("layer version %d, name %q, extent %d; want 2, alojamentos, %d", got.version, got.name, got.extent, DefaultExtent)
+	}
+	if !reflect.DeepEqual(got.features, points) {
+		t.Errorf("features\n%+v\nwant\n%+v", got.features, points)
+	}
+}
+
+func TestEncodeSharesKeysAndValues(t *testing.T) {
+	layer := NewLayer("alojamentos", DefaultExtent)
+	for id := range uint64(3) {
+		if err := layer.AddPoint(id+1, 10, 10, []Property{{Key: "modalidade", Value: "Moradia"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var keys, values int
+	for _, lf := range decodeFields(t, Encode(layer)) {
+		for _, f := range decodeFields(t, lf.b) {
+			switch f.num {
+			case layerKeys:
+				keys++
+			case layerValues:
+				values++
+			}
+		}
+	}
+	if keys != 1 || values != 1 {
+		t.Errorf("got %d keys and %d values, want one of each", keys, values)
+	}
+}
+
+func TestEncodeKnownBytes(t *testing.T) {
+	layer := NewLayer("a", 4096)
+	if err := layer.AddPoint(1, 25, 17, []Property{{Key: "k", Value: "v"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The point example of the vector tile specification, MoveTo(25, 17),
+	// in a layer named "a" with one string property
+	want := []byte{
+		0x1a, 0x1f, // layers, 31 bytes
+		0x78, 0x02, // version 2
+		0x0a, 0x01, 'a', // name
+		0x12, 0x0d, // feature, 13 bytes
+		0x08, 0x01, // id 1
+		0x12, 0x02, 0x00, 0x00, // tags key 0, value 0
+		0x18, 0x01, // type point
+		0x22, 0x03, 0x09, 0x32, 0x22, // geometry MoveTo(25, 17)
+		0x1a, 0x01, 'k', // keys
+		0x22, 0x03, 0x0a, 0x01, 'v', // values, string "v"
+		0x28, 0x80, 0x20, // extent 4096
+	}
+	if got := Encode(layer); !bytes.Equal(got, want) {
+		t.Errorf("Encode() =\n% x\nwant\n% x", got, want)
+	}
+}
+
+func TestEncodeSkipsEmptyLayers(t *testing.T) {
+	if got := Encode(NewLayer("alojamentos", DefaultExtent)); len(got) != 0 {
+		t.Errorf("empty layer encoded to %d bytes", len(got))
+	}
+}
+
+func TestAddPointRejectsUnsupportedValues(t *testing.T) {
+	layer := NewLayer("alojamentos", DefaultExtent)
+	if err := layer.AddPoint(1, 0, 0, []Property{{Key: "when", Value: []int{1}}}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if layer.Len() != 0 {
+		t.Errorf("layer holds %d features after a failed AddPoint", layer.Len())
+	}
+}
+
+func TestTileCoordinates(t *testing.T) {
+	// Lisbon lies in tile 12/1944/1569
+	lat, lng := 38.7077, -9.1365
+	x, y := TileCoordinates(lat, lng, 12, 1944, 1569, DefaultExtent)
+	if x < 0 || x > DefaultExtent || y < 0 || y > DefaultExtent {
+		t.Errorf("TileCoordinates = %d, %d, outside tile 12/1944/1569", x, y)
+	}
+
+	minLat, maxLat, minLng, maxLng := TileBounds(12, 1944, 1569, DefaultExtent, 0)
+	if lat < minLat || lat > maxLat || lng < minLng || lng > maxLng {
+		t.Errorf("TileBounds = %v..%v, %v..%v, not holding %v, %v", minLat, maxLat, minLng, maxLng, lat, lng)
+	}
+}
+
+func TestValidTile(t *testing.T) {
+	tests := []struct {
+		z, x, y int
+		want    bool
+	}{
+		{0, 0, 0, true},
+		{0, 1, 0, false},
+		{12, 4095, 4095, true},
+		{12, 4096, 0, false},
+		{-1, 0, 0, false},
+		{MaxZoom + 1, 0, 0, false},
+	}
+	for _, tt := range tests {
+		if got := ValidTile(tt.z, tt.x, tt.y); got != tt.want {
+			t.Errorf("ValidTile(%d, %d, %d) = %v, want %v", tt.z, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package spatial
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Cache is a least recently used cache of results computed from the data an
+// index was built from. Each entry is tagged with that index, so once a
+// rebuild swaps in a new one the old entries are misses, including any stored
+// late by a request that started before the rebuild.
+type Cache struct {
+	mu      sync.Mutex
+	maxCost int
+	cost    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key   string
+	index *Index
+	value any
+	cost  int
+}
+
+// NewCache returns a cache evicting its least recently used entries once the
+// cost of its entries exceeds maxCost
+func NewCache(maxCost int) *Cache {
+	return &Cache{
+		maxCost: maxCost,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value stored under key for index
+func (c *Cache) Get(key string, index *Index) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if entry.index != index {
+		c.remove(elem)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Put stores value under key for index, with the given cost. Values costing
+// more than the whole cache are not stored.
+func (c *Cache) Put(key string, index *Index, value any, cost int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	if cost > c.maxCost {
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, index: index, value: value, cost: cost})
+	c.cost += cost
+	for c.cost > c.maxCost {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *Cache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.cost -= entry.cost
+}
@@ -0,0 +1,122 @@
+package spatial
+
+import (
+	"math"
+	"sort"
+
+	"localRental/pkg/geo"
+)
+
+// ClusterCellPixels is the side of a clustering grid cell in pixels of a
+// 256 pixel tile, so clusters keep about the same spacing on screen at every
+// zoom level
+const ClusterCellPixels = 64
+
+// MaxClusterZoom is the deepest zoom level at which points are clustered;
+// beyond it every point is returned on its own
+const MaxClusterZoom = 16
+
+// ClusterPoint is a position to cluster, with the attributes shown on a map
+type ClusterPoint struct {
+	ID          int
+	Lat         float64
+	Lng         float64
+	Modalidade  string
+	Denominacao string
+}
+
+// Cluster is the group of points falling in one grid cell: their count,
+// centroid, bounds and count per modalidade. A cluster of a single point
+// carries that point.
+type Cluster struct {
+	Count        int
+	Lat          float64
+	Lng          float64
+	MinLat       float64
+	MinLng       float64
+	MaxLat       float64
+	MaxLng       float64
+	ByModalidade map[string]int
+	Point        *ClusterPoint
+}
+
+// GridClusters groups points by the Web Mercator grid cell of
+// ClusterCellPixels they fall in at zoom, ordered by cell, row by row
+func GridClusters(points []ClusterPoint, zoom int) []Cluster {
+	type cell struct {
+		key     int64
+		cluster Cluster
+	}
+
+	n := GridSize(zoom)
+	cells := make(map[int64]*cell)
+	for i := range points {
+		p := &points[i]
+
+		var key int64
+		if zoom > MaxClusterZoom {
+			key = int64(i)
+		} else {
+			x, y := GridCell(p.Lat, p.Lng, zoom)
+			key = y*n + x
+		}
+
+		c, ok := cells[key]
+		if !ok {
+			c = &cell{key: key, cluster: Cluster{
+				MinLat: p.Lat, MinLng: p.Lng, MaxLat: p.Lat, MaxLng: p.Lng,
+				ByModalidade: map[string]int{},
+			}}
+			cells[key] = c
+		}
+
+		cl := &c.cluster
+		cl.Count++
+		// Accumulate sums in Lat and Lng; they become means below
+		cl.Lat += p.Lat
+		cl.Lng += p.Lng
+		cl.MinLat = math.Min(cl.MinLat, p.Lat)
+		cl.MinLng = math.Min(cl.MinLng, p.Lng)
+		cl.MaxLat = math.Max(cl.MaxLat, p.Lat)
+		cl.MaxLng = math.Max(cl.MaxLng, p.Lng)
+		cl.ByModalidade[p.Modalidade]++
+		cl.Point = p
+	}
+
+	ordered := make([]*cell, 0, len(cells))
+	for _, c := range cells {
+		ordered = append(ordered, c)
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].key < ordered[j].key })
+
+	clusters := make([]Cluster, len(ordered))
+	for i, c := range ordered {
+		cl := c.cluster
+		cl.Lat /= float64(cl.Count)
+		cl.Lng /= float64(cl.Count)
+		if cl.Count == 1 {
+			point := *cl.Point
+			cl.Point = &point
+			cl.Lat, cl.Lng = point.Lat, point.Lng
+		} else {
+			cl.Point = nil
+		}
+		clusters[i] = cl
+	}
+	return clusters
+}
+
+// GridSize returns the number of grid cells along each axis at zoom
+func GridSize(zoom int) int64 {
+	return int64(256/ClusterCellPixels) << zoom
+}
+
+// GridCell returns the column and row of the grid cell holding a position at
+// zoom, counted from the north-west corner of the Web Mercator square
+func GridCell(lat, lng float64, zoom int) (x, y int64) {
+	n := GridSize(zoom)
+	mx, my := geo.WebMercator(lat, lng)
+	x = min(int64(mx*float64(n)), n-1)
+	y = min(int64(my*float64(n)), n-1)
+	return x, y
+}
@@ -0,0 +1,235 @@
+package spatial
+
+import (
+	"container/heap"
+	"math"
+	"time"
+
+	"localRental/pkg/geo"
+)
+
+// Point is an indexed accommodation position
+type Point struct {
+	ID  int
+	Lat float64
+	Lng float64
+}
+
+// Neighbor is a point found by a nearest neighbour query, with its
+// great-circle distance from the query position
+type Neighbor struct {
+	Point
+	DistanceM float64
+}
+
+// entry is a point with its position as a vector on the unit sphere. The
+// straight-line (chord) distance between two such vectors grows with the
+// great-circle distance, so nearest in 3D is nearest on the globe, with no
+// special cases at the poles or the antimeridian.
+type entry struct {
+	Point
+	v [3]float64
+}
+
+// Index is an immutable KD-tree over accommodation positions. The tree is
+// implicit: each range of entries holds its median at the middle, split on
+// the axis of the range's depth, with the lower half before it and the upper
+// half after it.
+type Index struct {
+	entries []entry
+	byID    map[int]int
+	builtAt time.Time
+}
+
+// NewIndex builds an index over points. The slice is not retained.
+func NewIndex(points []Point) *Index {
+	idx := &Index{
+		entries: make([]entry, len(points)),
+		byID:    make(map[int]int, len(points)),
+		builtAt: time.Now(),
+	}
+	for i, p := range points {
+		idx.entries[i] = entry{Point: p, v: unitVector(p.Lat, p.Lng)}
+	}
+
+	idx.build(0, len(idx.entries), 0)
+	for i, e := range idx.entries {
+		idx.byID[e.ID] = i
+	}
+	return idx
+}
+
+func (idx *Index) build(lo, hi, depth int) {
+	if hi-lo < 2 {
+		return
+	}
+	mid := (lo + hi) / 2
+	selectNth(idx.entries[lo:hi], mid-lo, depth%3)
+	idx.build(lo, mid, depth+1)
+	idx.build(mid+1, hi, depth+1)
+}
+
+// Len returns the number of indexed points
+func (idx *Index) Len() int {
+	return len(idx.entries)
+}
+
+// BuiltAt returns when the index was built
+func (idx *Index) BuiltAt() time.Time {
+	return idx.builtAt
+}
+
+// Position returns the indexed position of the point with the given id
+func (idx *Index) Position(id int) (lat, lng float64, ok bool) {
+	i, ok := idx.byID[id]
+	if !ok {
+		return 0, 0, false
+	}
+	return idx.entries[i].Lat, idx.entries[i].Lng, true
+}
+
+// Nearest returns the k points closest to (lat, lng), nearest first, leaving
+// out the point whose id is exclude (pass 0 to keep every point). Ties are
+// broken by id so results are stable across rebuilds.
+func (idx *Index) Nearest(lat, lng float64, k, exclude int) []Neighbor {
+	if k <= 0 || len(idx.entries) == 0 {
+		return nil
+	}
+
+	s := search{
+		entries: idx.entries,
+		target:  unitVector(lat, lng),
+		k:       k,
+		exclude: exclude,
+	}
+	s.visit(0, len(idx.entries), 0)
+
+	neighbors := make([]Neighbor, len(s.best))
+	for i := len(s.best) - 1; i >= 0; i-- {
+		c := heap.Pop(&s.best).(candidate)
+		e := idx.entries[c.index]
+		neighbors[i] = Neighbor{Point: e.Point, DistanceM: geo.DistanceMeters(lat, lng, e.Lat, e.Lng)}
+	}
+	return neighbors
+}
+
+// search holds the state of a k nearest neighbour query
+type search struct {
+	entries []entry
+	target  [3]float64
+	k       int
+	exclude int
+	best    candidates
+}
+
+func (s *search) visit(lo, hi, depth int) {
+	if lo >= hi {
+		return
+	}
+	mid := (lo + hi) / 2
+	e := &s.entries[mid]
+	if e.ID != s.exclude {
+		s.offer(candidate{index: mid, id: e.ID, dist2: dist2(e.v, s.target)})
+	}
+
+	axis := depth % 3
+	diff := s.target[axis] - e.v[axis]
+	near, far := [2]int{lo, mid}, [2]int{mid + 1, hi}
+	if diff > 0 {
+		near, far = far, near
+	}
+
+	s.visit(near[0], near[1], depth+1)
+	// The far side can only hold closer points when the splitting plane is
+	// closer than the current k-th candidate
+	if len(s.best) < s.k || diff*diff <= s.best[0].dist2 {
+		s.visit(far[0], far[1], depth+1)
+	}
+}
+
+// offer keeps c if it is among the k closest candidates seen so far
+func (s *search) offer(c candidate) {
+	if len(s.best) < s.k {
+		heap.Push(&s.best, c)
+		return
+	}
+	if c.closerThan(s.best[0]) {
+		s.best[0] = c
+		heap.Fix(&s.best, 0)
+	}
+}
+
+// candidate is an entry found by a search, with its squared chord distance
+type candidate struct {
+	index int
+	id    int
+	dist2 float64
+}
+
+func (c candidate) closerThan(o candidate) bool {
+	if c.dist2 != o.dist2 {
+		return c.dist2 < o.dist2
+	}
+	return c.id < o.id
+}
+
+// candidates is a max-heap, its furthest candidate first
+type candidates []candidate
+
+func (h candidates) Len() int           { return len(h) }
+func (h candidates) Less(i, j int) bool { return h[j].closerThan(h[i]) }
+func (h candidates) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *candidates) Push(x any)        { *h = append(*h, x.(candidate)) }
+func (h *candidates) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// selectNth reorders entries so that the n-th one on axis is in place, with
+// no greater one before it and no smaller one after it (Hoare's selection)
+func selectNth(entries []entry, n, axis int) {
+	lo, hi := 0, len(entries)-1
+	for lo < hi {
+		pivot := entries[(lo+hi)/2].v[axis]
+		i, j := lo, hi
+		for i <= j {
+			for entries[i].v[axis] < pivot {
+				i++
+			}
+			for entries[j].v[axis] > pivot {
+				j--
+			}
+			if i <= j {
+				entries[i], entries[j] = entries[j], entries[i]
+				i++
+				j--
+			}
+		}
+		switch {
+		case n <= j:
+			hi = j
+		case n >= i:
+			lo = i
+		default:
+			return
+		}
+	}
+}
+
+// unitVector converts a WGS84 position in degrees to a vector on the unit sphere
+func unitVector(lat, lng float64) [3]float64 {
+	phi := lat * math.Pi / 180
+	lambda := lng * math.Pi / 180
+	return [3]float64{
+		math.Cos(phi) * math.Cos(lambda),
+		math.Cos(phi) * math.Sin(lambda),
+		math.Sin(phi),
+	}
+}
+
+func dist2(a, b [3]float64) float64 {
+	dx, dy, dz := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dx*dx + dy*dy + dz*dz
+}
@@ -0,0 +1,135 @@
+package spatial
+
+import (
+	"math"
+	"math/rand/v2"
+	"sort"
+	"testing"
+
+	"localRental/pkg/geo"
+)
+
+// bruteNearest returns the k points closest to (lat, lng) by haversine
+// distance, nearest first with ties broken by id, leaving out exclude
+func bruteNearest(points []Point, lat, lng float64, k, exclude int) []Neighbor {
+	var all []Neighbor
+	for _, p := range points {
+		if p.ID == exclude {
+			continue
+		}
+		all = append(all, Neighbor{Point: p, DistanceM: geo.DistanceMeters(lat, lng, p.Lat, p.Lng)})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].DistanceM != all[j].DistanceM {
+			return all[i].DistanceM < all[j].DistanceM
+		}
+		return all[i].ID < all[j].ID
+	})
+	return all[:min(k, len(all))]
+}
+
+// randomPoints returns n points, most of them over mainland Portugal and the
+// rest anywhere on the globe, so the poles and the antimeridian are covered
+func randomPoints(rng *rand.Rand, n int) []Point {
+	points := make([]Point, n)
+	for i := range points {
+		p := Point{ID: i + 1}
+		if i%5 == 0 {
+			p.Lat = math.Asin(2*rng.Float64()-1) * 180 / math.Pi
+			p.Lng = rng.Float64()*360 - 180
+		} else {
+			p.Lat = 37 + rng.Float64()*5
+			p.Lng = -9.5 + rng.Float64()*3.3
+		}
+		points[i] = p
+	}
+	return points
+}
+
+func TestIndexNearestMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	points := randomPoints(rng, 2000)
+	idx := NewIndex(points)
+
+	queries := randomPoints(rng, 200)
+	queries = append(queries,
+		Point{Lat: 90, Lng: 0}, Point{Lat: -90, Lng: 0},
+		Point{Lat: 0, Lng: 180}, Point{Lat: 0, Lng: -180},
+	)
+
+	for _, k := range []int{1, 5, 50} {
+		for i, q := range queries {
+			// Leave out an indexed point for some queries
+			exclude := 0
+			if i%3 == 0 {
+				exclude = points[i].ID
+			}
+
+			got := idx.Nearest(q.Lat, q.Lng, k, exclude)
+			want := bruteNearest(points, q.Lat, q.Lng, k, exclude)
+			if len(got) != len(want) {
+				t.Fatalf("Nearest(%v, %v, %d) returned %d points, want %d", q.Lat, q.Lng, k, len(got), len(want))
+			}
+			for j := range want {
+				// The index ranks by chord length, the scan by haversine
+				// distance; both orders agree up to rounding
+				if math.Abs(got[j].DistanceM-want[j].DistanceM) > 1e-6 {
+					t.Fatalf("Nearest(%v, %v, %d)[%d] = id %d at %.3f m, want id %d at %.3f m",
+						q.Lat, q.Lng, k, j, got[j].ID, got[j].DistanceM, want[j].ID, want[j].DistanceM)
+				}
+				if got[j].ID == exclude {
+					t.Fatalf("Nearest(%v, %v, %d) returned the excluded point %d", q.Lat, q.Lng, k, exclude)
+				}
+			}
+		}
+	}
+}
+
+func TestIndexNearestMorePointsThanIndexed(t *testing.T) {
+	points := []Point{
+		{ID: 1, Lat: 38.7077, Lng: -9.1365},
+		{ID: 2, Lat: 41.1456, Lng: -8.6109},
+		{ID: 3, Lat: 37.0194, Lng: -7.9322},
+	}
+	idx := NewIndex(points)
+
+	got := idx.Nearest(38.72, -9.14, 10, 0)
+	if len(got) != len(points) {
+		t.Fatalf("got %d points, want all %d", len(got), len(points))
+	}
+	for i, id := range []int{1, 3, 2} {
+		if got[i].ID != id {
+			t.Errorf("rank %d is id %d, want %d", i, got[i].ID, id)
+		}
+	}
+
+	if got := idx.Nearest(38.72, -9.14, 10, 1); len(got) != 2 {
+		t.Errorf("got %d points excluding one, want 2", len(got))
+	}
+}
+
+func TestIndexEmpty(t *testing.T) {
+	idx := NewIndex(nil)
+
+	if idx.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", idx.Len())
+	}
+	if got := idx.Nearest(38.72, -9.14, 5, 0); len(got) != 0 {
+		t.Errorf("Nearest on an empty index returned %d points", len(got))
+	}
+	if _, _, ok := idx.Position(1); ok {
+		t.Error("Position found a point in an empty index")
+	}
+}
+
+func TestIndexPosition(t *testing.T) {
+	points := randomPoints(rand.New(rand.NewPCG(3, 4)), 100)
+	idx := NewIndex(points)
+
+	for _, p := range points {
+		lat, lng, ok := idx.Position(p.ID)
+		if !ok || lat != p.Lat || lng != p.Lng {
+			t.Fatalf("Position(%d) = %v, %v, %v, want %v, %v", p.ID, lat, lng, ok, p.Lat, p.Lng)
+		}
+	}
+}
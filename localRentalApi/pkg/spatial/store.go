@@ -0,0 +1,132 @@
+package spatial
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// maxClusterCacheCost bounds the cluster cache, counted in the points and
+// clusters it holds
+const maxClusterCacheCost = 2_000_000
+
+// maxTileCacheBytes bounds the vector tile cache
+const maxTileCacheBytes = 256 << 20
+
+// Store holds the current index of active accommodation positions and
+// rebuilds it from the database when an import finishes. Queries read the
+// index they got from Index while a rebuild swaps in a new one.
+type Store struct {
+	db       *sql.DB
+	index    atomic.Pointer[Index]
+	clusters *Cache
+	tiles    *Cache
+	// lastImport is the finish time of the latest import run the index was
+	// built after, only touched by Load and Watch
+	lastImport sql.NullTime
+}
+
+// NewStore returns a store for db holding an empty index until Load is called
+func NewStore(db *sql.DB) *Store {
+	s := &Store{db: db, clusters: NewCache(maxClusterCacheCost), tiles: NewCache(maxTileCacheBytes)}
+	s.index.Store(NewIndex(nil))
+	return s
+}
+
+// Index returns the current index
+func (s *Store) Index() *Index {
+	return s.index.Load()
+}
+
+// Clusters returns the cache of map clusters, whose entries are dropped once
+// the index is rebuilt
+func (s *Store) Clusters() *Cache {
+	return s.clusters
+}
+
+// Tiles returns the cache of encoded vector tiles, whose entries are dropped
+// once the index is rebuilt
+func (s *Store) Tiles() *Cache {
+	return s.tiles
+}
+
+// Load rebuilds the index from the active accommodations that have coordinates
+func (s *Store) Load(ctx context.Context) error {
+	// Read the import marker first, so an import finishing during the load
+	// triggers another rebuild on the next check
+	lastImport, err := s.latestImport(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, latitude, longitude
+		FROM alojamentos
+		WHERE deregistered_at IS NULL
+		  AND latitude IS NOT NULL AND longitude IS NOT NULL
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to query positions: %w", err)
+	}
+	defer rows.Close()
+
+	var points []Point
+	for rows.Next() {
+		var p Point
+		if err := rows.Scan(&p.ID, &p.Lat, &p.Lng); err != nil {
+			return fmt.Errorf("failed to scan position: %w", err)
+		}
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read positions: %w", err)
+	}
+
+	s.index.Store(NewIndex(points))
+	s.lastImport = lastImport
+	return nil
+}
+
+// Watch checks the import_runs ledger every interval and reloads the index
+// when a run has finished since the last load, until ctx is cancelled. Failed
+// runs count too, as they may have committed part of their changes.
+func (s *Store) Watch(ctx context.Context, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		lastImport, err := s.latestImport(ctx)
+		if err != nil {
+			logger.Error("spatial index import check failed", "error", err)
+			continue
+		}
+		if lastImport.Valid == s.lastImport.Valid && lastImport.Time.Equal(s.lastImport.Time) {
+			continue
+		}
+
+		start := time.Now()
+		if err := s.Load(ctx); err != nil {
+			logger.Error("spatial index rebuild failed", "error", err)
+			continue
+		}
+		logger.Info("spatial index rebuilt", "points", s.Index().Len(), "duration", time.Since(start))
+	}
+}
+
+// latestImport returns when the most recent import run finished
+func (s *Store) latestImport(ctx context.Context) (sql.NullTime, error) {
+	var finishedAt sql.NullTime
+	if err := s.db.QueryRowContext(ctx, "SELECT MAX(finished_at) FROM import_runs").Scan(&finishedAt); err != nil {
+		return finishedAt, fmt.Errorf("failed to check import runs: %w", err)
+	}
+	return finishedAt, nil
+}
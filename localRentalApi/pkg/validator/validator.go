@@ -2,14 +2,29 @@ package validator
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 )
 
 var validate *validator.Validate
 
+// ptPostalCodeRegex matches Portuguese postal codes (CP4-CP3)
+var ptPostalCodeRegex = regexp.MustCompile(`^\d{4}-\d{3}$`)
+
 func init() {
 	validate = validator.New()
+	validate.RegisterValidation("pt_postal_code", func(fl validator.FieldLevel) bool {
+		return ptPostalCodeRegex.MatchString(fl.Field().String())
+	})
+}
+
+// FieldError describes a single failed validation rule
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
 }
 
 // Validate validates a struct based on its validation tags
@@ -30,11 +45,35 @@ func FormatValidationError(err error) map[string]string {
 	return errors
 }
 
+// FieldErrors converts validator errors into a list of failed rules
+func FieldErrors(err error) []FieldError {
+	var fieldErrors []FieldError
+
+	if validationErrors, ok := err.(validator.ValidationErrors); ok {
+		for _, e := range validationErrors {
+			fieldErrors = append(fieldErrors, FieldError{
+				Field:   e.Field(),
+				Rule:    e.Tag(),
+				Message: formatError(e),
+			})
+		}
+	}
+
+	return fieldErrors
+}
+
 // formatError formats a single validation error into a human-readable message
 func formatError(e validator.FieldError) string {
 	switch e.Tag() {
 	case "required":
 		return fmt.Sprintf("%s is required", e.Field())
+	case "required_with":
+		return fmt.Sprintf("%s is required with %s", e.Field(), strings.Join(strings.Fields(e.Param()), " and "))
+	case "required_if":
+		if param := strings.Fields(e.Param()); len(param) == 2 {
+			return fmt.Sprintf("%s is required when %s is %s", e.Field(), param[0], param[1])
+		}
+		return fmt.Sprintf("%s is required", e.Field())
 	case "email":
 		return "Invalid email format"
 	case "min":
@@ -55,6 +94,12 @@ func formatError(e validator.FieldError) string {
 		return fmt.Sprintf("%s must contain only alphanumeric characters", e.Field())
 	case "oneof":
 		return fmt.Sprintf("%s must be one of: %s", e.Field(), e.Param())
+	case "latitude", "longitude":
+		return fmt.Sprintf("%s must be a valid %s", e.Field(), e.Tag())
+	case "datetime":
+		return fmt.Sprintf("%s must be a date in the format %s", e.Field(), e.Param())
+	case "pt_postal_code":
+		return fmt.Sprintf("%s must be a postal code in the format NNNN-NNN", e.Field())
 	default:
 		return fmt.Sprintf("%s is invalid", e.Field())
 	}